@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 
 	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/server"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 )
 
 func main() {
@@ -20,11 +22,21 @@ func main() {
 func run() error {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
-	s, err := server.Start(
-		ctx,
+	opts := []server.ServerOption{
 		server.WithDevMode(),
 		server.WithAuthOptions(auth.WithAdminGroups("admin")),
-	)
+	}
+	// Export traces using OTLP if an endpoint has been configured.
+	// The exporter is configured using the standard OTEL_EXPORTER_OTLP_*
+	// environment variables.
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return fmt.Errorf("creating otlp trace exporter: %w", err)
+		}
+		opts = append(opts, server.WithTraceExporter(exporter))
+	}
+	s, err := server.Start(ctx, opts...)
 	if err != nil {
 		return err
 	}
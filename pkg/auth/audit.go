@@ -0,0 +1,475 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+const (
+	streamAudit = "hz_audit"
+	// format: HZ.audit.<namespace>
+	subjectAudit    = "HZ.audit.%s"
+	subjectAuditAll = "HZ.audit.>"
+
+	SubjectAPIAuditQuery      = "HZ.api.audit.query"
+	SubjectInternalAuditQuery = "HZ.internal.audit.query"
+)
+
+// MaxAuditQueryLimit is the maximum number of entries returned by an audit
+// query, and the limit of queries that do not set one.
+const MaxAuditQueryLimit = 1000
+
+// auditQueryBatch is the number of stream sequences read at a time by an
+// audit query.
+const auditQueryBatch = 256
+
+// AuditEntry records a single mutating API request: who made it, what they
+// tried to do and what the result was.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Groups []string  `json:"groups,omitempty"`
+	Verb   Verb      `json:"verb"`
+
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Action is the name of the action for run requests, or of the admin
+	// operation (e.g. "redrive" for re-driving a dead letter).
+	Action string `json:"action,omitempty"`
+
+	// Status is the HTTP status code of the result.
+	Status  int    `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Changes are the paths of the fields changed by the request.
+	Changes []string `json:"changes,omitempty"`
+}
+
+// NewAuditEntry returns an entry for the given user performing verb on the
+// object with the given key.
+func NewAuditEntry(
+	user UserInfo,
+	verb Verb,
+	key hz.ObjectKeyer,
+) AuditEntry {
+	return AuditEntry{
+		Time:      time.Now().UTC(),
+		User:      user.Identity(),
+		Groups:    user.Groups,
+		Verb:      verb,
+		Group:     key.ObjectGroup(),
+		Version:   key.ObjectVersion(),
+		Kind:      key.ObjectKind(),
+		Namespace: key.ObjectNamespace(),
+		Name:      key.ObjectName(),
+	}
+}
+
+// WithResult sets the result of the request on the entry.
+// If err is not nil its status and message are used instead of status.
+func (e AuditEntry) WithResult(status int, err error) AuditEntry {
+	e.Status = status
+	if err != nil {
+		e.Status = http.StatusInternalServerError
+		e.Message = err.Error()
+		var hErr *hz.Error
+		if errors.As(err, &hErr) {
+			e.Status = hErr.Status
+			e.Message = hErr.Message
+		}
+	}
+	return e
+}
+
+func (e AuditEntry) ObjectKey() hz.ObjectKey {
+	return hz.ObjectKey{
+		Group:     e.Group,
+		Version:   e.Version,
+		Kind:      e.Kind,
+		Namespace: e.Namespace,
+		Name:      e.Name,
+	}
+}
+
+// AuditQuery filters the entries returned from the audit log.
+// Empty fields match all entries.
+type AuditQuery struct {
+	User      string    `json:"user,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+	Until     time.Time `json:"until,omitempty"`
+	// Limit is the maximum number of entries to return, up to
+	// [MaxAuditQueryLimit] (the default).
+	// The most recent entries are returned.
+	Limit int `json:"limit,omitempty"`
+}
+
+func (q AuditQuery) match(e AuditEntry) bool {
+	if q.User != "" && q.User != e.User {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	return true
+}
+
+// Audit is the audit log of mutating API requests.
+// Entries are stored in a JetStream stream, with a subject per namespace.
+type Audit struct {
+	Conn *nats.Conn
+	// RBAC is used to filter the entries a user is allowed to read.
+	RBAC     *RBAC
+	Sessions *Sessions
+
+	MaxAge time.Duration
+
+	js   jetstream.JetStream
+	subs []*nats.Subscription
+}
+
+func (a *Audit) Start(ctx context.Context) error {
+	js, err := jetstream.New(a.Conn)
+	if err != nil {
+		return fmt.Errorf("new jetstream: %w", err)
+	}
+	a.js = js
+	if _, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        streamAudit,
+		Description: "Audit log of horizon API requests.",
+		Subjects:    []string{subjectAuditAll},
+		MaxAge:      a.MaxAge,
+	}); err != nil {
+		return fmt.Errorf("create audit stream %q: %w", streamAudit, err)
+	}
+	for _, subject := range []string{
+		SubjectAPIAuditQuery,
+		SubjectInternalAuditQuery,
+	} {
+		isAPI := subject == SubjectAPIAuditQuery
+		sub, err := a.Conn.QueueSubscribe(
+			subject,
+			"audit",
+			func(msg *nats.Msg) {
+				go a.handleQueryMsg(ctx, msg, isAPI)
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("subscribing %q: %w", subject, err)
+		}
+		a.subs = append(a.subs, sub)
+	}
+	return nil
+}
+
+func (a *Audit) Close() error {
+	var errs error
+	for _, sub := range a.subs {
+		errs = errors.Join(errs, sub.Unsubscribe())
+	}
+	return errs
+}
+
+// Record writes the entry to the audit log.
+// Failing to record an entry is logged but does not fail the request.
+func (a *Audit) Record(ctx context.Context, entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("marshalling audit entry", "error", err)
+		return
+	}
+	subject := fmt.Sprintf(subjectAudit, auditToken(entry.Namespace))
+	if _, err := a.js.Publish(ctx, subject, data); err != nil {
+		slog.Error("publishing audit entry", "error", err)
+	}
+}
+
+// Query returns the entries of the audit log that match the query, oldest
+// first.
+//
+// If allow is not nil, only the entries it allows are returned and count
+// towards the limit (e.g. the entries a user can read).
+//
+// The stream is read backwards, in batches of sequences, so that only the
+// most recent matching entries (up to the limit) are read into memory.
+func (a *Audit) Query(
+	ctx context.Context,
+	query AuditQuery,
+	allow func(AuditEntry) bool,
+) ([]AuditEntry, error) {
+	limit := query.Limit
+	if limit <= 0 || limit > MaxAuditQueryLimit {
+		limit = MaxAuditQueryLimit
+	}
+	subject := subjectAuditAll
+	if query.Namespace != "" {
+		subject = fmt.Sprintf(subjectAudit, auditToken(query.Namespace))
+	}
+	stream, err := a.js.Stream(ctx, streamAudit)
+	if err != nil {
+		return nil, fmt.Errorf("getting stream: %w", err)
+	}
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting stream info: %w", err)
+	}
+	first, last := info.State.FirstSeq, info.State.LastSeq
+	// Collect the entries newest first, and reverse them at the end.
+	entries := []AuditEntry{}
+	for end := last; end > 0 && end >= first; {
+		start := first
+		if end-first >= auditQueryBatch {
+			start = end - auditQueryBatch + 1
+		}
+		batch, done, err := a.queryRange(
+			ctx,
+			subject,
+			start,
+			end,
+			query,
+			allow,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(batch) - 1; i >= 0 && len(entries) < limit; i-- {
+			entries = append(entries, batch[i])
+		}
+		if done || len(entries) == limit {
+			break
+		}
+		end = start - 1
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// queryRange returns the entries matching the query (and allowed by allow, if
+// not nil) with stream sequences from start to end (inclusive), oldest first.
+// done is true if the range has entries before the query's since time, which
+// means that earlier ranges have no matching entries.
+func (a *Audit) queryRange(
+	ctx context.Context,
+	subject string,
+	start uint64,
+	end uint64,
+	query AuditQuery,
+	allow func(AuditEntry) bool,
+) ([]AuditEntry, bool, error) {
+	cfg := jetstream.OrderedConsumerConfig{
+		FilterSubjects: []string{subject},
+		DeliverPolicy:  jetstream.DeliverByStartSequencePolicy,
+		OptStartSeq:    start,
+	}
+	con, err := a.js.OrderedConsumer(ctx, streamAudit, cfg)
+	if err != nil {
+		return nil, false, fmt.Errorf("creating consumer: %w", err)
+	}
+	info, err := con.Info(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("getting consumer info: %w", err)
+	}
+	entries := []AuditEntry{}
+	if info.NumPending == 0 {
+		return entries, false, nil
+	}
+	iter, err := con.Messages()
+	if err != nil {
+		return nil, false, fmt.Errorf("consuming messages: %w", err)
+	}
+	defer iter.Stop()
+	done := false
+	for {
+		msg, err := iter.Next()
+		if err != nil {
+			return nil, false, fmt.Errorf("next message: %w", err)
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return nil, false, fmt.Errorf("message metadata: %w", err)
+		}
+		if meta.Sequence.Stream > end {
+			break
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(msg.Data(), &entry); err != nil {
+			return nil, false, fmt.Errorf("unmarshalling entry: %w", err)
+		}
+		if !query.Since.IsZero() && entry.Time.Before(query.Since) {
+			done = true
+		}
+		// Entries are ordered by time, so stop once past the end.
+		if !query.Until.IsZero() && entry.Time.After(query.Until) {
+			break
+		}
+		if query.match(entry) && (allow == nil || allow(entry)) {
+			entries = append(entries, entry)
+		}
+		if meta.NumPending == 0 || meta.Sequence.Stream == end {
+			break
+		}
+	}
+	return entries, done, nil
+}
+
+func (a *Audit) handleQueryMsg(
+	ctx context.Context,
+	msg *nats.Msg,
+	isAPI bool,
+) {
+	var query AuditQuery
+	if err := json.Unmarshal(msg.Data, &query); err != nil {
+		_ = hz.RespondError(msg, &hz.Error{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("unmarshalling query: %s", err.Error()),
+		})
+		return
+	}
+	var allow func(AuditEntry) bool
+	session := msg.Header.Get(hz.HeaderAuthorization)
+	if isAPI || session != "" {
+		user, err := a.Sessions.Get(ctx, session)
+		if err != nil {
+			_ = hz.RespondError(msg, err)
+			return
+		}
+		// Users can only see entries for objects they can read.
+		allow = func(e AuditEntry) bool {
+			return a.RBAC.Check(ctx, Request{
+				Subject: RequestSubject{Groups: user.Groups},
+				Verb:    VerbRead,
+				Object:  e.ObjectKey(),
+			})
+		}
+	}
+	entries, err := a.Query(ctx, query, allow)
+	if err != nil {
+		_ = hz.RespondError(msg, &hz.Error{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("querying audit log: %s", err.Error()),
+		})
+		return
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		_ = hz.RespondError(msg, &hz.Error{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("marshalling entries: %s", err.Error()),
+		})
+		return
+	}
+	_ = hz.RespondOK(msg, data)
+}
+
+// QueryAudit queries the audit log over NATS.
+// If session is not empty, the query is made using the API subject and only
+// entries for objects the user can read are returned.
+func QueryAudit(
+	ctx context.Context,
+	conn *nats.Conn,
+	session string,
+	query AuditQuery,
+) ([]AuditEntry, error) {
+	data, err := json.Marshal(query)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling query: %w", err)
+	}
+	subject := SubjectInternalAuditQuery
+	if session != "" {
+		subject = SubjectAPIAuditQuery
+	}
+	msg := nats.NewMsg(subject)
+	msg.Header.Set(hz.HeaderAuthorization, session)
+	msg.Data = data
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	reply, err := conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return nil, hz.ErrorFromNATSErr(err)
+	}
+	if err := hz.ErrorFromNATS(reply); err != nil {
+		return nil, err
+	}
+	var entries []AuditEntry
+	if err := json.Unmarshal(reply.Data, &entries); err != nil {
+		return nil, fmt.Errorf("unmarshalling entries: %w", err)
+	}
+	return entries, nil
+}
+
+// auditToken returns a valid subject token for the namespace of an entry.
+func auditToken(namespace string) string {
+	if namespace == "" || namespace == "*" {
+		return "_"
+	}
+	return namespace
+}
+
+// ChangedFields returns the paths of the fields that differ between the JSON
+// objects before and after.
+// Read-only fields that change on every write (like the revision and managed
+// fields) are ignored.
+func ChangedFields(before []byte, after []byte) ([]string, error) {
+	var b, a map[string]interface{}
+	if before != nil {
+		if err := json.Unmarshal(before, &b); err != nil {
+			return nil, fmt.Errorf("unmarshalling before: %w", err)
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &a); err != nil {
+			return nil, fmt.Errorf("unmarshalling after: %w", err)
+		}
+	}
+	changes := []string{}
+	diffFields("", b, a, &changes)
+	changes = slices.DeleteFunc(changes, func(path string) bool {
+		return path == "metadata.revision" ||
+			strings.HasPrefix(path, "metadata.managedFields")
+	})
+	sort.Strings(changes)
+	return changes, nil
+}
+
+func diffFields(prefix string, before, after interface{}, changes *[]string) {
+	bMap, bOK := before.(map[string]interface{})
+	aMap, aOK := after.(map[string]interface{})
+	if !bOK || !aOK {
+		if !reflect.DeepEqual(before, after) {
+			*changes = append(*changes, prefix)
+		}
+		return
+	}
+	keys := map[string]struct{}{}
+	for k := range bMap {
+		keys[k] = struct{}{}
+	}
+	for k := range aMap {
+		keys[k] = struct{}{}
+	}
+	for k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+		diffFields(path, bMap[k], aMap[k], changes)
+	}
+}
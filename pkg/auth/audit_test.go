@@ -0,0 +1,173 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/auth"
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+func TestAudit(t *testing.T) {
+	ctx := context.Background()
+	ts := server.Test(t, ctx)
+
+	adminSess, err := ts.Auth.Sessions.New(ctx, auth.UserInfo{
+		Sub:    "admin",
+		Iss:    "horizon",
+		Email:  "admin@example.com",
+		Groups: []string{"admin"},
+	})
+	tu.AssertNoError(t, err)
+	otherSess, err := ts.Auth.Sessions.New(ctx, auth.UserInfo{
+		Sub: "other",
+		Iss: "horizon",
+	})
+	tu.AssertNoError(t, err)
+
+	start := time.Now()
+	role := auth.Role{
+		ObjectMeta: hz.ObjectMeta{
+			Name:      "audit",
+			Namespace: "test",
+		},
+		Spec: auth.RoleSpec{},
+	}
+	adminClient := hz.NewClient(
+		ts.Conn,
+		hz.WithClientSession(adminSess),
+		hz.WithClientManager("test"),
+	)
+	_, err = adminClient.Apply(ctx, hz.WithApplyObject(role))
+	tu.AssertNoError(t, err)
+	role.Spec.Allow = []auth.Rule{
+		{
+			Group: hz.P("*"),
+			Kind:  hz.P("*"),
+			Name:  hz.P("*"),
+			Verbs: []auth.Verb{auth.VerbRead},
+		},
+	}
+	_, err = adminClient.Apply(ctx, hz.WithApplyObject(role))
+	tu.AssertNoError(t, err)
+
+	otherClient := hz.NewClient(
+		ts.Conn,
+		hz.WithClientSession(otherSess),
+		hz.WithClientManager("test"),
+	)
+	err = otherClient.Delete(ctx, hz.WithDeleteObject(role))
+	tu.AssertErrorIs(t, err, auth.ErrForbidden)
+
+	entries, err := auth.QueryAudit(ctx, ts.Conn, adminSess, auth.AuditQuery{
+		Namespace: "test",
+		Since:     start,
+	})
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 3)
+
+	tu.AssertEqual(t, entries[0].User, "admin@example.com")
+	tu.AssertEqual(t, entries[0].Verb, auth.VerbCreate)
+	tu.AssertEqual(t, entries[0].Status, http.StatusCreated)
+
+	tu.AssertEqual(t, entries[1].Verb, auth.VerbUpdate)
+	tu.AssertEqual(t, entries[1].Status, http.StatusOK)
+	tu.AssertEqual(t, entries[1].Changes, []string{"spec.allow"})
+
+	tu.AssertEqual(t, entries[2].User, "other")
+	tu.AssertEqual(t, entries[2].Verb, auth.VerbDelete)
+	tu.AssertEqual(t, entries[2].Status, http.StatusForbidden)
+
+	// The limit keeps the most recent entries.
+	entries, err = auth.QueryAudit(ctx, ts.Conn, adminSess, auth.AuditQuery{
+		Namespace: "test",
+		Since:     start,
+		Limit:     2,
+	})
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 2)
+	tu.AssertEqual(t, entries[0].Verb, auth.VerbUpdate)
+	tu.AssertEqual(t, entries[1].Verb, auth.VerbDelete)
+
+	// Filter by user.
+	entries, err = auth.QueryAudit(ctx, ts.Conn, adminSess, auth.AuditQuery{
+		User:  "other",
+		Since: start,
+	})
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 1)
+
+	// Filter by time range.
+	entries, err = auth.QueryAudit(ctx, ts.Conn, adminSess, auth.AuditQuery{
+		Since: start,
+		Until: start,
+	})
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 0)
+
+	// Users only see entries for objects they can read.
+	entries, err = auth.QueryAudit(ctx, ts.Conn, otherSess, auth.AuditQuery{
+		Namespace: "test",
+	})
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 0)
+}
+
+func TestAuditQueryLimit(t *testing.T) {
+	ctx := context.Background()
+	ts := server.Test(t, ctx)
+
+	// Record more entries than are read in a single batch.
+	user := auth.UserInfo{Sub: "batch", Iss: "horizon"}
+	for i := 0; i < 600; i++ {
+		ts.Auth.Audit.Record(ctx, auth.NewAuditEntry(
+			user,
+			auth.VerbCreate,
+			hz.ObjectKey{
+				Group:     "core",
+				Version:   "v1",
+				Kind:      "Dummy",
+				Namespace: "batch",
+				Name:      fmt.Sprintf("entry-%d", i),
+			},
+		))
+	}
+
+	entries, err := ts.Auth.Audit.Query(ctx, auth.AuditQuery{
+		Namespace: "batch",
+		Limit:     300,
+	}, nil)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 300)
+	tu.AssertEqual(t, entries[0].Name, "entry-300")
+	tu.AssertEqual(t, entries[299].Name, "entry-599")
+
+	// Without a limit, entries up to the maximum limit are returned.
+	entries, err = ts.Auth.Audit.Query(ctx, auth.AuditQuery{
+		Namespace: "batch",
+	}, nil)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 600)
+	tu.AssertEqual(t, entries[0].Name, "entry-0")
+
+	// Only allowed entries count towards the limit.
+	even := map[string]bool{}
+	for i := 0; i < 600; i += 2 {
+		even[fmt.Sprintf("entry-%d", i)] = true
+	}
+	entries, err = ts.Auth.Audit.Query(ctx, auth.AuditQuery{
+		Namespace: "batch",
+		Limit:     200,
+	}, func(e auth.AuditEntry) bool {
+		return even[e.Name]
+	})
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(entries), 200)
+	tu.AssertEqual(t, entries[0].Name, "entry-200")
+	tu.AssertEqual(t, entries[199].Name, "entry-598")
+}
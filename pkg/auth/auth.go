@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/verifa/horizon/pkg/hz"
@@ -18,13 +19,24 @@ func WithAdminGroups(group string) Option {
 	}
 }
 
+// WithAuditMaxAge sets how long entries are kept in the audit log.
+// Zero keeps entries forever.
+func WithAuditMaxAge(d time.Duration) Option {
+	return func(o *authorizerOptions) {
+		o.auditMaxAge = d
+	}
+}
+
 type Option func(*authorizerOptions)
 
 type authorizerOptions struct {
-	adminGroup string
+	adminGroup  string
+	auditMaxAge time.Duration
 }
 
-var defaultAuthorizerOptions = authorizerOptions{}
+var defaultAuthorizerOptions = authorizerOptions{
+	auditMaxAge: time.Hour * 24 * 90,
+}
 
 func Start(
 	ctx context.Context,
@@ -46,6 +58,7 @@ type Auth struct {
 
 	Sessions *Sessions
 	RBAC     *RBAC
+	Audit    *Audit
 
 	controllers []*hz.Controller
 }
@@ -104,11 +117,25 @@ func (a *Auth) Start(
 		return fmt.Errorf("starting rbac: %w", err)
 	}
 	a.RBAC = &rbac
+
+	audit := Audit{
+		Conn:     a.Conn,
+		RBAC:     &rbac,
+		Sessions: &sessions,
+		MaxAge:   ao.auditMaxAge,
+	}
+	if err := audit.Start(ctx); err != nil {
+		return fmt.Errorf("starting audit: %w", err)
+	}
+	a.Audit = &audit
 	return nil
 }
 
 func (a *Auth) Close() error {
 	var errs error
+	if a.Audit != nil {
+		errs = errors.Join(errs, a.Audit.Close())
+	}
 	if a.RBAC != nil {
 		errs = errors.Join(errs, a.RBAC.Close())
 	}
@@ -128,9 +155,19 @@ func (a *Auth) Check(
 	ctx context.Context,
 	req CheckRequest,
 ) (bool, error) {
+	_, ok, err := a.Authorize(ctx, req)
+	return ok, err
+}
+
+// Authorize is like [Auth.Check] but also returns the user of the session, for
+// example to record in the audit log.
+func (a *Auth) Authorize(
+	ctx context.Context,
+	req CheckRequest,
+) (UserInfo, bool, error) {
 	user, err := a.Sessions.Get(ctx, req.Session)
 	if err != nil {
-		return false, err
+		return UserInfo{}, false, err
 	}
 	checkRequest := Request{
 		Subject: RequestSubject{
@@ -141,7 +178,7 @@ func (a *Auth) Check(
 	}
 	ok := a.RBAC.Check(ctx, checkRequest)
 	slog.Info("checking", "checkRequest", checkRequest, "ok", ok)
-	return ok, nil
+	return user, ok, nil
 }
 
 // Verb is implied (read).
@@ -8,3 +8,15 @@ type UserInfo struct {
 	Groups  []string `json:"groups"`
 	Picture string   `json:"picture"`
 }
+
+// Identity returns a human readable identifier for the user.
+func (u UserInfo) Identity() string {
+	switch {
+	case u.Email != "":
+		return u.Email
+	case u.Name != "":
+		return u.Name
+	default:
+		return u.Sub
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/hz"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // NATS headers that are not exported and hence we re-define them here
@@ -94,34 +96,76 @@ func (b *Broker) handleAPIMessage(ctx context.Context, msg *nats.Msg) {
 		Name:      tokens[hz.SubjectInternalBrokerIndexName],
 	}
 
-	ok, err := b.Auth.Check(ctx, auth.CheckRequest{
+	action := tokens[hz.SubjectInternalBrokerIndexAction]
+	user, ok, err := b.Auth.Authorize(ctx, auth.CheckRequest{
 		Session: msg.Header.Get(hz.HeaderAuthorization),
 		Verb:    auth.VerbRun,
 		Object:  key,
 	})
 	if err != nil {
+		b.audit(ctx, user, key, action, -1, err)
 		_ = hz.RespondError(msg, err)
 		return
 	}
 	if !ok {
-		_ = hz.RespondError(msg, &hz.Error{
-			Status:  http.StatusForbidden,
-			Message: "forbidden",
-		})
+		b.audit(ctx, user, key, action, -1, auth.ErrForbidden)
+		_ = hz.RespondError(msg, auth.ErrForbidden)
 		return
 	}
 
-	b.handleInternalMessage(ctx, msg)
+	reply, err := b.run(ctx, msg)
+	if err != nil {
+		b.audit(ctx, user, key, action, -1, err)
+		_ = hz.RespondError(msg, err)
+		return
+	}
+	status, _ := strconv.Atoi(reply.Header.Get(hz.HeaderStatus))
+	b.audit(ctx, user, key, action, status, hz.ErrorFromNATS(reply))
+	_ = msg.RespondMsg(reply)
+}
+
+// audit records a run request in the audit log.
+func (b *Broker) audit(
+	ctx context.Context,
+	user auth.UserInfo,
+	key hz.ObjectKey,
+	action string,
+	status int,
+	err error,
+) {
+	if b.Auth.Audit == nil {
+		return
+	}
+	entry := auth.NewAuditEntry(user, auth.VerbRun, key).
+		WithResult(status, err)
+	entry.Action = action
+	b.Auth.Audit.Record(ctx, entry)
 }
 
 func (b *Broker) handleInternalMessage(ctx context.Context, msg *nats.Msg) {
+	reply, err := b.run(ctx, msg)
+	if err != nil {
+		_ = hz.RespondError(msg, err)
+		return
+	}
+	// Forward the reply message onto the original caller.
+	_ = msg.RespondMsg(reply)
+}
+
+// run runs the action requested in msg and returns the reply from the actor.
+func (b *Broker) run(
+	ctx context.Context,
+	msg *nats.Msg,
+) (reply *nats.Msg, err error) {
+	defer func(start time.Time) {
+		observeRun(start, reply, err)
+	}(time.Now())
 	tokens := strings.Split(msg.Subject, ".")
 	if len(tokens) != hz.SubjectInternalBrokerLength {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status:  http.StatusBadRequest,
 			Message: fmt.Sprintf("invalid subject: %s", msg.Subject),
-		})
-		return
+		}
 	}
 
 	key := hz.ObjectKey{
@@ -133,13 +177,24 @@ func (b *Broker) handleInternalMessage(ctx context.Context, msg *nats.Msg) {
 	}
 	action := tokens[hz.SubjectInternalBrokerIndexAction]
 
+	ctx, span := hz.Tracer().Start(
+		hz.TraceExtract(ctx, msg.Header),
+		"broker.run",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(hz.TraceAttributes(key)...),
+		trace.WithAttributes(attribute.String("hz.action", action)),
+	)
+	defer func() {
+		hz.TraceError(span, err)
+		span.End()
+	}()
+
 	var runMsg hz.RunMsg
 	if err := json.Unmarshal(msg.Data, &runMsg); err != nil {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status:  http.StatusBadRequest,
 			Message: fmt.Sprintf("unmarshalling run message: %s", err.Error()),
-		})
-		return
+		}
 	}
 
 	adMsg := hz.AdvertiseMsg{
@@ -147,11 +202,10 @@ func (b *Broker) handleInternalMessage(ctx context.Context, msg *nats.Msg) {
 	}
 	bAdMsg, err := json.Marshal(adMsg)
 	if err != nil {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status:  http.StatusInternalServerError,
 			Message: fmt.Sprintf("marshal advertise message: %s", err.Error()),
-		})
-		return
+		}
 	}
 
 	advertiseSubject := fmt.Sprintf(
@@ -168,11 +222,10 @@ func (b *Broker) handleInternalMessage(ctx context.Context, msg *nats.Msg) {
 	msgCh := make(chan *nats.Msg, 100)
 	sub, err := b.Conn.ChanSubscribe(inbox, msgCh)
 	if err != nil {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status:  http.StatusInternalServerError,
 			Message: fmt.Sprintf("subscribing to %s: %s", inbox, err.Error()),
-		})
-		return
+		}
 	}
 	defer func() {
 		_ = sub.Unsubscribe()
@@ -185,15 +238,14 @@ func (b *Broker) handleInternalMessage(ctx context.Context, msg *nats.Msg) {
 	// If there are no responders (i.e. subscribers) for the request,
 	// nats automatically adds one reply with a "Status" header of 503.
 	if err := b.Conn.PublishRequest(advertiseSubject, inbox, bAdMsg); err != nil {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status: http.StatusInternalServerError,
 			Message: fmt.Sprintf(
 				"publishing request to %s: %s",
 				advertiseSubject,
 				err.Error(),
 			),
-		})
-		return
+		}
 	}
 	// processMessages waits for either the first message (from an actor) or a
 	// timeout, and then returns the reply.
@@ -209,44 +261,41 @@ func (b *Broker) handleInternalMessage(ctx context.Context, msg *nats.Msg) {
 	}
 	adReply := processMessages()
 	if adReply == nil {
-		_ = hz.RespondError(msg, &hz.Error{
+		metricTimeouts.Inc()
+		return nil, &hz.Error{
 			Status:  http.StatusServiceUnavailable,
 			Message: "no actors responded to advertise request",
-		})
-		return
+		}
 	}
 	// Check for any headers added by nats.
 	if adReply.Header.Get(natsHeaderStatus) == natsHeaderStatusNoResponders {
-		_ = hz.RespondError(msg, &hz.Error{
+		metricNoResponders.Inc()
+		return nil, &hz.Error{
 			Status:  http.StatusServiceUnavailable,
 			Message: "no actors responded to advertise request",
-		})
-		return
+		}
 	}
 
 	// Check the status header set/expected by horizon.
 	status, err := strconv.Atoi(adReply.Header.Get(hz.HeaderStatus))
 	if err != nil {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status:  http.StatusInternalServerError,
 			Message: fmt.Sprintf("parsing status header: %s", err.Error()),
-		})
-		return
+		}
 	}
 	if status != http.StatusOK {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status:  status,
 			Message: string(adReply.Data),
-		})
-		return
+		}
 	}
 	id, err := uuid.ParseBytes(adReply.Data)
 	if err != nil {
-		_ = hz.RespondError(msg, &hz.Error{
+		return nil, &hz.Error{
 			Status:  http.StatusInternalServerError,
 			Message: fmt.Sprintf("parsing actor uuid: %s", err.Error()),
-		})
-		return
+		}
 	}
 
 	runSubject := fmt.Sprintf(
@@ -265,32 +314,33 @@ func (b *Broker) handleInternalMessage(ctx context.Context, msg *nats.Msg) {
 	timeout := runMsg.Timeout - (time.Second * 2)
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	runReply, err := b.Conn.RequestWithContext(ctx, runSubject, runMsg.Data)
+	runReq := nats.NewMsg(runSubject)
+	runReq.Data = runMsg.Data
+	hz.TraceInject(ctx, runReq.Header)
+	runReply, err := b.Conn.RequestMsgWithContext(ctx, runReq)
 	if err != nil {
 		switch {
 		case errors.Is(err, nats.ErrNoResponders):
-			_ = hz.RespondError(msg, &hz.Error{
+			metricNoResponders.Inc()
+			return nil, &hz.Error{
 				Status:  http.StatusServiceUnavailable,
 				Message: "actor id: " + id.String(),
-			})
-			return
+			}
 		case errors.Is(err, context.DeadlineExceeded),
 			errors.Is(err, nats.ErrTimeout):
-			_ = hz.RespondError(msg, &hz.Error{
+			metricTimeouts.Inc()
+			return nil, &hz.Error{
 				Status:  http.StatusRequestTimeout,
 				Message: "actor id: " + id.String(),
-			})
-			return
+			}
 		default:
-			_ = hz.RespondError(msg, &hz.Error{
+			return nil, &hz.Error{
 				Status:  http.StatusInternalServerError,
 				Message: "actor id: " + id.String() + ": " + err.Error(),
-			})
-			return
+			}
 		}
 	}
-	// Forward the reply message onto the original caller.
-	_ = msg.RespondMsg(runReply)
+	return runReply, nil
 }
 
 func (b *Broker) Stop() error {
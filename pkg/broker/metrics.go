@@ -0,0 +1,61 @@
+package broker
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+var (
+	metricRunDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "horizon",
+			Subsystem: "broker",
+			Name:      "run_duration_seconds",
+			Help:      "Latency of broker run requests by status.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"status"},
+	)
+	metricNoResponders = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "horizon",
+			Subsystem: "broker",
+			Name:      "no_responders_total",
+			Help:      "Total number of run requests with no responding actor.",
+		},
+	)
+	metricTimeouts = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "horizon",
+			Subsystem: "broker",
+			Name:      "timeouts_total",
+			Help:      "Total number of run requests that timed out.",
+		},
+	)
+)
+
+// observeRun records the result of a run request that started at start.
+func observeRun(start time.Time, reply *nats.Msg, err error) {
+	status := http.StatusOK
+	switch {
+	case err != nil:
+		status = http.StatusInternalServerError
+		var hErr *hz.Error
+		if errors.As(err, &hErr) {
+			status = hErr.Status
+		}
+	case reply != nil && reply.Header.Get(hz.HeaderStatus) != "":
+		if s, err := strconv.Atoi(reply.Header.Get(hz.HeaderStatus)); err == nil {
+			status = s
+		}
+	}
+	metricRunDuration.WithLabelValues(strconv.Itoa(status)).
+		Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+const ObjectKindEvent = "Event"
+
+var _ hz.Objecter = (*Event)(nil)
+
+// Event records something that happened to an object, e.g. a reconcile that
+// failed.
+// Events are recorded with [hz.EventRecorder], and deleted by the
+// [EventReconciler] once they expire.
+type Event struct {
+	hz.ObjectMeta `json:"metadata" cue:""`
+
+	// Type is either "Normal" or "Warning".
+	Type string `json:"type" cue:"\"Normal\" | \"Warning\""`
+	// Reason is a short CamelCase reason for the event.
+	Reason string `json:"reason"`
+	// Message is a human readable description of the event.
+	Message string `json:"message,omitempty" cue:",opt"`
+	// InvolvedObject is the object that the event is about.
+	InvolvedObject ObjectReference `json:"involvedObject"`
+	// Source is the component that recorded the event.
+	Source string `json:"source,omitempty" cue:",opt"`
+	// Count is the number of times the event occurred.
+	Count int `json:"count"`
+	// FirstTimestamp is when the event first occurred.
+	FirstTimestamp *hz.Time `json:"firstTimestamp,omitempty"      cue:",opt"`
+	// LastTimestamp is when the event last occurred.
+	LastTimestamp *hz.Time `json:"lastTimestamp,omitempty"       cue:",opt"`
+	// ExpirationTimestamp is when the event is deleted.
+	ExpirationTimestamp *hz.Time `json:"expirationTimestamp,omitempty" cue:",opt"`
+}
+
+func (e Event) ObjectGroup() string {
+	return ObjectGroup
+}
+
+func (e Event) ObjectVersion() string {
+	return ObjectVersion
+}
+
+func (e Event) ObjectKind() string {
+	return ObjectKindEvent
+}
+
+// IsFor returns true if the event is about the object, in any version.
+func (e Event) IsFor(obj hz.ObjectKeyer) bool {
+	return e.InvolvedObject.Group == obj.ObjectGroup() &&
+		e.InvolvedObject.Kind == obj.ObjectKind() &&
+		e.InvolvedObject.Namespace == obj.ObjectNamespace() &&
+		e.InvolvedObject.Name == obj.ObjectName()
+}
+
+// ObjectReference refers to an object in a specific version.
+type ObjectReference struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty" cue:",opt"`
+	Name      string `json:"name"`
+}
+
+var _ hz.Reconciler = (*EventReconciler)(nil)
+
+// EventReconciler deletes events once they expire.
+type EventReconciler struct {
+	Client hz.Client
+}
+
+func (r *EventReconciler) Reconcile(
+	ctx context.Context,
+	req hz.Request,
+) (hz.Result, error) {
+	client := hz.ObjectClient[Event]{Client: r.Client}
+	event, err := client.Get(ctx, hz.WithGetKey(req.Key))
+	if err != nil {
+		return hz.Result{}, hz.IgnoreNotFound(err)
+	}
+	if event.DeletionTimestamp != nil || event.ExpirationTimestamp == nil {
+		return hz.Result{}, nil
+	}
+	if remaining := time.Until(event.ExpirationTimestamp.Time); remaining > 0 {
+		return hz.Result{RequeueAfter: remaining}, nil
+	}
+	if err := client.Delete(ctx, event); err != nil {
+		return hz.Result{}, fmt.Errorf("deleting expired event: %w", err)
+	}
+	return hz.Result{}, nil
+}
+
+// ObjectEvents returns the events about the object, with the most recent
+// first.
+func ObjectEvents(events []Event, obj hz.ObjectKeyer) []Event {
+	var objEvents []Event
+	for _, event := range events {
+		if event.IsFor(obj) {
+			objEvents = append(objEvents, event)
+		}
+	}
+	slices.SortStableFunc(objEvents, func(a, b Event) int {
+		return b.LastSeen().Compare(a.LastSeen())
+	})
+	return objEvents
+}
+
+// LastSeen returns when the event last occurred, or the zero time if it is
+// not set.
+func (e Event) LastSeen() time.Time {
+	if e.LastTimestamp == nil {
+		return time.Time{}
+	}
+	return e.LastTimestamp.Time
+}
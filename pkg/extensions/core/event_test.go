@@ -0,0 +1,104 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/extensions/core"
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	ts := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ts.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	eventClient := hz.ObjectClient[core.Event]{Client: client}
+	secret := core.Secret{
+		ObjectMeta: hz.ObjectMeta{
+			Name:      "my-secret",
+			Namespace: "test",
+		},
+	}
+	recorder := &hz.EventRecorder{
+		Client: client,
+		Source: "test",
+	}
+	err := recorder.Event(ctx, secret, hz.EventTypeNormal, "Rotated", "rotated")
+	tu.AssertNoError(t, err)
+	err = recorder.Event(ctx, secret, hz.EventTypeNormal, "Rotated", "rotated")
+	tu.AssertNoError(t, err)
+
+	// Recording the same event again increments its count.
+	events, err := eventClient.List(ctx, hz.WithListKey(hz.ObjectKey{
+		Namespace: "test",
+	}))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, 1, len(events))
+	event := events[0]
+	tu.AssertTrue(t, event.IsFor(secret), "expected event for secret")
+	tu.AssertEqual(t, hz.EventTypeNormal, event.Type)
+	tu.AssertEqual(t, "Rotated", event.Reason)
+	tu.AssertEqual(t, "rotated", event.Message)
+	tu.AssertEqual(t, "test", event.Source)
+	tu.AssertEqual(t, 2, event.Count)
+	tu.AssertTrue(
+		t,
+		!event.LastTimestamp.Before(event.FirstTimestamp.Time),
+		"expected last timestamp after first timestamp",
+	)
+	tu.AssertEqual(
+		t,
+		event.LastTimestamp.Add(hz.DefaultEventTTL),
+		event.ExpirationTimestamp.Time,
+	)
+
+	// Events are deleted once they expire.
+	recorder.TTL = time.Second
+	err = recorder.Eventf(
+		ctx,
+		secret,
+		hz.EventTypeWarning,
+		"RotateFailed",
+		"rotating %s",
+		secret.Name,
+	)
+	tu.AssertNoError(t, err)
+	expiredKey := hz.ObjectKey{
+		Group:     core.ObjectGroup,
+		Version:   core.ObjectVersion,
+		Kind:      core.ObjectKindEvent,
+		Namespace: "test",
+		Name: hz.EventName(
+			secret,
+			hz.EventTypeWarning,
+			"RotateFailed",
+			"rotating my-secret",
+		),
+	}
+	_, err = eventClient.Get(ctx, hz.WithGetKey(expiredKey))
+	tu.AssertNoError(t, err)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		_, err := eventClient.Get(ctx, hz.WithGetKey(expiredKey))
+		if errors.Is(err, hz.ErrNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for event to expire")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Invalid events are not recorded.
+	err = recorder.Event(ctx, secret, "Unknown", "Rotated", "rotated")
+	tu.AssertTrue(t, err != nil, "expected error for invalid event type")
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nats-io/nats.go"
+	"github.com/verifa/horizon/pkg/auth"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+type AuditHandler struct {
+	Conn *nats.Conn
+}
+
+func (a *AuditHandler) router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", a.get)
+	return r
+}
+
+func (a *AuditHandler) get(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := auth.AuditQuery{
+		User:      q.Get("user"),
+		Namespace: q.Get("namespace"),
+	}
+	parseTime := func(name string, t *time.Time) bool {
+		if q.Get(name) == "" {
+			return true
+		}
+		var err error
+		*t, err = time.Parse(time.RFC3339, q.Get(name))
+		if err != nil {
+			http.Error(
+				w,
+				"invalid "+name+": "+err.Error(),
+				http.StatusBadRequest,
+			)
+			return false
+		}
+		return true
+	}
+	if !parseTime("since", &query.Since) || !parseTime("until", &query.Until) {
+		return
+	}
+	if limit := q.Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			http.Error(
+				w,
+				"invalid limit: "+err.Error(),
+				http.StatusBadRequest,
+			)
+			return
+		}
+	}
+	session := hz.SessionFromRequest(r)
+	if session == "" {
+		httpError(w, auth.ErrAuthenticationMissing)
+		return
+	}
+	entries, err := auth.QueryAudit(r.Context(), a.Conn, session, query)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(entries)
+}
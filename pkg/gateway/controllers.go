@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nats-io/nats.go"
+	"github.com/verifa/horizon/pkg/auth"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+// ControllersHandler lists the controllers in the controller registry, and
+// the objects that controllers stopped retrying (dead letters).
+// They span all namespaces, so only admins can list them.
+type ControllersHandler struct {
+	Middleware chi.Middlewares
+	Conn       *nats.Conn
+	Auth       *auth.Auth
+}
+
+func (h *ControllersHandler) router() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(h.middlewareAdmin)
+	r.Get("/", h.get)
+	return r
+}
+
+// deadLettersRouter lists the dead letters, and re-drives them.
+func (h *ControllersHandler) deadLettersRouter() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(h.middlewareAdmin)
+	r.Get("/", h.getDeadLetters)
+	r.Post("/{key}/redrive", h.postRedrive)
+	return r
+}
+
+// adminRouter serves the admin page for the controllers, which requires the
+// user to be logged in.
+func (h *ControllersHandler) adminRouter() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(h.Middleware...)
+	r.Use(h.middlewareAdmin)
+	r.Get("/", h.getPage)
+	return r
+}
+
+func (h *ControllersHandler) middlewareAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := hz.SessionFromRequest(r)
+		if session == "" {
+			httpError(w, auth.ErrAuthenticationMissing)
+			return
+		}
+		user, err := h.Auth.Sessions.Get(r.Context(), session)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		if !slices.Contains(user.Groups, h.Auth.RBAC.AdminGroup) {
+			httpError(w, auth.ErrForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *ControllersHandler) get(w http.ResponseWriter, r *http.Request) {
+	statuses, err := hz.ListControllers(r.Context(), h.Conn)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+func (h *ControllersHandler) getDeadLetters(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	deadLetters, err := hz.ListDeadLetters(r.Context(), h.Conn)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(deadLetters)
+}
+
+// postRedrive re-drives a dead letter, and records it in the audit log.
+func (h *ControllersHandler) postRedrive(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	key := chi.URLParam(r, "key")
+	objKey, err := hz.ObjectKeyFromString(key)
+	if err != nil {
+		httpError(w, &hz.Error{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	user, err := h.Auth.Sessions.Get(r.Context(), hz.SessionFromRequest(r))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	err = hz.RedriveDeadLetter(r.Context(), h.Conn, key)
+	entry := auth.NewAuditEntry(user, auth.VerbUpdate, objKey).
+		WithResult(http.StatusNoContent, err)
+	entry.Action = "redrive"
+	h.Auth.Audit.Record(r.Context(), entry)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ControllersHandler) getPage(w http.ResponseWriter, r *http.Request) {
+	userInfo, ok := r.Context().Value(authContext).(auth.UserInfo)
+	if !ok {
+		http.Error(w, "no auth context", http.StatusUnauthorized)
+		return
+	}
+	statuses, err := hz.ListControllers(r.Context(), h.Conn)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	layout("Controllers", &userInfo, controllersPage(statuses)).
+		Render(r.Context(), w)
+}
@@ -71,7 +71,7 @@ func layout(title string, userInfo *auth.UserInfo, body templ.Component) templ.C
 
 // namespaceLayout is a layout for pages within an namespace.
 // It shows the sidebar with the extensions/actors installed.
-func namespaceLayout(namespace string, portals map[string]hz.Portal, body templ.Component) templ.Component {
+func namespaceLayout(namespace string, portals []hz.Portal, body templ.Component) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
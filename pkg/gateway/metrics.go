@@ -0,0 +1,17 @@
+package gateway
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricPortalProxyDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "horizon",
+		Subsystem: "gateway",
+		Name:      "portal_proxy_duration_seconds",
+		Help:      "Latency of requests proxied to portals by portal and status.",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"portal", "status"},
+)
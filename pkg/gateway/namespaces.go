@@ -2,14 +2,19 @@ package gateway
 
 import (
 	_ "embed"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
+	"slices"
 	"strings"
 
 	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/extensions/core"
 	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/internal/managedfields"
+	"sigs.k8s.io/yaml"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/nats-io/nats.go"
@@ -19,7 +24,7 @@ type NamespaceHandler struct {
 	Middleware chi.Middlewares
 	Conn       *nats.Conn
 	Auth       *auth.Auth
-	Portals    map[string]hz.Portal
+	Portals    *hz.Cache[hz.Portal]
 }
 
 func (h *NamespaceHandler) Router() *chi.Mux {
@@ -27,6 +32,7 @@ func (h *NamespaceHandler) Router() *chi.Mux {
 	r.Use(h.Middleware...)
 	r.Use(h.middlewareNamespace)
 	r.Get("/", h.getNamespace)
+	r.Get("/objects/{group}/{version}/{kind}/{name}", h.getObject)
 	r.HandleFunc("/portal/{portal}", h.servePortal)
 	r.HandleFunc("/portal/{portal}/*", h.servePortal)
 	return r
@@ -83,10 +89,82 @@ func (h *NamespaceHandler) getNamespace(
 		return
 	}
 	namespace := chi.URLParam(r, "namespace")
-	body := namespaceLayout(namespace, h.Portals, namespacePage())
+	body := namespaceLayout(namespace, h.Portals.List(), namespacePage())
 	layout("Namespace", &userInfo, body).Render(r.Context(), w)
 }
 
+func (h *NamespaceHandler) getObject(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	userInfo, ok := r.Context().Value(authContext).(auth.UserInfo)
+	if !ok {
+		http.Error(w, "no auth context", http.StatusUnauthorized)
+		return
+	}
+	namespace := chi.URLParam(r, "namespace")
+	client := hz.NewClient(h.Conn, hz.WithClientSessionFromRequest(r))
+	data, err := client.Get(r.Context(), hz.WithGetKey(hz.ObjectKey{
+		Group:     chi.URLParam(r, "group"),
+		Version:   chi.URLParam(r, "version"),
+		Kind:      chi.URLParam(r, "kind"),
+		Namespace: namespace,
+		Name:      chi.URLParam(r, "name"),
+	}))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	var object hz.GenericObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		http.Error(
+			w,
+			"decoding object: "+err.Error(),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+	// Show the managers separately, with the most recent change first.
+	managers := slices.Clone(object.ManagedFields)
+	slices.SortStableFunc(managers, func(a, b managedfields.FieldManager) int {
+		switch {
+		case a.Time == nil && b.Time == nil:
+			return 0
+		case a.Time == nil:
+			return 1
+		case b.Time == nil:
+			return -1
+		default:
+			return b.Time.Compare(*a.Time)
+		}
+	})
+	object.ManagedFields = nil
+	objectYAML, err := yaml.Marshal(object)
+	if err != nil {
+		http.Error(
+			w,
+			"encoding object: "+err.Error(),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+	// Show the events about the object, if the user can read them.
+	eventClient := hz.ObjectClient[core.Event]{Client: client}
+	events, err := eventClient.List(r.Context(), hz.WithListKey(hz.ObjectKey{
+		Namespace: namespace,
+	}))
+	if err != nil {
+		slog.Warn("listing object events", "error", err)
+	}
+	events = core.ObjectEvents(events, object)
+	body := namespaceLayout(
+		namespace,
+		h.Portals.List(),
+		objectPage(object, string(objectYAML), managers, events),
+	)
+	layout("Object", &userInfo, body).Render(r.Context(), w)
+}
+
 func (h *NamespaceHandler) servePortal(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -126,6 +204,7 @@ func (h *NamespaceHandler) servePortal(
 			conn:      h.Conn,
 			subject:   fmt.Sprintf(hz.SubjectPortalRender, portal),
 			namespace: namespace,
+			portal:    portal,
 		}
 		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 			// NOTE: this only handles errors returned from the proxy.
@@ -150,7 +229,7 @@ func (h *NamespaceHandler) servePortal(
 	}
 	body := namespaceLayout(
 		namespace,
-		h.Portals,
+		h.Portals.List(),
 		portalProxy(namespace, portal, subpath),
 	)
 	layout(portal, &userInfo, body).Render(r.Context(), w)
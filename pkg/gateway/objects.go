@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/nats-io/nats.go"
@@ -20,6 +21,10 @@ func (o *ObjectsHandler) router() *chi.Mux {
 	r.Post("/", o.create)
 	r.Patch("/", o.apply)
 	r.Delete("/{group}/{version}/{kind}/{namespace}/{name}", o.delete)
+	r.Post(
+		"/{group}/{version}/{kind}/{namespace}/{name}/migrate",
+		o.migrate,
+	)
 	return r
 }
 
@@ -80,7 +85,12 @@ func (o *ObjectsHandler) apply(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	if _, err := client.Apply(r.Context(), hz.WithApplyObject(obj)); err != nil {
+	force, _ := strconv.ParseBool(r.Header.Get(hz.HeaderApplyForceConflicts))
+	if _, err := client.Apply(
+		r.Context(),
+		hz.WithApplyObject(obj),
+		hz.WithApplyForce(force),
+	); err != nil {
 		httpError(w, err)
 		return
 	}
@@ -102,3 +112,24 @@ func (o *ObjectsHandler) delete(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (o *ObjectsHandler) migrate(w http.ResponseWriter, r *http.Request) {
+	key := hz.ObjectKey{
+		Group:     chi.URLParam(r, "group"),
+		Version:   chi.URLParam(r, "version"),
+		Kind:      chi.URLParam(r, "kind"),
+		Namespace: chi.URLParam(r, "namespace"),
+		Name:      chi.URLParam(r, "name"),
+	}
+	client := hz.NewClient(o.Conn, hz.WithClientSessionFromRequest(r))
+	result, err := client.Migrate(r.Context(), hz.WithMigrateKey(key))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	if result == hz.MigrateOpResultNoop {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
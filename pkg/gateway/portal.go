@@ -6,9 +6,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/nats-io/nats.go"
+	"github.com/verifa/horizon/pkg/hz"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const HeaderNamespace = "Hz-Namespace"
@@ -24,15 +28,43 @@ type NATSHTTPTransport struct {
 	conn      *nats.Conn
 	subject   string
 	namespace string
+	portal    string
 }
 
-func (t *NATSHTTPTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+func (t *NATSHTTPTransport) RoundTrip(
+	r *http.Request,
+) (resp *http.Response, err error) {
+	defer func(start time.Time) {
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		metricPortalProxyDuration.WithLabelValues(t.portal, status).
+			Observe(time.Since(start).Seconds())
+	}(time.Now())
+	ctx, span := hz.Tracer().Start(
+		r.Context(),
+		"gateway.portal",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("hz.portal", t.portal),
+			attribute.String("hz.namespace", t.namespace),
+		),
+	)
+	defer func() {
+		hz.TraceError(span, err)
+		span.End()
+	}()
+	// Propagate the trace context to the portal in the HTTP request headers.
+	r = r.Clone(ctx)
+	hz.TraceInjectHTTP(ctx, r.Header)
+
 	reqBuf := bytes.Buffer{}
 	if err := r.Write(&reqBuf); err != nil {
 		return nil, fmt.Errorf("transport writing request: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), time.Second)
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
 	reply, err := t.conn.RequestWithContext(
 		ctx,
@@ -46,7 +78,7 @@ func (t *NATSHTTPTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 			err,
 		)
 	}
-	resp, err := http.ReadResponse(
+	resp, err = http.ReadResponse(
 		bufio.NewReader(bytes.NewBuffer(reply.Data)),
 		r,
 	)
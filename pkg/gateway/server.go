@@ -3,7 +3,6 @@ package gateway
 import (
 	"context"
 	_ "embed"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -22,6 +21,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/httplog/v2"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 //go:embed dist/htmx-1.9.8.min.js
@@ -91,9 +91,8 @@ func Start(
 	opts ...ServerOption,
 ) (*Server, error) {
 	s := Server{
-		Conn:    conn,
-		Auth:    auth,
-		portals: make(map[string]hz.Portal),
+		Conn: conn,
+		Auth: auth,
 	}
 
 	if err := s.start(ctx, opts...); err != nil {
@@ -108,8 +107,7 @@ type Server struct {
 	HTTPServer *http.Server
 	dummyOIDC  *dummyoidc.Server
 
-	portals map[string]hz.Portal
-	watcher *hz.Watcher
+	portals *hz.Cache[hz.Portal]
 }
 
 func (s *Server) start(
@@ -120,16 +118,11 @@ func (s *Server) start(
 	for _, o := range opts {
 		o(&opt)
 	}
-	watcher, err := hz.StartWatcher(
-		ctx,
-		s.Conn,
-		hz.WithWatcherFor(hz.Portal{}),
-		hz.WithWatcherFn(s.handlePortalEvent),
-	)
+	portals, err := hz.StartCache[hz.Portal](ctx, s.Conn)
 	if err != nil {
-		return fmt.Errorf("starting portal watcher: %w", err)
+		return fmt.Errorf("starting portal cache: %w", err)
 	}
-	s.watcher = watcher
+	s.portals = portals
 
 	logger := httplog.NewLogger("horizon", httplog.Options{
 		JSON:             false,
@@ -241,6 +234,22 @@ func (s *Server) start(
 	objRouter := objHandler.router()
 	r.Mount("/v1/objects", objRouter)
 
+	auditHandler := AuditHandler{
+		Conn: s.Conn,
+	}
+	r.Mount("/v1/audit", auditHandler.router())
+
+	controllersHandler := ControllersHandler{
+		Middleware: chi.Middlewares{oidcHandler.authMiddleware},
+		Conn:       s.Conn,
+		Auth:       s.Auth,
+	}
+	r.Mount("/v1/controllers", controllersHandler.router())
+	r.Mount("/v1/deadletters", controllersHandler.deadLettersRouter())
+	r.Mount("/admin/controllers", controllersHandler.adminRouter())
+
+	r.Handle("/metrics", promhttp.Handler())
+
 	//
 	// Static files.
 	//
@@ -321,40 +330,17 @@ func (s *Server) Close() error {
 			)
 		}
 	}
-	if s.watcher != nil {
-		s.watcher.Close()
+	if s.portals != nil {
+		s.portals.Close()
 	}
 	return errs
 }
 
-func (s *Server) handlePortalEvent(
-	event hz.Event,
-) (hz.Result, error) {
-	switch event.Operation {
-	case hz.EventOperationPut:
-		var portal hz.Portal
-		if err := json.Unmarshal(event.Data, &portal); err != nil {
-			return hz.Result{}, fmt.Errorf("unmarshalling portal: %w", err)
-		}
-		s.portals[hz.KeyFromObject(event.Key)] = portal
-		return hz.Result{}, nil
-	case hz.EventOperationDelete, hz.EventOperationPurge:
-		delete(s.portals, hz.KeyFromObject(event.Key))
-	}
-	return hz.Result{}, nil
-}
-
 func (s *Server) serveLoggedOut(w http.ResponseWriter, r *http.Request) {
 	body := loggedOutPage()
 	layout("Logged Out", nil, body).Render(r.Context(), w)
 }
 
 func httpError(w http.ResponseWriter, err error) {
-	var hzErr *hz.Error
-	if errors.As(err, &hzErr) {
-		http.Error(w, hzErr.Message, hzErr.Status)
-		return
-	}
-
-	http.Error(w, err.Error(), http.StatusInternalServerError)
+	hz.WriteHTTPError(w, err)
 }
@@ -2,15 +2,18 @@ package gateway_test
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/extensions/core"
 	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/hzctl"
 	"github.com/verifa/horizon/pkg/server"
 	tu "github.com/verifa/horizon/pkg/testutil"
 )
@@ -61,3 +64,232 @@ func TestHandler(t *testing.T) {
 	tu.AssertNoError(t, err)
 	tu.AssertEqual(t, "another", ns.Name)
 }
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	ts := server.Test(t, ctx)
+	handler := ts.Gateway.HTTPServer.Handler
+
+	// Make a store request so that the store metrics have a value.
+	client := hz.NewClient(ts.Conn, hz.WithClientInternal(true))
+	_, err := client.Apply(ctx, hz.WithApplyObject(core.Namespace{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: hz.NamespaceRoot,
+			Name:      "metrics",
+		},
+	}))
+	tu.AssertNoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+	tu.AssertNoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	tu.AssertEqual(t, http.StatusOK, rec.Result().StatusCode)
+	body := rec.Body.String()
+	tu.AssertTrue(t, strings.Contains(
+		body,
+		`horizon_store_requests_total{command="apply",status="201"}`,
+	))
+	tu.AssertTrue(t, strings.Contains(
+		body,
+		"horizon_store_request_duration_seconds_bucket",
+	))
+}
+
+func TestApplyConflicts(t *testing.T) {
+	ctx := context.Background()
+	ts := server.Test(t, ctx)
+	httpServer := httptest.NewServer(ts.Gateway.HTTPServer.Handler)
+	t.Cleanup(httpServer.Close)
+
+	sess, err := ts.Auth.Sessions.New(ctx, auth.UserInfo{
+		Sub:    "test",
+		Iss:    "test",
+		Groups: []string{"admin"},
+	})
+	tu.AssertNoError(t, err)
+
+	apply := func(manager string, value string, force bool) (hz.ApplyOpResult, error) {
+		client := hzctl.Client{
+			Server:  httpServer.URL,
+			Session: sess,
+			Manager: manager,
+		}
+		data := `{
+			"apiVersion": "core/v1",
+			"kind": "Namespace",
+			"metadata": {
+				"namespace": "root",
+				"name": "conflicts",
+				"labels": {"owner": "` + value + `"}
+			}
+		}`
+		return client.Apply(
+			ctx,
+			hzctl.WithApplyData([]byte(data)),
+			hzctl.WithApplyForce(force),
+		)
+	}
+
+	_, err = apply("m1", "m1", false)
+	tu.AssertNoError(t, err)
+	result, err := apply("m2", "m2", false)
+	tu.AssertEqual(t, hz.ApplyOpResultConflict, result)
+	var hErr *hz.Error
+	tu.AssertTrue(t, errors.As(err, &hErr), "expected hz.Error")
+	tu.AssertEqual(t, http.StatusConflict, hErr.Status)
+	tu.AssertEqual(t, &hz.ErrorDetails{
+		Conflicts: []hz.ApplyConflict{
+			{Field: "metadata.labels.owner", Manager: "m1"},
+		},
+	}, hErr.Details)
+
+	_, err = apply("m2", "m2", true)
+	tu.AssertNoError(t, err)
+}
+
+func TestObjectPage(t *testing.T) {
+	ctx := context.Background()
+	ts := server.Test(t, ctx)
+	handler := ts.Gateway.HTTPServer.Handler
+
+	sess, err := ts.Auth.Sessions.New(ctx, auth.UserInfo{
+		Sub:    "test",
+		Iss:    "test",
+		Groups: []string{"admin"},
+	})
+	tu.AssertNoError(t, err)
+
+	client := hz.NewClient(
+		ts.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("m1"),
+	)
+	_, err = client.Apply(ctx, hz.WithApplyObject(core.Secret{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "page",
+		},
+		Data: core.SecretData{"key": "value"},
+	}))
+	tu.AssertNoError(t, err)
+	recorder := hz.EventRecorder{Client: client, Source: "test"}
+	err = recorder.Event(
+		ctx,
+		hz.ObjectKey{
+			Group:     core.ObjectGroup,
+			Version:   core.ObjectVersion,
+			Kind:      "Secret",
+			Namespace: "test",
+			Name:      "page",
+		},
+		hz.EventTypeWarning,
+		"RotateFailed",
+		"rotating the secret failed",
+	)
+	tu.AssertNoError(t, err)
+
+	req, err := http.NewRequest(
+		http.MethodGet,
+		"/namespaces/test/objects/core/v1/Secret/page",
+		nil,
+	)
+	tu.AssertNoError(t, err)
+	req.AddCookie(&http.Cookie{
+		Name:  hz.CookieSession,
+		Value: sess,
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	tu.AssertEqual(t, http.StatusOK, rec.Result().StatusCode)
+	body := rec.Body.String()
+	for _, exp := range []string{
+		"<td>m1</td>",
+		"<td>Apply</td>",
+		"<td>core/v1</td>",
+		"<code>data.key</code>",
+		"<td>RotateFailed</td>",
+		"<td>rotating the secret failed</td>",
+	} {
+		tu.AssertTrue(t, strings.Contains(body, exp), "missing "+exp)
+	}
+}
+
+func TestControllers(t *testing.T) {
+	ctx := context.Background()
+	ts := server.Test(t, ctx)
+	handler := ts.Gateway.HTTPServer.Handler
+
+	newSession := func(groups ...string) string {
+		sess, err := ts.Auth.Sessions.New(ctx, auth.UserInfo{
+			Sub:    "test",
+			Iss:    "test",
+			Groups: groups,
+		})
+		tu.AssertNoError(t, err)
+		return sess
+	}
+	get := func(path string, session string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		tu.AssertNoError(t, err)
+		req.AddCookie(&http.Cookie{
+			Name:  hz.CookieSession,
+			Value: session,
+		})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(httpServer.Close)
+
+	admin := newSession("admin")
+	client := hzctl.Client{
+		Server:  httpServer.URL,
+		Session: admin,
+	}
+	statuses, err := client.Controllers(ctx)
+	tu.AssertNoError(t, err)
+	hasSecret := slices.ContainsFunc(statuses, func(s hz.ControllerStatus) bool {
+		return s.Group == "core" && s.Kind == "Secret"
+	})
+	tu.AssertTrue(t, hasSecret, "expected secret controller")
+
+	rec := get("/admin/controllers", admin)
+	tu.AssertEqual(t, http.StatusOK, rec.Result().StatusCode)
+	tu.AssertTrue(
+		t,
+		strings.Contains(rec.Body.String(), "<td>Secret</td>"),
+		"missing secret controller",
+	)
+
+	deadLetters, err := client.DeadLetters(ctx)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, 0, len(deadLetters))
+	err = client.RedriveDeadLetter(ctx, "core.v1.Secret.test.unknown")
+	var hErr *hz.Error
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, http.StatusNotFound, hErr.Status)
+	// Re-drives are audited, including failed ones.
+	entries, err := ts.Auth.Audit.Query(ctx, auth.AuditQuery{
+		Namespace: "test",
+	}, nil)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, 1, len(entries))
+	tu.AssertEqual(t, "redrive", entries[0].Action)
+	tu.AssertEqual(t, "unknown", entries[0].Name)
+	tu.AssertEqual(t, http.StatusNotFound, entries[0].Status)
+
+	// Only admins can list the controllers and dead letters.
+	user := newSession("users")
+	client.Session = user
+	_, err = client.Controllers(ctx)
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, http.StatusForbidden, hErr.Status)
+	_, err = client.DeadLetters(ctx)
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, http.StatusForbidden, hErr.Status)
+	rec = get("/admin/controllers", user)
+	tu.AssertEqual(t, http.StatusForbidden, rec.Result().StatusCode)
+}
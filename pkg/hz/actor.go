@@ -12,6 +12,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Actioner interface {
@@ -158,6 +160,18 @@ func (a *Actor[T]) startActioner(
 	doSub, err := a.nc.Subscribe(
 		runSubject,
 		func(msg *nats.Msg) {
+			ctx, span := Tracer().Start(
+				TraceExtract(ctx, msg.Header),
+				"actor.run",
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("hz.group", group),
+					attribute.String("hz.version", version),
+					attribute.String("hz.kind", kind),
+					attribute.String("hz.action", action),
+				),
+			)
+			defer span.End()
 			var t T
 			if err := json.Unmarshal(msg.Data, &t); err != nil {
 				slog.Error("unmarshalling msg data", "error", err)
@@ -175,6 +189,7 @@ func (a *Actor[T]) startActioner(
 			}
 			resp, err := actioner.Do(ctx, t)
 			if err != nil {
+				TraceError(span, err)
 				_ = RespondError(
 					msg,
 					&Error{
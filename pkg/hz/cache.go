@@ -0,0 +1,360 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// IndexFunc returns the values of an index for an object, e.g. the name of a
+// referenced object.
+type IndexFunc[T Objecter] func(obj T) []string
+
+type CacheOption[T Objecter] func(*cacheOptions[T])
+
+// WithCacheIndexer adds an index to the cache, to list the objects with a
+// value using [Cache.ByIndex].
+func WithCacheIndexer[T Objecter](
+	name string,
+	fn IndexFunc[T],
+) CacheOption[T] {
+	return func(o *cacheOptions[T]) {
+		o.indexers[name] = fn
+	}
+}
+
+// WithCacheWatcherOptions adds options to the watcher of the cache, e.g. for
+// leader election.
+func WithCacheWatcherOptions[T Objecter](opts ...WatcherOption) CacheOption[T] {
+	return func(o *cacheOptions[T]) {
+		o.watcherOpts = append(o.watcherOpts, opts...)
+	}
+}
+
+type cacheOptions[T Objecter] struct {
+	indexers    map[string]IndexFunc[T]
+	watcherOpts []WatcherOption
+}
+
+type CacheListOption func(*cacheListOptions)
+
+// WithCacheListNamespace only lists the objects in the namespace.
+func WithCacheListNamespace(namespace string) CacheListOption {
+	return func(o *cacheListOptions) {
+		o.namespace = namespace
+	}
+}
+
+// WithCacheListLabelSelector only lists the objects whose labels match the
+// selector.
+func WithCacheListLabelSelector(selector LabelSelector) CacheListOption {
+	return func(o *cacheListOptions) {
+		o.selector = &selector
+	}
+}
+
+type cacheListOptions struct {
+	namespace string
+	selector  *LabelSelector
+}
+
+// Cache keeps an in-memory copy of the objects of a kind, which it updates
+// using a [Watcher].
+//
+// The cache watches every version of the kind, and has each object in the
+// version of T.
+// Objects that are stored in another version (e.g. the storage version of a
+// kind with conversions) are fetched from the store, which converts them.
+//
+// Reading from a cache avoids a request to the store for each read, e.g. in a
+// reconciler that reads many objects.
+// A cache can be shared, e.g. by multiple controllers in the same process.
+// The objects in a cache may be slightly out of date, so use a [Client] to
+// read an object before updating it.
+//
+// Objects returned from a cache are shared, so do not modify them.
+type Cache[T Objecter] struct {
+	Conn *nats.Conn
+
+	watcher  *Watcher
+	client   ObjectClient[T]
+	indexers map[string]IndexFunc[T]
+
+	mu      sync.RWMutex
+	objects map[string]T
+	// indices maps the name of an index to the keys of the objects for each
+	// value of the index.
+	indices map[string]map[string]map[string]struct{}
+}
+
+// StartCache starts a cache for the objects of type T.
+func StartCache[T Objecter](
+	ctx context.Context,
+	conn *nats.Conn,
+	opts ...CacheOption[T],
+) (*Cache[T], error) {
+	c := &Cache[T]{Conn: conn}
+	if err := c.Start(ctx, opts...); err != nil {
+		return nil, fmt.Errorf("starting cache: %w", err)
+	}
+	return c, nil
+}
+
+func (c *Cache[T]) Start(ctx context.Context, opts ...CacheOption[T]) error {
+	opt := cacheOptions[T]{
+		indexers: make(map[string]IndexFunc[T]),
+	}
+	for _, o := range opts {
+		o(&opt)
+	}
+	c.indexers = opt.indexers
+	c.objects = make(map[string]T)
+	c.indices = make(map[string]map[string]map[string]struct{})
+	for name := range c.indexers {
+		c.indices[name] = make(map[string]map[string]struct{})
+	}
+
+	c.client = ObjectClient[T]{
+		Client: NewClient(c.Conn, WithClientInternal(true)),
+	}
+
+	var t T
+	c.watcher = &Watcher{Conn: c.Conn}
+	// Watch all versions of the kind.
+	// Clip the options, so that appending does not overwrite the caller's
+	// slice.
+	if err := c.watcher.Start(
+		ctx,
+		append(
+			slices.Clip(opt.watcherOpts),
+			WithWatcherFor(ObjectKey{
+				Group: t.ObjectGroup(),
+				Kind:  t.ObjectKind(),
+			}),
+			WithWatcherFn(func(event Event) (Result, error) {
+				return c.handleEvent(ctx, event)
+			}),
+		)...,
+	); err != nil {
+		return fmt.Errorf("starting watcher: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache[T]) Close() {
+	if c.watcher != nil {
+		c.watcher.Close()
+	}
+}
+
+// WaitForSync waits until the cache has the objects that existed when it was
+// started, or the context is done.
+func (c *Cache[T]) WaitForSync(ctx context.Context) error {
+	select {
+	case <-c.watcher.Init:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for cache to sync: %w", ctx.Err())
+	}
+}
+
+// HasSynced returns true if the cache has the objects that existed when it was
+// started.
+func (c *Cache[T]) HasSynced() bool {
+	select {
+	case <-c.watcher.Init:
+		return true
+	default:
+		return false
+	}
+}
+
+// Get returns the object with the namespace and name of the key.
+// It returns [ErrNotFound] if the object is not in the cache.
+func (c *Cache[T]) Get(key ObjectKeyer) (T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	obj, ok := c.objects[cacheKey(key)]
+	if !ok {
+		var t T
+		return t, ErrNotFound
+	}
+	return obj, nil
+}
+
+// List returns the objects that match the options, sorted by namespace and
+// name.
+func (c *Cache[T]) List(opts ...CacheListOption) []T {
+	opt := cacheListOptions{}
+	for _, o := range opts {
+		o(&opt)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]string, 0, len(c.objects))
+	for key, obj := range c.objects {
+		if c.matches(obj, opt) {
+			keys = append(keys, key)
+		}
+	}
+	return c.sortedObjects(keys)
+}
+
+// ByIndex returns the objects with the value in the index, sorted by
+// namespace and name.
+func (c *Cache[T]) ByIndex(
+	name string,
+	value string,
+	opts ...CacheListOption,
+) ([]T, error) {
+	opt := cacheListOptions{}
+	for _, o := range opts {
+		o(&opt)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	index, ok := c.indices[name]
+	if !ok {
+		return nil, fmt.Errorf("index %q does not exist", name)
+	}
+	keys := make([]string, 0, len(index[value]))
+	for key := range index[value] {
+		if c.matches(c.objects[key], opt) {
+			keys = append(keys, key)
+		}
+	}
+	return c.sortedObjects(keys), nil
+}
+
+func (c *Cache[T]) matches(obj T, opt cacheListOptions) bool {
+	if opt.namespace != "" && obj.ObjectNamespace() != opt.namespace {
+		return false
+	}
+	if opt.selector != nil && !opt.selector.Matches(objectLabels(obj)) {
+		return false
+	}
+	return true
+}
+
+// sortedObjects returns the objects of the keys, sorted by key.
+// The caller must hold the lock.
+func (c *Cache[T]) sortedObjects(keys []string) []T {
+	sort.Strings(keys)
+	objs := make([]T, len(keys))
+	for i, key := range keys {
+		objs[i] = c.objects[key]
+	}
+	return objs
+}
+
+func (c *Cache[T]) handleEvent(
+	ctx context.Context,
+	event Event,
+) (Result, error) {
+	obj, ok, err := c.eventObject(ctx, event)
+	if err != nil {
+		return Result{}, err
+	}
+	key := cacheKey(event.Key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delete(key)
+	if !ok {
+		return Result{}, nil
+	}
+	// Objects that are marked for deletion still exist, so keep them in the
+	// cache until they are purged.
+	c.objects[key] = obj
+	for name, fn := range c.indexers {
+		for _, value := range fn(obj) {
+			keys, ok := c.indices[name][value]
+			if !ok {
+				keys = make(map[string]struct{})
+				c.indices[name][value] = keys
+			}
+			keys[key] = struct{}{}
+		}
+	}
+	return Result{}, nil
+}
+
+// eventObject returns the object of the event in the version of T, or false
+// if the object no longer exists.
+func (c *Cache[T]) eventObject(
+	ctx context.Context,
+	event Event,
+) (T, bool, error) {
+	var t T
+	if event.Operation != EventOperationPurge &&
+		event.Key.ObjectVersion() == t.ObjectVersion() {
+		var obj T
+		if err := json.Unmarshal(event.Data, &obj); err != nil {
+			// The object will never decode, so skip it instead of retrying.
+			slog.Error(
+				"cache: unmarshalling object",
+				"key", KeyFromObject(event.Key),
+				"error", err,
+			)
+			return t, false, nil
+		}
+		return obj, true, nil
+	}
+	// The object is stored in another version, or was purged from this
+	// version but might still exist in another (e.g. after a migration).
+	// Get it from the store, which converts it to the version of T.
+	obj, err := c.client.Get(ctx, WithGetKey(ObjectKey{
+		Group:     t.ObjectGroup(),
+		Version:   t.ObjectVersion(),
+		Kind:      t.ObjectKind(),
+		Namespace: event.Key.ObjectNamespace(),
+		Name:      event.Key.ObjectName(),
+	}))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return t, false, nil
+		}
+		return t, false, fmt.Errorf("getting object: %w", err)
+	}
+	return obj, true, nil
+}
+
+// delete removes the object and its index values from the cache.
+// The caller must hold the lock.
+func (c *Cache[T]) delete(key string) {
+	obj, ok := c.objects[key]
+	if !ok {
+		return
+	}
+	delete(c.objects, key)
+	for name, fn := range c.indexers {
+		for _, value := range fn(obj) {
+			delete(c.indices[name][value], key)
+			if len(c.indices[name][value]) == 0 {
+				delete(c.indices[name], value)
+			}
+		}
+	}
+}
+
+// cacheKey returns the key of an object in a cache, which only has objects of
+// a single kind.
+func cacheKey(key ObjectKeyer) string {
+	return key.ObjectNamespace() + "/" + key.ObjectName()
+}
+
+// objectLabels returns the labels of the object, if it has object metadata.
+func objectLabels(obj Objecter) map[string]string {
+	if meta, ok := obj.(interface {
+		ObjectLabels() map[string]string
+	}); ok {
+		return meta.ObjectLabels()
+	}
+	return nil
+}
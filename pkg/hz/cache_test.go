@@ -0,0 +1,198 @@
+package hz_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+	apply := func(name string, env string) DummyObject {
+		obj := DummyObject{
+			ObjectMeta: hz.ObjectMeta{
+				Namespace: "test",
+				Name:      name,
+				Labels:    map[string]string{"env": env},
+			},
+		}
+		_, err := dummyClient.Apply(ctx, obj)
+		tu.AssertNoError(t, err)
+		return obj
+	}
+	names := func(objs []DummyObject) []string {
+		names := make([]string, len(objs))
+		for i, obj := range objs {
+			names[i] = obj.Name
+		}
+		return names
+	}
+
+	// Objects that exist before the cache starts are in the cache once it
+	// has synced.
+	a := apply("a", "prod")
+	cache, err := hz.StartCache[DummyObject](
+		ctx,
+		ti.Conn,
+		hz.WithCacheIndexer("env", func(obj DummyObject) []string {
+			return []string{obj.Labels["env"]}
+		}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(cache.Close)
+	syncCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = cache.WaitForSync(syncCtx)
+	tu.AssertNoError(t, err)
+	tu.AssertTrue(t, cache.HasSynced(), "expected cache to have synced")
+	cached, err := cache.Get(a)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, "prod", cached.Labels["env"])
+
+	b := apply("b", "dev")
+	waitFor(t, func() bool {
+		return len(cache.List()) == 2
+	})
+	tu.AssertEqual(t, []string{"a", "b"}, names(cache.List()))
+	tu.AssertEqual(
+		t,
+		[]string{"a"},
+		names(cache.List(hz.WithCacheListLabelSelector(hz.LabelSelector{
+			MatchLabels: map[string]string{"env": "prod"},
+		}))),
+	)
+	tu.AssertEqual(
+		t,
+		0,
+		len(cache.List(hz.WithCacheListNamespace("other"))),
+	)
+	devObjs, err := cache.ByIndex("env", "dev")
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, []string{"b"}, names(devObjs))
+	_, err = cache.ByIndex("unknown", "dev")
+	tu.AssertTrue(t, err != nil, "expected error for unknown index")
+
+	// Updating an object updates the indices.
+	apply("b", "prod")
+	waitFor(t, func() bool {
+		devObjs, _ := cache.ByIndex("env", "dev")
+		return len(devObjs) == 0
+	})
+	prodObjs, err := cache.ByIndex("env", "prod")
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, []string{"a", "b"}, names(prodObjs))
+
+	// Deleted objects are removed from the cache once they are purged.
+	err = dummyClient.Delete(ctx, b)
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		_, err := cache.Get(b)
+		return err != nil
+	})
+	_, err = cache.Get(b)
+	tu.AssertErrorIs(t, err, hz.ErrNotFound)
+	prodObjs, err = cache.ByIndex("env", "prod")
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, []string{"a"}, names(prodObjs))
+}
+
+func TestCacheConversion(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	v1Client := hz.ObjectClient[WidgetV1]{Client: client}
+	v2Client := hz.ObjectClient[WidgetV2]{Client: client}
+
+	// Store an object as v1, before the kind stores v2.
+	v1Ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV1{}),
+	)
+	tu.AssertNoError(t, err)
+	_, err = v1Client.Apply(ctx, WidgetV1{
+		ObjectMeta: hz.ObjectMeta{Namespace: "test", Name: "old"},
+		Spec:       WidgetV1Spec{Size: 1},
+	})
+	tu.AssertNoError(t, err)
+	err = v1Ctlr.Stop()
+	tu.AssertNoError(t, err)
+
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV2{}),
+		hz.WithControllerVersion(WidgetV1{}, widgetV1ToV2, widgetV2ToV1),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+	_, err = v2Client.Apply(ctx, WidgetV2{
+		ObjectMeta: hz.ObjectMeta{Namespace: "test", Name: "new"},
+		Spec:       WidgetV2Spec{Replicas: 2},
+	})
+	tu.AssertNoError(t, err)
+
+	// Caches of both versions have both objects, in their own version.
+	v1Cache, err := hz.StartCache[WidgetV1](ctx, ti.Conn)
+	tu.AssertNoError(t, err)
+	t.Cleanup(v1Cache.Close)
+	v2Cache, err := hz.StartCache[WidgetV2](ctx, ti.Conn)
+	tu.AssertNoError(t, err)
+	t.Cleanup(v2Cache.Close)
+	syncCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = v1Cache.WaitForSync(syncCtx)
+	tu.AssertNoError(t, err)
+	err = v2Cache.WaitForSync(syncCtx)
+	tu.AssertNoError(t, err)
+
+	v1Objs := v1Cache.List()
+	tu.AssertEqual(t, 2, len(v1Objs))
+	tu.AssertEqual(t, "new", v1Objs[0].Name)
+	tu.AssertEqual(t, 2, v1Objs[0].Spec.Size)
+	tu.AssertEqual(t, "old", v1Objs[1].Name)
+	tu.AssertEqual(t, 1, v1Objs[1].Spec.Size)
+	v2Objs := v2Cache.List()
+	tu.AssertEqual(t, 2, len(v2Objs))
+	tu.AssertEqual(t, 2, v2Objs[0].Spec.Replicas)
+	tu.AssertEqual(t, 1, v2Objs[1].Spec.Replicas)
+
+	// Changes to the storage version are converted for the v1 cache.
+	_, err = v2Client.Apply(ctx, WidgetV2{
+		ObjectMeta: hz.ObjectMeta{Namespace: "test", Name: "new"},
+		Spec:       WidgetV2Spec{Replicas: 5},
+	})
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		obj, err := v1Cache.Get(hz.ObjectKey{Namespace: "test", Name: "new"})
+		return err == nil && obj.Spec.Size == 5
+	})
+}
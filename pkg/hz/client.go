@@ -14,11 +14,30 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	BucketObjects = "hz_objects"
 	BucketMutex   = "hz_objects_mutex"
+	// BucketValidators stores the validator policy of each kind.
+	BucketValidators = "hz_validators"
+	// BucketMergeSchemas stores the schema for merging the lists of each kind
+	// and version during server-side apply.
+	BucketMergeSchemas = "hz_merge_schemas"
+	// BucketLeases stores the leases for leader election.
+	BucketLeases = "hz_leases"
+	// BucketControllers stores the status of the running controller
+	// instances.
+	BucketControllers = "hz_controllers"
+	// BucketDeadLetters stores the objects that controllers stopped retrying
+	// after exceeding their max retries.
+	BucketDeadLetters = "hz_dead_letters"
+	// BucketVersions stores the storage and served versions of each kind.
+	BucketVersions = "hz_versions"
+	// BucketResyncs stores how many deliveries of the last message of each
+	// object were resyncs, which do not count as retries.
+	BucketResyncs = "hz_resyncs"
 )
 
 const (
@@ -27,6 +46,7 @@ const (
 	HeaderApplyCreateOnly     = "Hz-Apply-Create-Only"
 	HeaderApplyFieldManager   = "Hz-Apply-Field-Manager"
 	HeaderApplyForceConflicts = "Hz-Apply-Force-Conflicts"
+	HeaderConvertVersion      = "Hz-Convert-Version"
 )
 
 const (
@@ -40,6 +60,10 @@ var (
 		Status:  http.StatusNotFound,
 		Message: "not found",
 	}
+	ErrTooManyRequests = &Error{
+		Status:  http.StatusTooManyRequests,
+		Message: "too many requests",
+	}
 	ErrApplyManagerRequired       = errors.New("apply: field manager required")
 	ErrClientObjectOrDataRequired = errors.New("object or data required")
 	ErrClientNoSession            = errors.New("client: no session")
@@ -50,6 +74,8 @@ var (
 	ErrRunTimeout              = errors.New("run: broker timeout")
 	ErrBrokerNoActorResponders = errors.New("broker: no actor responders")
 	ErrBrokerActorTimeout      = errors.New("broker: actor timeout")
+
+	ErrReconcileTimeout = errors.New("reconcile: timeout")
 )
 
 const (
@@ -83,6 +109,10 @@ const (
 	SubjectCtlrSchema         = "HZ.internal.controller.schema.%s.%s.%s"
 	SubjectCtlrValidateCreate = "HZ.internal.controller.validate_create.%s.%s.%s"
 	SubjectCtlrValidateUpdate = "HZ.internal.controller.validate_update.%s.%s.%s"
+	// format: HZ.internal.controller.<cmd>.<group>.<kind>
+	SubjectCtlrConvert     = "HZ.internal.controller.convert.%s.%s"
+	SubjectCtlrConvertList = "HZ.internal.controller.convert_list.%s.%s"
+	SubjectCtlrRedrive     = "HZ.internal.controller.redrive.%s.%s"
 )
 
 const SubjectPortalRender = "HZ.internal.portal.%s.http.render"
@@ -97,6 +127,9 @@ const (
 	SubjectStoreGet    = "store.get.%s.%s.%s.%s.%s"
 	SubjectStoreDelete = "store.delete.%s.%s.%s.%s.%s"
 	SubjectStoreList   = "store.list.%s.%s.%s.%s.%s"
+	// SubjectStoreMigrate rewrites the object to the storage version of its
+	// kind.
+	SubjectStoreMigrate = "store.migrate.%s.%s.%s.%s.%s"
 )
 
 type ObjectClient[T Objecter] struct {
@@ -289,7 +322,7 @@ type Client struct {
 	Manager string
 }
 
-func (c Client) marshalObjectWithTypeFields(obj Objecter) ([]byte, error) {
+func marshalObjectWithTypeFields(obj Objecter) ([]byte, error) {
 	data, err := json.Marshal(obj)
 	if err != nil {
 		return nil, fmt.Errorf("marshalling object: %w", err)
@@ -309,6 +342,33 @@ func (c Client) marshalObjectWithTypeFields(obj Objecter) ([]byte, error) {
 	return data, nil
 }
 
+// request sends msg and waits for the reply, within a span with the given
+// name.
+// The trace context is added to msg so that the receiver can continue the
+// trace.
+func (c Client) request(
+	ctx context.Context,
+	name string,
+	key ObjectKeyer,
+	msg *nats.Msg,
+) (*nats.Msg, error) {
+	ctx, span := Tracer().Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(TraceAttributes(key)...),
+	)
+	defer span.End()
+	TraceInject(ctx, msg.Header)
+	reply, err := c.Conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		TraceError(span, err)
+		return nil, err
+	}
+	TraceError(span, ErrorFromNATS(reply))
+	return reply, nil
+}
+
 func (c Client) checkSession() error {
 	if !c.Internal && c.Session == "" {
 		return ErrClientNoSession
@@ -384,7 +444,7 @@ func (c Client) Validate(
 	var obj Objecter
 	if vo.obj != nil {
 		var err error
-		vo.data, err = c.marshalObjectWithTypeFields(vo.obj)
+		vo.data, err = marshalObjectWithTypeFields(vo.obj)
 		if err != nil {
 			return fmt.Errorf("marshalling object: %w", err)
 		}
@@ -485,7 +545,7 @@ func (c Client) Apply(
 	)
 	if ao.object != nil {
 		var err error
-		data, err = c.marshalObjectWithTypeFields(ao.object)
+		data, err = marshalObjectWithTypeFields(ao.object)
 		if err != nil {
 			return ApplyOpResultError, fmt.Errorf("marshalling object: %w", err)
 		}
@@ -528,7 +588,7 @@ func (c Client) Apply(
 	msg.Data = data
 	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
 	defer cancel()
-	reply, err := c.Conn.RequestMsgWithContext(ctx, msg)
+	reply, err := c.request(ctx, "client.apply", key, msg)
 	if err != nil {
 		if errors.Is(err, nats.ErrNoResponders) {
 			return ApplyOpResultError, fmt.Errorf(
@@ -611,10 +671,7 @@ func (c *Client) Get(
 	msg.Header.Set(HeaderAuthorization, c.Session)
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
-	reply, err := c.Conn.RequestMsgWithContext(
-		ctx,
-		msg,
-	)
+	reply, err := c.request(ctx, "client.get", key, msg)
 	if err != nil {
 		return nil, ErrorFromNATSErr(err)
 	}
@@ -688,10 +745,7 @@ func (c *Client) Delete(
 	msg.Header.Set(HeaderAuthorization, c.Session)
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
-	reply, err := c.Conn.RequestMsgWithContext(
-		ctx,
-		msg,
-	)
+	reply, err := c.request(ctx, "client.delete", key, msg)
 	if err != nil {
 		if errors.Is(err, nats.ErrNoResponders) {
 			return ErrStoreNotResponding
@@ -701,6 +755,92 @@ func (c *Client) Delete(
 	return ErrorFromNATS(reply)
 }
 
+type MigrateOption func(*migrateOptions)
+
+// WithMigrateKey sets the key of the object to migrate.
+// The key must include the version the object is currently stored under.
+func WithMigrateKey(key ObjectKeyer) MigrateOption {
+	return func(mo *migrateOptions) {
+		mo.key = key
+	}
+}
+
+type migrateOptions struct {
+	key ObjectKeyer
+}
+
+type MigrateOpResult string
+
+const (
+	MigrateOpResultMigrated MigrateOpResult = "migrated"
+	MigrateOpResultNoop     MigrateOpResult = "noop"
+	MigrateOpResultConflict MigrateOpResult = "conflict"
+	MigrateOpResultError    MigrateOpResult = "error"
+)
+
+// Migrate rewrites a stored object to the storage version of its kind.
+// If the object is already stored using the storage version, the result is
+// [MigrateOpResultNoop].
+// If the object was modified during the migration, the result is
+// [MigrateOpResultConflict] and the migration can be tried again.
+func (c *Client) Migrate(
+	ctx context.Context,
+	opts ...MigrateOption,
+) (MigrateOpResult, error) {
+	if err := c.checkSession(); err != nil {
+		return MigrateOpResultError, err
+	}
+	mo := migrateOptions{}
+	for _, opt := range opts {
+		opt(&mo)
+	}
+	if mo.key == nil {
+		return MigrateOpResultError, fmt.Errorf("migrate: key required")
+	}
+	if err := validateKeyStrict(mo.key); err != nil {
+		return MigrateOpResultError, fmt.Errorf("invalid key: %w", err)
+	}
+	msg := nats.NewMsg(
+		c.SubjectPrefix() + fmt.Sprintf(
+			SubjectStoreMigrate,
+			mo.key.ObjectGroup(),
+			mo.key.ObjectVersion(),
+			mo.key.ObjectKind(),
+			mo.key.ObjectNamespace(),
+			mo.key.ObjectName(),
+		),
+	)
+	msg.Header.Set(HeaderAuthorization, c.Session)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	reply, err := c.request(ctx, "client.migrate", mo.key, msg)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return MigrateOpResultError, ErrStoreNotResponding
+		}
+		return MigrateOpResultError, fmt.Errorf("migrating object: %w", err)
+	}
+	headerStatus := reply.Header.Get(HeaderStatus)
+	status, err := strconv.Atoi(headerStatus)
+	if err != nil {
+		return MigrateOpResultError, fmt.Errorf(
+			"invalid status header %q: %w",
+			headerStatus,
+			err,
+		)
+	}
+	switch status {
+	case http.StatusOK:
+		return MigrateOpResultMigrated, nil
+	case http.StatusNotModified:
+		return MigrateOpResultNoop, nil
+	case http.StatusConflict:
+		return MigrateOpResultConflict, ErrorFromNATS(reply)
+	default:
+		return MigrateOpResultError, ErrorFromNATS(reply)
+	}
+}
+
 func WithListKey(obj ObjectKeyer) ListOption {
 	return func(lo *listOption) {
 		lo.key = obj
@@ -759,7 +899,7 @@ func (c *Client) List(
 		),
 	)
 	msg.Header.Set(HeaderAuthorization, c.Session)
-	reply, err := c.Conn.RequestMsgWithContext(ctx, msg)
+	reply, err := c.request(ctx, "client.list", key, msg)
 	if err != nil {
 		if errors.Is(err, nats.ErrNoResponders) {
 			return ErrStoreNotResponding
@@ -854,7 +994,7 @@ func (c *Client) Run(
 	)
 	if ro.object != nil {
 		var err error
-		data, err = c.marshalObjectWithTypeFields(ro.object)
+		data, err = marshalObjectWithTypeFields(ro.object)
 		if err != nil {
 			return nil, fmt.Errorf("marshalling object: %w", err)
 		}
@@ -903,7 +1043,7 @@ func (c *Client) Run(
 	msg.Data = bRunMsg
 	ctx, cancel := context.WithTimeout(ctx, ro.timeout)
 	defer cancel()
-	reply, err := c.Conn.RequestMsgWithContext(ctx, msg)
+	reply, err := c.request(ctx, "client.run", key, msg)
 	if err != nil {
 		switch {
 		case errors.Is(err, nats.ErrNoResponders):
@@ -0,0 +1,377 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ConditionStatus is the status of a condition.
+type ConditionStatus string
+
+const (
+	ConditionTrue  ConditionStatus = "True"
+	ConditionFalse ConditionStatus = "False"
+)
+
+// ConditionTypeReconcileFailed is the type of the condition that the
+// controller sets when it fails to reconcile an object.
+const ConditionTypeReconcileFailed = "ReconcileFailed"
+
+// Reasons for the [ConditionTypeReconcileFailed] condition.
+const (
+	// ConditionReasonReconcileTimeout means the reconciler did not finish
+	// within the reconcile timeout of the controller.
+	ConditionReasonReconcileTimeout = "ReconcileTimeout"
+	// ConditionReasonMaxRetriesExceeded means the reconciler failed more
+	// times than the max retries of the controller, which stopped retrying.
+	ConditionReasonMaxRetriesExceeded = "MaxRetriesExceeded"
+	// ConditionReasonReconciled means the object was reconciled successfully
+	// after a failure.
+	ConditionReasonReconciled = "Reconciled"
+)
+
+// Condition describes an aspect of the state of an object.
+//
+// Objects show conditions by adding them to their status:
+//
+//	type MyObjectStatus struct {
+//		Conditions []hz.Condition `json:"conditions,omitempty" cue:",opt"`
+//	}
+//
+// A list of conditions is merged as a map keyed by the condition type during
+// server-side apply, so that each condition can be owned by a different
+// manager.
+type Condition struct {
+	// Type of the condition, e.g. "Ready" or "ReconcileFailed".
+	Type string `json:"type"`
+	// Status of the condition, either "True" or "False".
+	Status ConditionStatus `json:"status"`
+	// Reason is a CamelCase reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"             cue:",opt"`
+	// Message is a human readable message with details about the condition.
+	Message string `json:"message,omitempty"            cue:",opt"`
+	// LastTransitionTime is when the condition last changed status.
+	LastTransitionTime *Time `json:"lastTransitionTime,omitempty" cue:",opt"`
+}
+
+// FindCondition returns the condition of the given type.
+func FindCondition(conditions []Condition, conditionType string) (Condition, bool) {
+	for _, condition := range conditions {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+	return Condition{}, false
+}
+
+var conditionType = reflect.TypeOf(Condition{})
+
+// hasStatusConditions returns true if the object has a list of [Condition]
+// in its status, under the JSON field "conditions".
+func hasStatusConditions(obj Objecter) bool {
+	status, ok := jsonField(reflect.TypeOf(obj), "status")
+	if !ok {
+		return false
+	}
+	conditions, ok := jsonField(status.Type, "conditions")
+	if !ok {
+		return false
+	}
+	return conditions.Type.Kind() == reflect.Slice &&
+		conditions.Type.Elem() == conditionType
+}
+
+// jsonField returns the field of the struct type with the given JSON name,
+// including fields of inlined embedded structs.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if isInlined(field) {
+			if f, ok := jsonField(field.Type, name); ok {
+				return f, true
+			}
+			continue
+		}
+		if jsonFieldName(field) == name {
+			if field.Type.Kind() == reflect.Ptr {
+				field.Type = field.Type.Elem()
+			}
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// setTimeoutCondition sets the ReconcileFailed condition of an object whose
+// reconcile timed out.
+//
+// Writing the condition creates a new revision of the object, which replaces
+// the message of the failed revision.
+// The retry is recorded, so that the new revision is reconciled after the
+// backoff and counts the attempts of the failed revision (see
+// [Controller.conditionRetryDelay]).
+func (c *Controller) setTimeoutCondition(
+	ctx context.Context,
+	key string,
+	objKey ObjectKey,
+	reconcileErr error,
+	attempt int,
+	backoff time.Duration,
+) {
+	c.conditionRetries.set(key, attempt, time.Now().Add(backoff))
+	if err := c.setCondition(ctx, objKey, Condition{
+		Type:    ConditionTypeReconcileFailed,
+		Status:  ConditionTrue,
+		Reason:  ConditionReasonReconcileTimeout,
+		Message: reconcileErr.Error(),
+	}); err != nil {
+		slog.Error("setting reconcile timeout condition", "error", err)
+	}
+}
+
+// conditionRetryDelay returns the delay before reconciling the message, if the
+// message is a revision that only set the ReconcileFailed condition of an
+// object that is being retried.
+func (c *Controller) conditionRetryDelay(
+	key string,
+	msg jetstream.Msg,
+) (time.Duration, bool) {
+	if !c.conditions {
+		return 0, false
+	}
+	meta, err := msg.Metadata()
+	if err != nil {
+		return 0, false
+	}
+	old, ok := c.seen.get(key)
+	if !ok || old.sequence >= meta.Sequence.Stream {
+		return 0, false
+	}
+	var obj GenericObject
+	if err := json.Unmarshal(msg.Data(), &obj); err != nil {
+		return 0, false
+	}
+	if old.withoutReconcileFailed != hashWithoutReconcileFailed(obj) {
+		return 0, false
+	}
+	delay, ok := c.conditionRetries.take(key, meta.Sequence.Stream)
+	if !ok {
+		return 0, false
+	}
+	// Record the revision, so that it is reconciled when it is redelivered.
+	c.seen.swap(key, meta.Sequence.Stream, obj)
+	return delay, true
+}
+
+// conditionRetries records the retries of objects whose ReconcileFailed
+// condition the controller set, by key.
+type conditionRetries struct {
+	mu      sync.Mutex
+	entries map[string]conditionRetry
+}
+
+type conditionRetry struct {
+	// attempt is the attempt of the reconcile that failed.
+	attempt int
+	// at is when the object is retried.
+	at time.Time
+	// revision is the revision with the condition, once it is delivered.
+	revision uint64
+}
+
+func (r *conditionRetries) set(key string, attempt int, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entries == nil {
+		r.entries = make(map[string]conditionRetry)
+	}
+	r.entries[key] = conditionRetry{attempt: attempt, at: at}
+}
+
+// take returns the delay until the retry of the object, if the retry is
+// pending, and records the revision that carries the retry.
+func (r *conditionRetries) take(
+	key string,
+	revision uint64,
+) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	retry, ok := r.entries[key]
+	if !ok || retry.revision != 0 {
+		return 0, false
+	}
+	delay := time.Until(retry.at)
+	if delay <= 0 {
+		// The retry is due, so reconcile the revision right away.
+		delete(r.entries, key)
+		return 0, false
+	}
+	retry.revision = revision
+	r.entries[key] = retry
+	return delay, true
+}
+
+// carried returns the number of attempts of the failed revision, if the
+// revision carries its retry.
+// The first delivery of the revision only delayed the retry, so it is not
+// counted.
+func (r *conditionRetries) carried(key string, revision uint64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	retry, ok := r.entries[key]
+	if !ok || retry.revision != revision {
+		return 0
+	}
+	return retry.attempt - 1
+}
+
+func (r *conditionRetries) forget(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.entries, key)
+}
+
+// hashWithoutReconcileFailed returns the hash of the object without the
+// ReconcileFailed condition, which the controller writes itself.
+func hashWithoutReconcileFailed(obj GenericObject) uint64 {
+	fields := objectFields(obj)
+	status, ok := fields["status"].(map[string]interface{})
+	if !ok {
+		return hashValue(fields)
+	}
+	if conditions, ok := status["conditions"].([]interface{}); ok {
+		conditions = slices.DeleteFunc(conditions, func(c interface{}) bool {
+			condition, ok := c.(map[string]interface{})
+			return ok && condition["type"] == ConditionTypeReconcileFailed
+		})
+		status["conditions"] = conditions
+		if len(conditions) == 0 {
+			delete(status, "conditions")
+		}
+	}
+	if len(status) == 0 {
+		delete(fields, "status")
+	}
+	return hashValue(fields)
+}
+
+// conditionManagerSuffix is appended to the kind of a controller to get the
+// field manager of the conditions that the controller sets.
+const conditionManagerSuffix = "-conditions"
+
+// setCondition applies the condition to the status of the object, using a
+// field manager that only owns the condition.
+//
+// Setting a condition writes a new revision of the object, which triggers
+// another reconcile.
+// Hence, the condition is only applied if it differs from the existing one.
+func (c *Controller) setCondition(
+	ctx context.Context,
+	key ObjectKey,
+	condition Condition,
+) error {
+	conditions, err := c.getConditions(ctx, key)
+	if err != nil {
+		return err
+	}
+	existing, ok := FindCondition(conditions, condition.Type)
+	if ok && existing.Status == condition.Status {
+		if existing.Reason == condition.Reason &&
+			existing.Message == condition.Message {
+			return nil
+		}
+		condition.LastTransitionTime = existing.LastTransitionTime
+	}
+	if condition.LastTransitionTime == nil {
+		condition.LastTransitionTime = &Time{
+			Time: time.Now().UTC().Truncate(time.Second),
+		}
+	}
+	obj := map[string]interface{}{
+		"apiVersion": key.Group + "/" + key.Version,
+		"kind":       key.Kind,
+		"metadata": map[string]interface{}{
+			"namespace": key.Namespace,
+			"name":      key.Name,
+		},
+		"status": map[string]interface{}{
+			"conditions": []Condition{condition},
+		},
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("marshalling condition: %w", err)
+	}
+	client := NewClient(
+		c.Conn,
+		WithClientInternal(true),
+		WithClientManager("ctlr-"+key.Kind+conditionManagerSuffix),
+	)
+	if _, err := client.Apply(
+		ctx,
+		WithApplyData(data),
+		WithApplyForce(true),
+	); err != nil {
+		return fmt.Errorf("applying condition: %w", err)
+	}
+	return nil
+}
+
+// clearReconcileFailed sets the ReconcileFailed condition of the object to
+// false, if it is true.
+func (c *Controller) clearReconcileFailed(
+	ctx context.Context,
+	key ObjectKey,
+) error {
+	conditions, err := c.getConditions(ctx, key)
+	if err != nil {
+		return IgnoreNotFound(err)
+	}
+	condition, ok := FindCondition(conditions, ConditionTypeReconcileFailed)
+	if !ok || condition.Status != ConditionTrue {
+		return nil
+	}
+	return c.setCondition(ctx, key, Condition{
+		Type:   ConditionTypeReconcileFailed,
+		Status: ConditionFalse,
+		Reason: ConditionReasonReconciled,
+	})
+}
+
+// getConditions returns the conditions in the status of the object.
+func (c *Controller) getConditions(
+	ctx context.Context,
+	key ObjectKey,
+) ([]Condition, error) {
+	client := NewClient(c.Conn, WithClientInternal(true))
+	data, err := client.Get(ctx, WithGetKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("getting object: %w", err)
+	}
+	var obj struct {
+		Status struct {
+			Conditions []Condition `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("unmarshalling object: %w", err)
+	}
+	return obj.Status.Conditions, nil
+}
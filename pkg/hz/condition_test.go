@@ -0,0 +1,249 @@
+package hz_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/extensions/core"
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+type ConditionObject struct {
+	hz.ObjectMeta `json:"metadata,omitempty" cue:""`
+
+	Spec   struct{}               `json:"spec,omitempty"   cue:""`
+	Status *ConditionObjectStatus `json:"status,omitempty" cue:",opt"`
+}
+
+func (o ConditionObject) ObjectGroup() string {
+	return "DummyGroup"
+}
+
+func (o ConditionObject) ObjectVersion() string {
+	return "v1"
+}
+
+func (o ConditionObject) ObjectKind() string {
+	return "ConditionObject"
+}
+
+type ConditionObjectStatus struct {
+	Conditions []hz.Condition `json:"conditions,omitempty" cue:",opt"`
+}
+
+// HangingReconciler does not return until its context is done, while hang is
+// true.
+type HangingReconciler struct {
+	hang       atomic.Bool
+	reconciles atomic.Int32
+}
+
+func (r *HangingReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	r.reconciles.Add(1)
+	if r.hang.Load() {
+		<-ctx.Done()
+		return hz.Result{}, ctx.Err()
+	}
+	return hz.Result{}, nil
+}
+
+func TestReconcileTimeout(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	condClient := hz.ObjectClient[ConditionObject]{Client: client}
+	hr := HangingReconciler{}
+	hr.hang.Store(true)
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&hr),
+		hz.WithControllerFor(ConditionObject{}),
+		hz.WithControllerReconcileTimeout(time.Millisecond*500),
+		hz.WithControllerRateLimiter(&hz.FixedIntervalRateLimiter{
+			Interval: time.Millisecond * 100,
+		}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	obj := ConditionObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "timeout",
+		},
+	}
+	_, err = condClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+
+	reconcileFailed := func() (hz.Condition, bool) {
+		obj, err := condClient.Get(ctx, hz.WithGetKey(obj))
+		tu.AssertNoError(t, err)
+		if obj.Status == nil {
+			return hz.Condition{}, false
+		}
+		return hz.FindCondition(
+			obj.Status.Conditions,
+			hz.ConditionTypeReconcileFailed,
+		)
+	}
+	// The reconcile times out, which is shown on the object's status.
+	waitFor(t, func() bool {
+		cond, ok := reconcileFailed()
+		return ok && cond.Status == hz.ConditionTrue
+	})
+	cond, _ := reconcileFailed()
+	tu.AssertEqual(t, hz.ConditionReasonReconcileTimeout, cond.Reason)
+	tu.AssertEqual(t, "reconcile: timeout: after 500ms", cond.Message)
+
+	// Once the reconciler succeeds, the condition is cleared.
+	hr.hang.Store(false)
+	waitFor(t, func() bool {
+		cond, ok := reconcileFailed()
+		return ok && cond.Status == hz.ConditionFalse
+	})
+	cond, _ = reconcileFailed()
+	tu.AssertEqual(t, hz.ConditionReasonReconciled, cond.Reason)
+}
+
+func TestReconcileTimeoutBackoff(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	condClient := hz.ObjectClient[ConditionObject]{Client: client}
+	eventClient := hz.ObjectClient[core.Event]{Client: client}
+	hr := HangingReconciler{}
+	hr.hang.Store(true)
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&hr),
+		hz.WithControllerFor(ConditionObject{}),
+		hz.WithControllerReconcileTimeout(time.Millisecond*200),
+		hz.WithControllerRateLimiter(&hz.FixedIntervalRateLimiter{
+			Interval: time.Second * 3,
+		}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	obj := ConditionObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "backoff",
+		},
+	}
+	_, err = condClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+
+	// The timeout is recorded as an event.
+	var events []core.Event
+	waitFor(t, func() bool {
+		events, err = eventClient.List(ctx, hz.WithListKey(hz.ObjectKey{
+			Namespace: "test",
+		}))
+		tu.AssertNoError(t, err)
+		return len(events) > 0
+	})
+	tu.AssertTrue(t, events[0].IsFor(obj), "expected event for object")
+	tu.AssertEqual(t, hz.ConditionReasonReconcileTimeout, events[0].Reason)
+	tu.AssertEqual(t, hz.EventTypeWarning, events[0].Type)
+
+	// Writing the ReconcileFailed condition does not reconcile the object
+	// before the backoff.
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, int32(1), hr.reconciles.Load())
+	waitFor(t, func() bool {
+		return hr.reconciles.Load() == 2
+	})
+}
+
+// OverrunReconciler ignores the cancellation of its context, and records the
+// most reconciles of an object that ran at the same time.
+type OverrunReconciler struct {
+	delay      time.Duration
+	active     atomic.Int32
+	maxActive  atomic.Int32
+	reconciles atomic.Int32
+}
+
+func (r *OverrunReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	active := r.active.Add(1)
+	defer r.active.Add(-1)
+	for {
+		maxActive := r.maxActive.Load()
+		if active <= maxActive ||
+			r.maxActive.CompareAndSwap(maxActive, active) {
+			break
+		}
+	}
+	time.Sleep(r.delay)
+	r.reconciles.Add(1)
+	return hz.Result{}, nil
+}
+
+func TestReconcileTimeoutGracePeriod(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	condClient := hz.ObjectClient[ConditionObject]{Client: client}
+	orc := OverrunReconciler{delay: time.Millisecond * 600}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&orc),
+		hz.WithControllerFor(ConditionObject{}),
+		hz.WithControllerReconcileTimeout(time.Millisecond*200),
+		hz.WithControllerRateLimiter(&hz.FixedIntervalRateLimiter{
+			Interval: time.Millisecond * 10,
+		}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	_, err = condClient.Apply(ctx, ConditionObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "slow",
+		},
+	})
+	tu.AssertNoError(t, err)
+
+	// The reconciles time out, but the lock is held until the reconciler
+	// returns, so the object is never reconciled twice at the same time.
+	waitFor(t, func() bool {
+		return orc.reconciles.Load() >= 2
+	})
+	tu.AssertEqual(t, int32(1), orc.maxActive.Load())
+}
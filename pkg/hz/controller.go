@@ -2,20 +2,25 @@ package hz
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"math"
 	"net/http"
 	"reflect"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ControllerOption func(*controllerOption)
@@ -32,6 +37,18 @@ func WithControllerReconciler(reconciler Reconciler) ControllerOption {
 	}
 }
 
+// WithControllerTypedReconciler sets a [TypedReconciler] as the reconciler,
+// wrapped in a [FetchingReconciler] that gets the object with the client.
+func WithControllerTypedReconciler[T Objecter](
+	client ObjectClient[T],
+	reconciler TypedReconciler[T],
+) ControllerOption {
+	return WithControllerReconciler(&FetchingReconciler[T]{
+		Client:     client,
+		Reconciler: reconciler,
+	})
+}
+
 func WithControllerValidator(validator Validator) ControllerOption {
 	return func(ro *controllerOption) {
 		ro.validators = append(ro.validators, validator)
@@ -53,6 +70,14 @@ func WithControllerValidatorForceNone() ControllerOption {
 	}
 }
 
+// WithControllerValidatorPolicy sets the timeout and failure policy used by
+// the store when calling the validators of the controller's kind.
+func WithControllerValidatorPolicy(policy ValidatorPolicy) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.validatorPolicy = policy
+	}
+}
+
 func WithControllerFor(obj Objecter) ControllerOption {
 	return func(ro *controllerOption) {
 		ro.forObject = obj
@@ -65,12 +90,151 @@ func WithControllerOwns(obj Objecter) ControllerOption {
 	}
 }
 
+// WithControllerWatches reconciles objects of the controller's kind when an
+// object of the given kind changes.
+// The map function returns the requests to reconcile for the changed object,
+// e.g. the objects that reference it.
+//
+// Unlike [WithControllerOwns], the watched objects need not be owned by the
+// objects they trigger.
+func WithControllerWatches(obj Objecter, mapFn MapFunc) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.reconWatches = append(ro.reconWatches, watch{
+			object: obj,
+			mapFn:  mapFn,
+		})
+	}
+}
+
+// WithControllerPredicates only reconciles changes to objects of the
+// controller's kind that all the predicates allow, e.g. to skip the changes
+// that a reconciler makes to the status of the object it reconciles.
+//
+// Predicates do not apply to owned or watched objects.
+func WithControllerPredicates(predicates ...Predicate) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.predicates = append(ro.predicates, predicates...)
+	}
+}
+
+// WithControllerNamespaces only reconciles objects in the given namespaces.
+// The controller's consumer only receives the objects of its kind in the
+// namespaces, so controllers for different namespaces can run in separate
+// processes, e.g. one per environment.
+// The default reconciles objects in all namespaces.
+func WithControllerNamespaces(namespaces ...string) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.namespaces = append(ro.namespaces, namespaces...)
+	}
+}
+
+// WithControllerLabelSelector only reconciles objects whose labels match the
+// selector.
+// The labels of the object are checked before each reconcile, including
+// reconciles for owned and watched objects.
+func WithControllerLabelSelector(selector LabelSelector) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.labelSelector = &selector
+	}
+}
+
+// WithControllerSharding spreads the objects of the controller's kind across
+// the instances of the controller that use sharding, using consistent hashing
+// of the object keys.
+// Each object is reconciled by one instance, so instances do not compete for
+// the lock of an object, and the keys are rebalanced when instances join or
+// leave.
+//
+// The instances are found using the controller registry, so it must exist.
+// Sharding cannot be used with leader election.
+func WithControllerSharding() ControllerOption {
+	return func(ro *controllerOption) {
+		ro.sharding = true
+	}
+}
+
+// WithControllerLeaderElection only runs the reconciler of the controller in
+// the instance that is the leader of the election with the given name.
+// Validators and conversions run in every instance.
+//
+// Use the options to add callbacks for when leadership is gained or lost.
+func WithControllerLeaderElection(
+	name string,
+	opts ...LeaderElectorOption,
+) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.leaderElection = &leaderElection{name: name, opts: opts}
+	}
+}
+
 func WithControllerStopTimeout(d time.Duration) ControllerOption {
 	return func(ro *controllerOption) {
 		ro.stopTimeout = d
 	}
 }
 
+// WithControllerMaxConcurrentReconciles sets the maximum number of reconciles
+// that the controller instance runs at the same time.
+// Further messages stay queued in the consumer until a reconcile finishes.
+// The default (zero) does not limit the number of concurrent reconciles.
+func WithControllerMaxConcurrentReconciles(n int) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.maxConcurrentReconciles = n
+	}
+}
+
+// WithControllerRateLimiter sets the rate limiter that decides how long to
+// wait before reconciling an object again, after the reconciler returns an
+// error or a result with Requeue set.
+// The default is [DefaultRateLimiter].
+func WithControllerRateLimiter(limiter RateLimiter) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.rateLimiter = limiter
+	}
+}
+
+// WithControllerMaxRetries sets the maximum number of times that the
+// controller retries an object after the reconciler returns an error.
+// Once exceeded, the controller stops retrying the object until it changes or
+// is re-driven with [RedriveDeadLetter].
+// It sets a ReconcileFailed condition with the reason MaxRetriesExceeded, if
+// the object has conditions in its status (see [Condition]), and writes the
+// object to the dead-letter bucket.
+// The default (zero) retries forever.
+func WithControllerMaxRetries(n int) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.maxRetries = n
+	}
+}
+
+// WithControllerResyncPeriod makes the controller reconcile objects of the
+// For kind again after the period, even if they have not changed, so that the
+// reconciler can detect and fix drift in external systems.
+// A jitter of up to 10% is added to the period, so that objects are not all
+// reconciled at the same time.
+// The default (zero) only reconciles objects when they change.
+func WithControllerResyncPeriod(d time.Duration) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.resyncPeriod = d
+	}
+}
+
+// WithControllerReconcileTimeout sets the maximum time that a reconcile can
+// run for. The context passed to the reconciler is cancelled after the
+// timeout, and the object is retried with the controller's rate limiter.
+// The controller holds the lock on the object until the reconciler returns,
+// for a grace period of 30 seconds.
+//
+// If the object has conditions in its status (see [Condition]), the
+// controller sets a ReconcileFailed condition with the reason
+// ReconcileTimeout.
+// The default is one hour.
+func WithControllerReconcileTimeout(d time.Duration) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.reconcileTimeout = d
+	}
+}
+
 type controllerOption struct {
 	bucketObjects string
 	bucketMutex   string
@@ -79,18 +243,41 @@ type controllerOption struct {
 	validators         []Validator
 	cueValidator       bool
 	validatorForceNone bool
+	validatorPolicy    ValidatorPolicy
 
-	forObject Objecter
-	reconOwns []Objecter
+	forObject    Objecter
+	reconOwns    []Objecter
+	reconWatches []watch
+	predicates   []Predicate
+	versions     []versionConversion
 
-	stopTimeout time.Duration
+	namespaces    []string
+	labelSelector *LabelSelector
+
+	leaderElection *leaderElection
+	sharding       bool
+	// shardSuffix is appended to the consumer names of a sharded instance.
+	shardSuffix string
+
+	stopTimeout             time.Duration
+	maxConcurrentReconciles int
+	rateLimiter             RateLimiter
+	maxRetries              int
+	resyncPeriod            time.Duration
+	reconcileTimeout        time.Duration
 }
 
+// reconcileGracePeriod is how long the controller waits for a reconciler to
+// return after its context is done, before releasing the lock on the object.
+const reconcileGracePeriod = time.Second * 30
+
 var controllerOptionsDefault = controllerOption{
 	bucketObjects: BucketObjects,
 	bucketMutex:   BucketMutex,
 	cueValidator:  true,
 	stopTimeout:   time.Minute * 10,
+
+	reconcileTimeout: time.Hour,
 }
 
 func StartController(
@@ -113,9 +300,66 @@ type Controller struct {
 
 	wg          sync.WaitGroup
 	stopTimeout time.Duration
+	rateLimiter RateLimiter
+	// maxRetries is the number of retries after which an object is
+	// dead-lettered, or zero to retry forever.
+	maxRetries int
+	// deadLetters is the dead-letter bucket, if the controller has max
+	// retries.
+	deadLetters jetstream.KeyValue
+	// resync is the rate limiter for resyncing objects, if the controller
+	// resyncs objects.
+	resync RateLimiter
+	// resyncs is the resyncs bucket, if the controller resyncs objects.
+	resyncs jetstream.KeyValue
+
+	reconcileTimeout time.Duration
+	predicates       []Predicate
+	seen             seenObjects
+	// conditionRetries are the retries of objects that got a
+	// ReconcileFailed condition.
+	conditionRetries conditionRetries
+	// namespaces are the namespaces of the objects that the controller
+	// reconciles, or empty for all namespaces.
+	namespaces []string
+	// labelSelector selects the objects that the controller reconciles, if
+	// set.
+	labelSelector *LabelSelector
+	// events records events for the objects that are reconciled.
+	events *EventRecorder
+	// conditions is true if the objects of the controller's kind have
+	// conditions in their status.
+	conditions bool
+	// stopped is closed when the controller is stopped.
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// reconcileSlots limits the number of concurrent reconciles, if the
+	// controller has a limit.
+	reconcileSlots chan struct{}
+
+	// elector is the leader elector, if the controller uses leader election.
+	elector *LeaderElector
+	// shards are the instances of the controller, if it uses sharding.
+	shards *shards
+	// shardingDone is closed when the controller stops rebalancing its
+	// shards.
+	shardingDone chan struct{}
+
+	// instanceID identifies the controller instance in the controller
+	// registry.
+	instanceID string
+	stats      controllerStats
+	// heartbeatDone is closed when the controller stops writing its status
+	// to the controller registry.
+	heartbeatDone chan struct{}
+
+	subscriptions []*nats.Subscription
 
-	subscriptions   []*nats.Subscription
+	mu              sync.Mutex
 	consumeContexts []jetstream.ConsumeContext
+	// requeue requeues objects in the reconciler, if it runs in this
+	// instance.
+	requeue requeueFunc
 }
 
 func (c *Controller) Start(
@@ -129,8 +373,65 @@ func (c *Controller) Start(
 	if ro.forObject == nil {
 		return fmt.Errorf("no object provided to controller")
 	}
+	if ro.reconcileTimeout <= 0 {
+		return fmt.Errorf(
+			"invalid reconcile timeout: %s",
+			ro.reconcileTimeout,
+		)
+	}
+	if err := validateWatches(ro.reconWatches); err != nil {
+		return err
+	}
+	if ro.maxConcurrentReconciles < 0 {
+		return fmt.Errorf(
+			"invalid max concurrent reconciles: %d",
+			ro.maxConcurrentReconciles,
+		)
+	}
+	if ro.maxRetries < 0 {
+		return fmt.Errorf("invalid max retries: %d", ro.maxRetries)
+	}
+	if ro.sharding && ro.leaderElection != nil {
+		return fmt.Errorf("sharding cannot be used with leader election")
+	}
+	for _, ns := range ro.namespaces {
+		if ns == "" || strings.ContainsAny(ns, ".*> ") {
+			return fmt.Errorf("invalid namespace: %q", ns)
+		}
+	}
 
 	c.stopTimeout = ro.stopTimeout
+	c.reconcileTimeout = ro.reconcileTimeout
+	c.conditions = hasStatusConditions(ro.forObject)
+	c.predicates = ro.predicates
+	c.maxRetries = ro.maxRetries
+	c.namespaces = ro.namespaces
+	c.labelSelector = ro.labelSelector
+	c.rateLimiter = ro.rateLimiter
+	if c.rateLimiter == nil {
+		c.rateLimiter = DefaultRateLimiter()
+	}
+	if ro.resyncPeriod > 0 {
+		c.resync = &JitterRateLimiter{
+			RateLimiter: &FixedIntervalRateLimiter{Interval: ro.resyncPeriod},
+			Factor:      0.1,
+		}
+	}
+	c.stopped = make(chan struct{})
+	c.instanceID = uuid.NewString()
+	c.events = &EventRecorder{
+		Client: NewClient(
+			c.Conn,
+			WithClientInternal(true),
+			WithClientManager(
+				"ctlr-"+ro.forObject.ObjectKind()+eventManagerSuffix,
+			),
+		),
+		Source: "ctlr-" + ro.forObject.ObjectKind(),
+	}
+	if ro.maxConcurrentReconciles > 0 {
+		c.reconcileSlots = make(chan struct{}, ro.maxConcurrentReconciles)
+	}
 	// Check the forObject value is not a pointer, as this causes problems for
 	// the cue encoder. If it is a pointer, get its element.
 	if reflect.ValueOf(ro.forObject).Type().Kind() == reflect.Ptr {
@@ -142,6 +443,20 @@ func (c *Controller) Start(
 			return fmt.Errorf("getting element from object pointer")
 		}
 	}
+	// Same for the objects of the served versions.
+	ro.versions = slices.Clone(ro.versions)
+	for i, conv := range ro.versions {
+		if reflect.ValueOf(conv.object).Type().Kind() != reflect.Ptr {
+			continue
+		}
+		var ok bool
+		ro.versions[i].object, ok = reflect.ValueOf(conv.object).
+			Elem().
+			Interface().(Objecter)
+		if !ok {
+			return fmt.Errorf("getting element from object pointer")
+		}
+	}
 	if ro.bucketMutex == "" {
 		ro.bucketMutex = ro.bucketObjects + "_mutex"
 	}
@@ -159,11 +474,32 @@ func (c *Controller) Start(
 	if err := c.startSchema(ctx, ro); err != nil {
 		return fmt.Errorf("start schema: %w", err)
 	}
+	if err := c.startConversion(ctx, ro); err != nil {
+		return fmt.Errorf("start conversion: %w", err)
+	}
 
 	if err := c.startValidators(ctx, ro); err != nil {
 		return fmt.Errorf("start validator: %w", err)
 	}
+	if err := c.startHeartbeat(ctx, ro); err != nil {
+		return fmt.Errorf("start heartbeat: %w", err)
+	}
 	if ro.reconciler != nil {
+		if err := c.startRedrive(ro); err != nil {
+			return fmt.Errorf("start redrive: %w", err)
+		}
+		if ro.leaderElection != nil {
+			return c.startLeaderElection(ctx, ro)
+		}
+		if ro.sharding {
+			if c.heartbeatDone == nil {
+				return fmt.Errorf(
+					"sharding requires the controller registry %q",
+					BucketControllers,
+				)
+			}
+			return c.startSharding(ctx, ro)
+		}
 		if err := c.startReconciler(ctx, ro); err != nil {
 			return fmt.Errorf("start reconciler: %w", err)
 		}
@@ -171,97 +507,184 @@ func (c *Controller) Start(
 	return nil
 }
 
-func (c *Controller) startSchema(
-	_ context.Context,
-	opt controllerOption,
+// startLeaderElection starts the reconciler when the controller becomes the
+// leader, and stops it when the controller stops being the leader.
+func (c *Controller) startLeaderElection(
+	ctx context.Context,
+	ro controllerOption,
 ) error {
-	obj := opt.forObject
-	objSpec, err := OpenAPISpecFromObject(obj)
-	if err != nil {
-		return fmt.Errorf("getting object spec: %w", err)
-	}
-	schema, err := objSpec.Schema()
-	if err != nil {
-		return fmt.Errorf("getting schema: %w", err)
-	}
-	bSchema, err := json.Marshal(schema)
-	if err != nil {
-		return fmt.Errorf("marshalling schema: %w", err)
+	opts := []LeaderElectorOption{
+		WithLeaderElectorOnGained(func(leaderCtx context.Context) {
+			// The reconcile loops use the leader context, so that
+			// reconciles in progress are cancelled and retried by the new
+			// leader when the leadership is lost.
+			if err := c.startReconciler(leaderCtx, ro); err != nil {
+				slog.Error(
+					"starting reconciler",
+					"kind", ro.forObject.ObjectKind(),
+					"error", err,
+				)
+			}
+		}),
+		WithLeaderElectorOnLost(func() {
+			c.stopConsumeContexts()
+		}),
 	}
-	subject := fmt.Sprintf(
-		SubjectCtlrSchema,
-		obj.ObjectGroup(),
-		obj.ObjectVersion(),
-		obj.ObjectKind(),
+	elector, err := StartLeaderElector(
+		ctx,
+		c.Conn,
+		ro.leaderElection.name,
+		append(opts, ro.leaderElection.opts...)...,
 	)
-	sub, err := c.Conn.QueueSubscribe(subject, "schema", func(msg *nats.Msg) {
-		go func() {
-			_ = msg.Respond(bSchema)
-		}()
-	})
 	if err != nil {
-		return fmt.Errorf("subscribing validator: %w", err)
+		return fmt.Errorf("start leader election: %w", err)
 	}
-	c.subscriptions = append(c.subscriptions, sub)
+	c.elector = elector
 	return nil
 }
 
-// startValidators subscribes to the validator subjects and validates objects as
-// they come in.
-func (c *Controller) startValidators(
+// IsLeader returns true if the controller runs its reconciler, i.e. it is the
+// leader or does not use leader election.
+func (c *Controller) IsLeader() bool {
+	if c.elector == nil {
+		return true
+	}
+	return c.elector.IsLeader()
+}
+
+func (c *Controller) addConsumeContext(cc jetstream.ConsumeContext) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.consumeContexts = append(c.consumeContexts, cc)
+}
+
+// stopConsumeContexts stops the consumers of the reconciler.
+func (c *Controller) stopConsumeContexts() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cc := range c.consumeContexts {
+		cc.Stop()
+	}
+	c.consumeContexts = nil
+	c.requeue = nil
+}
+
+func (c *Controller) startSchema(
 	ctx context.Context,
 	opt controllerOption,
 ) error {
-	obj := opt.forObject
-	{
-		subject := fmt.Sprintf(
-			SubjectCtlrValidateCreate,
-			obj.ObjectGroup(),
-			obj.ObjectVersion(),
-			obj.ObjectKind(),
-		)
-		sub, err := c.Conn.QueueSubscribe(
-			subject,
-			"validate-create",
-			func(msg *nats.Msg) {
-				go c.handleValidateCreate(ctx, opt, msg)
-			},
-		)
+	if err := c.registerMergeSchemas(ctx, opt); err != nil {
+		return fmt.Errorf("registering merge schemas: %w", err)
+	}
+	// Serve a schema for each version of the kind.
+	for _, obj := range opt.servedObjects() {
+		objSpec, err := OpenAPISpecFromObject(obj)
 		if err != nil {
-			return fmt.Errorf("subscribing validator %q: %w", subject, err)
+			return fmt.Errorf("getting object spec: %w", err)
+		}
+		schema, err := objSpec.Schema()
+		if err != nil {
+			return fmt.Errorf("getting schema: %w", err)
+		}
+		bSchema, err := json.Marshal(schema)
+		if err != nil {
+			return fmt.Errorf("marshalling schema: %w", err)
 		}
-		c.subscriptions = append(c.subscriptions, sub)
-	}
-	{
 		subject := fmt.Sprintf(
-			SubjectCtlrValidateUpdate,
+			SubjectCtlrSchema,
 			obj.ObjectGroup(),
 			obj.ObjectVersion(),
 			obj.ObjectKind(),
 		)
 		sub, err := c.Conn.QueueSubscribe(
 			subject,
-			"validate-update",
+			"schema",
 			func(msg *nats.Msg) {
-				go c.handleValidateUpdate(ctx, opt, msg)
+				go func() {
+					_ = msg.Respond(bSchema)
+				}()
 			},
 		)
 		if err != nil {
-			return fmt.Errorf("subscribing validator %q: %w", subject, err)
+			return fmt.Errorf("subscribing validator: %w", err)
 		}
 		c.subscriptions = append(c.subscriptions, sub)
 	}
 	return nil
 }
 
+// startValidators subscribes to the validator subjects and validates objects as
+// they come in.
+// Objects of any served version are converted to the storage version before
+// being validated, so validators only deal with the storage version.
+func (c *Controller) startValidators(
+	ctx context.Context,
+	opt controllerOption,
+) error {
+	if err := c.registerValidatorPolicy(ctx, opt); err != nil {
+		return fmt.Errorf("registering validator policy: %w", err)
+	}
+	for _, obj := range opt.servedObjects() {
+		{
+			subject := fmt.Sprintf(
+				SubjectCtlrValidateCreate,
+				obj.ObjectGroup(),
+				obj.ObjectVersion(),
+				obj.ObjectKind(),
+			)
+			sub, err := c.Conn.QueueSubscribe(
+				subject,
+				"validate-create",
+				func(msg *nats.Msg) {
+					go c.handleValidateCreate(ctx, opt, msg)
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("subscribing validator %q: %w", subject, err)
+			}
+			c.subscriptions = append(c.subscriptions, sub)
+		}
+		{
+			subject := fmt.Sprintf(
+				SubjectCtlrValidateUpdate,
+				obj.ObjectGroup(),
+				obj.ObjectVersion(),
+				obj.ObjectKind(),
+			)
+			sub, err := c.Conn.QueueSubscribe(
+				subject,
+				"validate-update",
+				func(msg *nats.Msg) {
+					go c.handleValidateUpdate(ctx, opt, msg)
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("subscribing validator %q: %w", subject, err)
+			}
+			c.subscriptions = append(c.subscriptions, sub)
+		}
+	}
+	return nil
+}
+
 func (c *Controller) handleValidateCreate(
 	ctx context.Context,
 	opt controllerOption,
 	msg *nats.Msg,
 ) {
+	ctx, span := startValidateSpan(ctx, "controller.validate_create", opt, msg)
+	defer span.End()
+	data, err := opt.convert(msg.Data, opt.forObject.ObjectVersion())
+	if err != nil {
+		_ = RespondError(msg, &Error{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("converting object: %s", err.Error()),
+		})
+		return
+	}
 	var vErr *Error
 	for _, validator := range opt.validators {
-		if err := validator.ValidateCreate(ctx, msg.Data); err != nil {
+		if err := validator.ValidateCreate(ctx, data); err != nil {
 			vErr = &Error{
 				Status:  http.StatusBadRequest,
 				Message: err.Error(),
@@ -271,6 +694,7 @@ func (c *Controller) handleValidateCreate(
 		}
 	}
 	if vErr != nil {
+		TraceError(span, vErr)
 		_ = RespondError(msg, vErr)
 		return
 	}
@@ -282,6 +706,8 @@ func (c *Controller) handleValidateUpdate(
 	opt controllerOption,
 	msg *nats.Msg,
 ) {
+	ctx, span := startValidateSpan(ctx, "controller.validate_update", opt, msg)
+	defer span.End()
 	var metaObj MetaOnlyObject
 	if err := json.Unmarshal(msg.Data, &metaObj); err != nil {
 		_ = RespondError(msg, &Error{
@@ -306,9 +732,29 @@ func (c *Controller) handleValidateUpdate(
 		})
 		return
 	}
+	storageVersion := opt.forObject.ObjectVersion()
+	data, err := opt.convert(msg.Data, storageVersion)
+	if err != nil {
+		_ = RespondError(msg, &Error{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("converting object: %s", err.Error()),
+		})
+		return
+	}
+	old, err = opt.convert(old, storageVersion)
+	if err != nil {
+		_ = RespondError(msg, &Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"converting existing object: %s",
+				err.Error(),
+			),
+		})
+		return
+	}
 	var vErr *Error
 	for _, validator := range opt.validators {
-		if err := validator.ValidateUpdate(ctx, old, msg.Data); err != nil {
+		if err := validator.ValidateUpdate(ctx, old, data); err != nil {
 			vErr = &Error{
 				Status:  http.StatusBadRequest,
 				Message: err.Error(),
@@ -318,12 +764,104 @@ func (c *Controller) handleValidateUpdate(
 		}
 	}
 	if vErr != nil {
+		TraceError(span, vErr)
 		_ = RespondError(msg, vErr)
 		return
 	}
 	_ = RespondOK(msg, nil)
 }
 
+// registerValidatorPolicy stores the validator policy for the controller's
+// kind, so that the store knows how to call the validators.
+func (c *Controller) registerValidatorPolicy(
+	ctx context.Context,
+	opt controllerOption,
+) error {
+	js, err := jetstream.New(c.Conn)
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := keyValue(ctx, js, jetstream.KeyValueConfig{
+		Bucket:      BucketValidators,
+		Description: "Validator policies for " + BucketObjects,
+		History:     1,
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(opt.validatorPolicy)
+	if err != nil {
+		return fmt.Errorf("marshalling policy: %w", err)
+	}
+	if _, err := kv.Put(
+		ctx,
+		ValidatorPolicyKey(opt.forObject),
+		data,
+	); err != nil {
+		return fmt.Errorf("putting policy: %w", err)
+	}
+	return nil
+}
+
+// registerMergeSchemas puts the merge schema of each served version of the
+// kind into the merge schemas bucket, so that the store can merge lists
+// according to the list types of the kind during server-side apply.
+func (c *Controller) registerMergeSchemas(
+	ctx context.Context,
+	opt controllerOption,
+) error {
+	js, err := jetstream.New(c.Conn)
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := keyValue(ctx, js, jetstream.KeyValueConfig{
+		Bucket:      BucketMergeSchemas,
+		Description: "Merge schemas for " + BucketObjects,
+		History:     1,
+	})
+	if err != nil {
+		return err
+	}
+	for _, obj := range opt.servedObjects() {
+		schema, err := mergeSchemaFromObject(obj)
+		if err != nil {
+			return fmt.Errorf(
+				"getting merge schema for version %q: %w",
+				obj.ObjectVersion(),
+				err,
+			)
+		}
+		data, err := json.Marshal(schema)
+		if err != nil {
+			return fmt.Errorf("marshalling merge schema: %w", err)
+		}
+		if _, err := kv.Put(ctx, MergeSchemaKey(obj), data); err != nil {
+			return fmt.Errorf("putting merge schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// startValidateSpan starts a span for validating an object, continuing the
+// trace from the store.
+func startValidateSpan(
+	ctx context.Context,
+	name string,
+	opt controllerOption,
+	msg *nats.Msg,
+) (context.Context, trace.Span) {
+	ctx = TraceExtract(ctx, msg.Header)
+	return Tracer().Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("hz.group", opt.forObject.ObjectGroup()),
+			attribute.String("hz.kind", opt.forObject.ObjectKind()),
+		),
+	)
+}
+
 func (c *Controller) startReconciler(
 	ctx context.Context,
 	opt controllerOption,
@@ -344,6 +882,28 @@ func (c *Controller) startReconciler(
 	if err != nil {
 		return fmt.Errorf("obtaining mutex: %w", err)
 	}
+	if c.maxRetries > 0 {
+		deadLetters, err := keyValue(ctx, js, jetstream.KeyValueConfig{
+			Bucket:      BucketDeadLetters,
+			Description: "Objects that controllers stopped retrying.",
+			History:     1,
+		})
+		if err != nil {
+			return err
+		}
+		c.deadLetters = deadLetters
+	}
+	if c.resync != nil {
+		resyncs, err := keyValue(ctx, js, jetstream.KeyValueConfig{
+			Bucket:      BucketResyncs,
+			Description: "Deliveries of objects that were resyncs.",
+			History:     1,
+		})
+		if err != nil {
+			return err
+		}
+		c.resyncs = resyncs
+	}
 
 	ttl := mutex.ttl
 
@@ -352,20 +912,20 @@ func (c *Controller) startReconciler(
 	if err != nil {
 		return fmt.Errorf("stream: %w", err)
 	}
-	subject := "$KV." + kv.Bucket() + "." + KeyFromObject(forObj)
 	con, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
-		Name:           "rc_" + forObj.ObjectKind(),
-		Durable:        "rc_" + forObj.ObjectKind(),
+		Name:           opt.consumerName(""),
+		Durable:        opt.durableName(),
 		Description:    "Reconciler for " + forObj.ObjectKind(),
 		AckPolicy:      jetstream.AckExplicitPolicy,
 		DeliverPolicy:  jetstream.DeliverLastPerSubjectPolicy,
-		FilterSubjects: []string{subject},
+		FilterSubjects: opt.forSubjects(kv.Bucket()),
 		MaxAckPending:  -1,
 		// AckWait specifies how long a consumer waits before considering a
 		// message delivered to a consumer as lost.
 		// Hence, the consumer needs to ack/nak or mark the msg as in progress
 		// before this time expires.
-		AckWait: ttl,
+		AckWait:           ttl,
+		InactiveThreshold: opt.inactiveThreshold(),
 		// MaxAckPendingPerSubject would allow only one concurrent consume loop
 		// *per consumer*, which still does not solve everything.
 		// We need one concurrent consume loop per object, including reconcile
@@ -385,11 +945,26 @@ func (c *Controller) startReconciler(
 			// metadata.deletionTimestamp. In the kv store, a delete operation
 			// means the whole object is gone (i.e. what horizon's considers
 			// a purge).
+			c.forgetResyncs(ctx, keyFromMsgSubject(kv, msg))
+			c.seen.forget(keyFromMsgSubject(kv, msg))
+			c.conditionRetries.forget(keyFromMsgSubject(kv, msg))
+			c.clearDeadLetter(ctx, keyFromMsgSubject(kv, msg))
 			_ = msg.Ack()
 			return
 		}
 		key := keyFromMsgSubject(kv, msg)
-		go c.handleControlLoop(
+		// A revision that only sets the ReconcileFailed condition replaces
+		// the message of the failed revision, so it is retried instead.
+		if delay, ok := c.conditionRetryDelay(key, msg); ok {
+			_ = msg.NakWithDelay(delay)
+			return
+		}
+		if !c.allowMsg(key, msg) {
+			metricFilteredEvents.WithLabelValues(forObj.ObjectKind()).Inc()
+			_ = msg.Ack()
+			return
+		}
+		c.startControlLoop(
 			ctx,
 			opt.reconciler,
 			kv,
@@ -398,16 +973,16 @@ func (c *Controller) startReconciler(
 			msg,
 			ttl,
 		)
-	})
+	}, opt.consumeOpts()...)
 	if err != nil {
 		return fmt.Errorf("consume: %w", err)
 	}
-	c.consumeContexts = append(c.consumeContexts, cc)
+	c.addConsumeContext(cc)
 
 	for _, obj := range opt.reconOwns {
 		subject := "$KV." + kv.Bucket() + "." + KeyFromObject(obj)
 		con, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
-			Name:           "rc_" + forObj.ObjectKind() + "_o_" + obj.ObjectKind(),
+			Name:           opt.consumerName("_o_" + obj.ObjectKind()),
 			Description:    "Reconciler for " + forObj.ObjectKind() + " owns " + obj.ObjectKind(),
 			DeliverPolicy:  jetstream.DeliverLastPerSubjectPolicy,
 			FilterSubjects: []string{subject},
@@ -416,7 +991,8 @@ func (c *Controller) startReconciler(
 			// message delivered to a consumer as lost.
 			// Hence, the consumer needs to ack/nak or mark the msg as in
 			// progress before this time expires.
-			AckWait: ttl,
+			AckWait:           ttl,
+			InactiveThreshold: opt.inactiveThreshold(),
 		})
 		if err != nil {
 			return fmt.Errorf("create owns consumer: %w", err)
@@ -455,7 +1031,7 @@ func (c *Controller) startReconciler(
 				Name:      ownerRef.Name,
 				Namespace: ownerRef.Namespace,
 			})
-			go c.handleControlLoop(
+			c.startControlLoop(
 				ctx,
 				opt.reconciler,
 				kv,
@@ -464,26 +1040,48 @@ func (c *Controller) startReconciler(
 				msg,
 				ttl,
 			)
-		})
+		}, opt.consumeOpts()...)
 		if err != nil {
 			return fmt.Errorf("consume: %w", err)
 		}
-		c.consumeContexts = append(c.consumeContexts, cc)
+		c.addConsumeContext(cc)
 	}
 
+	for _, w := range opt.reconWatches {
+		if err := c.startWatch(ctx, opt, stream, kv, mutex, w); err != nil {
+			return err
+		}
+	}
+	c.setRequeue(c.requeuer(ctx, opt.reconciler, kv, mutex, ttl))
 	return nil
 }
 
 func (c *Controller) Stop() error {
 	var errs error
-	for _, cc := range c.consumeContexts {
-		cc.Stop()
+	if c.elector != nil {
+		if err := c.elector.Stop(); err != nil {
+			errs = errors.Join(errs, err)
+		}
 	}
+	c.stopConsumeContexts()
+	c.stopOnce.Do(func() {
+		if c.stopped != nil {
+			close(c.stopped)
+		}
+	})
 	for _, sub := range c.subscriptions {
 		if err := sub.Unsubscribe(); err != nil {
 			errs = errors.Join(errs, err)
 		}
 	}
+	if c.heartbeatDone != nil {
+		<-c.heartbeatDone
+	}
+	if c.shardingDone != nil {
+		// Stop the consumers that a rebalance started while stopping.
+		<-c.shardingDone
+		c.stopConsumeContexts()
+	}
 
 	// Wait for all reconcile loops to finish, with a timeout.
 	if c.stopWaitTimeout() {
@@ -526,6 +1124,159 @@ func (c *Controller) stopWaitTimeout() bool {
 	}
 }
 
+// consumerName returns the name of a reconciler consumer, with the suffix for
+// owned and watched objects.
+func (opt controllerOption) consumerName(suffix string) string {
+	return opt.consumerPrefix() + suffix + opt.shardSuffix
+}
+
+// durableName returns the durable name of the consumer for the controller's
+// kind, which is shared by the instances of the controller.
+// Sharded instances have their own consumers, which are not durable.
+func (opt controllerOption) durableName() string {
+	if opt.shardSuffix != "" {
+		return ""
+	}
+	return opt.consumerName("")
+}
+
+// inactiveThreshold returns how long the reconciler consumers live without
+// being consumed from.
+// The consumers of sharded instances are deleted, e.g. after the instance
+// crashed, and the other consumers are kept.
+func (opt controllerOption) inactiveThreshold() time.Duration {
+	if opt.shardSuffix != "" {
+		return shardConsumerInactiveThreshold
+	}
+	return 0
+}
+
+// consumerPrefix returns the name of the consumer for the controller's kind,
+// which prefixes the names of the other reconciler consumers.
+//
+// Controllers that are scoped to namespaces or labels have their own
+// consumers, so that controllers with different scopes do not share messages.
+func (opt controllerOption) consumerPrefix() string {
+	name := "rc_" + opt.forObject.ObjectKind()
+	if len(opt.namespaces) > 0 || opt.labelSelector != nil {
+		namespaces := slices.Clone(opt.namespaces)
+		slices.Sort(namespaces)
+		scope, _ := json.Marshal(struct {
+			Namespaces    []string       `json:"namespaces"`
+			LabelSelector *LabelSelector `json:"labelSelector"`
+		}{
+			Namespaces:    slices.Compact(namespaces),
+			LabelSelector: opt.labelSelector,
+		})
+		sum := sha256.Sum256(scope)
+		name += "_s_" + hex.EncodeToString(sum[:])[:8]
+	}
+	return name
+}
+
+// forSubjects returns the subjects of the objects of the controller's kind,
+// in the controller's namespaces.
+func (opt controllerOption) forSubjects(bucket string) []string {
+	forObj := opt.forObject
+	if len(opt.namespaces) == 0 {
+		return []string{"$KV." + bucket + "." + KeyFromObject(forObj)}
+	}
+	subjects := make([]string, 0, len(opt.namespaces))
+	for _, ns := range opt.namespaces {
+		subject := "$KV." + bucket + "." + KeyFromObject(ObjectKey{
+			Group:     forObj.ObjectGroup(),
+			Version:   forObj.ObjectVersion(),
+			Kind:      forObj.ObjectKind(),
+			Namespace: ns,
+		})
+		if !slices.Contains(subjects, subject) {
+			subjects = append(subjects, subject)
+		}
+	}
+	return subjects
+}
+
+// inScope returns true if the object is in the controller's namespaces, and
+// its labels match the controller's label selector.
+func (c *Controller) inScope(
+	ctx context.Context,
+	kv jetstream.KeyValue,
+	key string,
+	objKey ObjectKey,
+) (bool, error) {
+	if len(c.namespaces) > 0 &&
+		!slices.Contains(c.namespaces, objKey.Namespace) {
+		return false, nil
+	}
+	if c.labelSelector == nil {
+		return true, nil
+	}
+	kve, err := kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting object: %w", err)
+	}
+	var obj MetaOnlyObject
+	if err := json.Unmarshal(kve.Value(), &obj); err != nil {
+		return false, fmt.Errorf("unmarshalling object: %w", err)
+	}
+	return c.labelSelector.Matches(obj.Labels), nil
+}
+
+// consumeOpts returns the options for the reconciler consumers.
+func (opt controllerOption) consumeOpts() []jetstream.PullConsumeOpt {
+	if opt.maxConcurrentReconciles == 0 {
+		return nil
+	}
+	// Only buffer as many messages as can be reconciled, so that the rest of
+	// the backlog stays in the stream.
+	return []jetstream.PullConsumeOpt{
+		jetstream.PullMaxMessages(opt.maxConcurrentReconciles),
+	}
+}
+
+// startControlLoop starts the control loop for the message in a new
+// goroutine.
+//
+// If the number of concurrent reconciles is limited, it blocks until a
+// reconcile finishes.
+// It is called from the consumer callbacks, so blocking keeps further
+// messages queued in the consumer.
+func (c *Controller) startControlLoop(
+	ctx context.Context,
+	reconciler Reconciler,
+	kv jetstream.KeyValue,
+	mutex mutex,
+	key string,
+	msg jetstream.Msg,
+	ttl time.Duration,
+) {
+	if c.reconcileSlots != nil {
+		select {
+		case c.reconcileSlots <- struct{}{}:
+		case <-c.stopped:
+			// Let another controller instance handle the message.
+			_ = msg.Nak()
+			return
+		}
+		// The message might have been waiting for a while, so reset its
+		// AckWait.
+		_ = msg.InProgress()
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		if c.reconcileSlots != nil {
+			defer func() {
+				<-c.reconcileSlots
+			}()
+		}
+		c.handleControlLoop(ctx, reconciler, kv, mutex, key, msg, ttl)
+	}()
+}
+
 // handleControlLoop is the main control loop for the controller.
 // - kv is the kv store that the controller is watching
 // - mutex is the mutex bucket for the kv store
@@ -545,8 +1296,12 @@ func (c *Controller) handleControlLoop(
 	msg jetstream.Msg,
 	ttl time.Duration,
 ) {
-	c.wg.Add(1)
-	defer c.wg.Done()
+	// Only reconcile the keys in the instance's shard, if it is sharded.
+	// The instance that owns the key has its own message for it.
+	if c.shards != nil && !c.shards.owns(key) {
+		_ = msg.Ack()
+		return
+	}
 	// Check that the message is the last message for the subject.
 	// If not, we don't care about it and want to avoid acquiring the lock.
 	isLast, err := isLastMsg(ctx, kv, msg)
@@ -554,6 +1309,7 @@ func (c *Controller) handleControlLoop(
 		if errors.Is(err, jetstream.ErrKeyNotFound) {
 			// Could be that the key was deleted, which is fine.
 			// Ack the message and return.
+			c.forgetResyncs(ctx, key)
 			_ = msg.Ack()
 			return
 		}
@@ -563,7 +1319,14 @@ func (c *Controller) handleControlLoop(
 	}
 	// If message is not the last message, we don't care about it.
 	// Ack the message and return.
-	if !isLast {
+	// However, if the predicates filtered out the last message, this message
+	// is the only one left to reconcile the object (e.g. a retry).
+	if !isLast && !c.newerMsgFiltered(ctx, kv, key, msg) {
+		_ = msg.Ack()
+		return
+	}
+	// If the object was dead-lettered, do not retry it until it changes.
+	if c.isDeadLettered(ctx, key, msg) {
 		_ = msg.Ack()
 		return
 	}
@@ -574,11 +1337,25 @@ func (c *Controller) handleControlLoop(
 		_ = msg.NakWithDelay(time.Second)
 		return
 	}
+	// Only reconcile objects in the controller's namespaces and label
+	// selector.
+	inScope, err := c.inScope(ctx, kv, key, objKey)
+	if err != nil {
+		slog.Error("checking controller scope", "key", key, "error", err)
+		_ = msg.NakWithDelay(time.Second)
+		return
+	}
+	if !inScope {
+		_ = msg.Ack()
+		return
+	}
 	// Acquire lock from the mutex.
 	lock, err := mutex.Lock(ctx, key)
 	if err != nil {
 		if errors.Is(err, ErrKeyLocked) {
 			// Someone else has the lock, which is fine.
+			// Sharded instances only contend for locks while the keys are
+			// rebalanced.
 			// Set some reconcile time and finish gracefully.
 			// The control loop should start and wait for the lock again.
 			_ = msg.NakWithDelay(time.Second)
@@ -597,43 +1374,93 @@ func (c *Controller) handleControlLoop(
 		}
 	}()
 
+	// Continue the trace of the request that changed the object.
+	ctx, span := Tracer().Start(
+		TraceExtract(ctx, msg.Headers()),
+		"controller.reconcile",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(TraceAttributes(objKey)...),
+	)
+	defer span.End()
+
 	// Prepare the request and call the reconciler.
 	req := Request{
 		Key: objKey,
 	}
-	var (
-		reconcileResult Result
-		reconcileErr    error
-		reconcileDone   = make(chan struct{})
-	)
+	// Create a context with the reconcile timeout.
+	// This is the max time a reconciler can run for.
+	reconcileCtx, cancel := context.WithTimeout(ctx, c.reconcileTimeout)
+	reconcileCtx = ContextWithEventRecorder(reconcileCtx, c.events)
+	defer cancel()
+	type reconcileOutcome struct {
+		result Result
+		err    error
+	}
+	// The channel is buffered so that a reconciler that finishes after the
+	// timeout does not block forever.
+	reconcileDone := make(chan reconcileOutcome, 1)
 	reconcile := func() {
-		// Close the channel when the reconciler is done.
+		var outcome reconcileOutcome
+		// Send the outcome when the reconciler is done.
 		defer func() {
-			close(reconcileDone)
 			// In case the reconciler panics, we want to recover and redeliver
 			// the message within a timely manner.
 			if err := recover(); err != nil {
-				reconcileErr = fmt.Errorf("panic: %v: %s", err, debug.Stack())
+				outcome.err = fmt.Errorf("panic: %v: %s", err, debug.Stack())
 			}
+			reconcileDone <- outcome
 		}()
-		// Create a context with a hard timeout.
-		// This is the max time a reconciler can run for.
-		hardTimeout := time.Hour
-		ctx, cancel := context.WithTimeout(ctx, hardTimeout)
-		defer cancel()
-		reconcileResult, reconcileErr = reconciler.Reconcile(ctx, req)
+		outcome.result, outcome.err = reconciler.Reconcile(reconcileCtx, req)
 	}
 	slog.Info("reconciling object", "key", key)
+	reconcileStart := time.Now()
+	metricActiveReconciles.WithLabelValues(objKey.Kind).Inc()
+	c.stats.active.Add(1)
 	go reconcile()
 
 	// Setup an auto-ticker for the message, which keeps the message alive and
 	// avoids the consumer AckWait or lock TTL expiring.
+	// Once the reconcile context is done (e.g. after the reconcile timeout),
+	// the message and lock are kept alive for a grace period, so that the
+	// reconciler can return before the lock is released.
+	// A reconciler that does not return within the grace period does not hold
+	// the lock any longer.
+	var (
+		reconcileResult Result
+		reconcileErr    error
+	)
 	inProgressTicker := func() {
 		ticker := time.NewTicker(ttl / 2)
 		defer ticker.Stop()
+		reconcileCtxDone := reconcileCtx.Done()
+		var graceExpired <-chan time.Time
 		for {
 			select {
-			case <-ctx.Done():
+			case <-reconcileCtxDone:
+				if errors.Is(reconcileCtx.Err(), context.DeadlineExceeded) {
+					reconcileErr = fmt.Errorf(
+						"%w: after %s",
+						ErrReconcileTimeout,
+						c.reconcileTimeout,
+					)
+				} else {
+					// The controller is stopping, so let the object be
+					// reconciled again.
+					reconcileErr = fmt.Errorf(
+						"reconcile: %w",
+						reconcileCtx.Err(),
+					)
+				}
+				reconcileCtxDone = nil
+				graceTimer := time.NewTimer(reconcileGracePeriod)
+				defer graceTimer.Stop()
+				graceExpired = graceTimer.C
+			case <-graceExpired:
+				slog.Error(
+					"reconciler did not return after its context was done",
+					"key", key,
+					"grace_period", reconcileGracePeriod,
+				)
 				return
 			case <-ticker.C:
 				slog.Info("ticker in progress")
@@ -643,19 +1470,46 @@ func (c *Controller) handleControlLoop(
 				if err := msg.InProgress(); err != nil {
 					slog.Error("marking  message in progress", "error", err)
 				}
-			case <-reconcileDone:
+			case outcome := <-reconcileDone:
+				// Once the reconcile context is done, the reconcile failed,
+				// whatever the reconciler returned.
+				if reconcileErr == nil {
+					reconcileResult, reconcileErr = outcome.result, outcome.err
+				}
 				return
 			}
 		}
 	}
 	inProgressTicker()
+	metricActiveReconciles.WithLabelValues(objKey.Kind).Dec()
+	metricReconciles.WithLabelValues(objKey.Kind).Inc()
+	c.stats.active.Add(-1)
+	c.stats.reconciles.Add(1)
+	metricReconcileDuration.WithLabelValues(objKey.Kind).
+		Observe(time.Since(reconcileStart).Seconds())
 	if reconcileErr != nil {
-		backoff, err := exponentialBackoff(msg)
+		metricReconcileErrors.WithLabelValues(objKey.Kind).Inc()
+		c.stats.recordError(reconcileErr)
+		TraceError(span, reconcileErr)
+		timedOut := errors.Is(reconcileErr, ErrReconcileTimeout)
+		if timedOut {
+			c.reconcileTimedOut(ctx, objKey, reconcileErr)
+		}
+		attempt, err := c.attempt(ctx, key, msg)
 		if err != nil {
-			slog.Error("getting exponential backoff", "error", err)
+			slog.Error("getting reconcile attempt", "error", err)
 			_ = msg.NakWithDelay(time.Second * 10)
 			return
 		}
+		if c.maxRetries > 0 && attempt > c.maxRetries {
+			c.conditionRetries.forget(key)
+			c.deadLetter(ctx, kv, key, objKey, attempt, reconcileErr, msg)
+			return
+		}
+		backoff := c.rateLimiter.When(req, attempt)
+		if timedOut && c.conditions {
+			c.setTimeoutCondition(ctx, key, objKey, reconcileErr, attempt, backoff)
+		}
 		slog.Error(
 			"reconcile",
 			"key",
@@ -669,23 +1523,46 @@ func (c *Controller) handleControlLoop(
 		return
 	}
 
+	// If a previous reconcile of the object failed, clear the failure.
+	if c.conditions {
+		if err := c.clearReconcileFailed(ctx, objKey); err != nil {
+			slog.Error("clearing reconcile failed condition", "error", err)
+		}
+	}
+	c.clearDeadLetter(ctx, key)
+	c.conditionRetries.forget(key)
+
 	switch {
 	case reconcileResult.IsZero():
 		slog.Info("result zero", "key", req.Key)
-		// TODO: make this configurable. Default is to ACK the message
-		// so that it never reconciles. It reconciles again when the object
-		// changes.
+		// Only resync messages for the object itself, and not messages for
+		// owned objects, which would resync the object again.
+		if c.resync != nil && keyFromMsgSubject(kv, msg) == key {
+			c.resyncMsg(ctx, req, key, msg)
+			return
+		}
+		// ACK the message so that it never reconciles.
+		// It reconciles again when the object changes.
 		if err := msg.Ack(); err != nil {
 			slog.Error("result zero: ack", "error", err)
 		}
 	case reconcileResult.RequeueAfter > 0:
+		metricReconcileRequeues.WithLabelValues(objKey.Kind).Inc()
+		c.stats.requeues.Add(1)
 		if err := msg.NakWithDelay(reconcileResult.RequeueAfter); err != nil {
 			slog.Error("result requeue after: nak with delay", "error", err)
 		}
 	case reconcileResult.Requeue:
-		// If requeue is set, reconcile immediately.
-		if err := msg.Nak(); err != nil {
-			slog.Error("result requeue: nak", "error", err)
+		// If requeue is set, reconcile again after the rate limiter's delay.
+		metricReconcileRequeues.WithLabelValues(objKey.Kind).Inc()
+		c.stats.requeues.Add(1)
+		delay, err := c.requeueDelay(ctx, req, key, msg)
+		if err != nil {
+			slog.Error("getting requeue delay", "error", err)
+			delay = time.Second * 10
+		}
+		if err := msg.NakWithDelay(delay); err != nil {
+			slog.Error("result requeue: nak with delay", "error", err)
 		}
 	default:
 		slog.Error("unknown reconcile result", "result", reconcileResult)
@@ -717,24 +1594,115 @@ func isLastMsg(
 	return false, nil
 }
 
-func exponentialBackoff(msg jetstream.Msg) (time.Duration, error) {
+// allowMsg returns true if the predicates of the controller allow the change
+// in the message.
+func (c *Controller) allowMsg(key string, msg jetstream.Msg) bool {
+	if len(c.predicates) == 0 && !c.conditions {
+		return true
+	}
 	meta, err := msg.Metadata()
 	if err != nil {
-		return 0, fmt.Errorf("getting message metadata: %w", err)
+		return true
+	}
+	var obj GenericObject
+	if err := json.Unmarshal(msg.Data(), &obj); err != nil {
+		return true
 	}
+	old, ok := c.seen.swap(key, meta.Sequence.Stream, obj)
+	if !ok {
+		// Always reconcile redelivered messages, which were allowed before.
+		return true
+	}
+	event := PredicateEvent{New: obj}
+	if old != nil {
+		event.Old = &old.digest
+	}
+	return allowEvent(c.predicates, event)
+}
+
+// reconcileTimedOut surfaces a reconcile that exceeded the reconcile timeout,
+// in the controller stats and as a warning event for the object.
+// Objects with conditions also get a ReconcileFailed condition (see
+// [Controller.setTimeoutCondition]).
+func (c *Controller) reconcileTimedOut(
+	ctx context.Context,
+	objKey ObjectKey,
+	reconcileErr error,
+) {
+	c.stats.timeouts.Add(1)
+	if err := c.events.Event(
+		ctx,
+		objKey,
+		EventTypeWarning,
+		ConditionReasonReconcileTimeout,
+		reconcileErr.Error(),
+	); err != nil {
+		slog.Error("recording reconcile timeout event", "error", err)
+	}
+}
 
-	backoff := time.Second * 10
-	if meta.NumDelivered == 0 {
-		return backoff, nil
+// newerMsgFiltered returns true if the message is for an object of the
+// controller's kind, and the predicates do not allow the change from the
+// message to the latest revision of the object.
+func (c *Controller) newerMsgFiltered(
+	ctx context.Context,
+	kv jetstream.KeyValue,
+	key string,
+	msg jetstream.Msg,
+) bool {
+	if len(c.predicates) == 0 || keyFromMsgSubject(kv, msg) != key {
+		return false
 	}
-	exp := math.Pow(2, float64(meta.NumDelivered))
-	const secondsPerDay = 86400
-	if exp > secondsPerDay {
-		backoff = time.Second * secondsPerDay
-	} else {
-		backoff = time.Duration(exp * float64(time.Second))
+	kve, err := kv.Get(ctx, key)
+	if err != nil {
+		return false
+	}
+	var oldObj, newObj GenericObject
+	if err := json.Unmarshal(msg.Data(), &oldObj); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(kve.Value(), &newObj); err != nil {
+		return false
+	}
+	oldDigest := DigestObject(oldObj)
+	return !allowEvent(c.predicates, PredicateEvent{
+		Old: &oldDigest,
+		New: newObj,
+	})
+}
+
+// requeueDelay returns the delay from the controller's rate limiter, before
+// the message is delivered again.
+func (c *Controller) requeueDelay(
+	ctx context.Context,
+	req Request,
+	key string,
+	msg jetstream.Msg,
+) (time.Duration, error) {
+	attempt, err := c.attempt(ctx, key, msg)
+	if err != nil {
+		return 0, err
+	}
+	return c.rateLimiter.When(req, attempt), nil
+}
+
+// attempt returns the number of times the message was reconciled since it was
+// last resynced, starting at one.
+func (c *Controller) attempt(
+	ctx context.Context,
+	key string,
+	msg jetstream.Msg,
+) (int, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return 0, fmt.Errorf("getting message metadata: %w", err)
+	}
+	resynced, err := c.resyncedDeliveries(ctx, key, meta)
+	if err != nil {
+		return 0, err
 	}
-	return backoff, nil
+	carried := c.conditionRetries.carried(key, meta.Sequence.Stream)
+	return int(meta.NumDelivered-resynced) + carried, nil
 }
 
 // keyFromMsgSubject takes the subject for a msg and converts it to the
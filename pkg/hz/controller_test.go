@@ -3,10 +3,13 @@ package hz_test
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/verifa/horizon/pkg/extensions/core"
 	"github.com/verifa/horizon/pkg/hz"
 	"github.com/verifa/horizon/pkg/server"
 	"github.com/verifa/horizon/pkg/store"
@@ -414,3 +417,530 @@ func TestReconcilerConcurrent(t *testing.T) {
 		t.Fatal("concurent reconciler was called concurrently")
 	}
 }
+
+// BlockingReconciler blocks until it is released, and records the maximum
+// number of concurrent reconciles.
+type BlockingReconciler struct {
+	release    chan struct{}
+	active     atomic.Int32
+	maxActive  atomic.Int32
+	reconciled atomic.Int32
+}
+
+func (r *BlockingReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	active := r.active.Add(1)
+	defer r.active.Add(-1)
+	for {
+		maxActive := r.maxActive.Load()
+		if active <= maxActive ||
+			r.maxActive.CompareAndSwap(maxActive, active) {
+			break
+		}
+	}
+	<-r.release
+	r.reconciled.Add(1)
+	return hz.Result{}, nil
+}
+
+func TestReconcilerMaxConcurrent(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	br := BlockingReconciler{
+		release: make(chan struct{}),
+	}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&br),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerMaxConcurrentReconciles(2),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	const numObjects = 10
+	for i := 0; i < numObjects; i++ {
+		_, err := dummyClient.Apply(ctx, DummyObject{
+			ObjectMeta: hz.ObjectMeta{
+				Namespace: "test",
+				Name:      fmt.Sprintf("dummy-%d", i),
+			},
+		})
+		tu.AssertNoError(t, err)
+	}
+
+	// Only two objects are reconciled at a time, and the rest of the backlog
+	// waits.
+	waitFor(t, func() bool {
+		return br.active.Load() == 2
+	})
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, int32(2), br.active.Load())
+	tu.AssertEqual(t, int32(0), br.reconciled.Load())
+
+	// Once released, the backlog is reconciled.
+	close(br.release)
+	waitFor(t, func() bool {
+		return br.reconciled.Load() == numObjects
+	})
+	tu.AssertEqual(t, int32(2), br.maxActive.Load())
+}
+
+// ResyncReconciler succeeds for the first reconciles, and then fails.
+type ResyncReconciler struct {
+	reconciles atomic.Int32
+	succeed    int32
+}
+
+func (r *ResyncReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	if r.reconciles.Add(1) <= r.succeed {
+		return hz.Result{}, nil
+	}
+	return hz.Result{}, fmt.Errorf("drift detected")
+}
+
+func TestReconcilerResync(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	rr := ResyncReconciler{succeed: 3}
+	rl := recordingRateLimiter{}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&rr),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerResyncPeriod(time.Millisecond*200),
+		hz.WithControllerRateLimiter(&rl),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	})
+	tu.AssertNoError(t, err)
+
+	// The object is reconciled again without changing, until the reconciler
+	// fails.
+	waitFor(t, func() bool {
+		return len(rl.Attempts()) > 0
+	})
+	tu.AssertTrue(t, rr.reconciles.Load() > rr.succeed)
+	// The resyncs do not count as retries.
+	tu.AssertEqual(t, 1, rl.Attempts()[0])
+}
+
+// TestReconcilerResyncReplicas tests that replicas sharing a consumer resync
+// each existing object once per period, and not once per replica.
+func TestReconcilerResyncReplicas(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	rr := ResyncReconciler{succeed: 1000}
+	startController := func() *hz.Controller {
+		ctlr, err := hz.StartController(
+			ctx,
+			ti.Conn,
+			hz.WithControllerReconciler(&rr),
+			hz.WithControllerFor(&DummyObject{}),
+			hz.WithControllerResyncPeriod(time.Millisecond*300),
+		)
+		tu.AssertNoError(t, err)
+		t.Cleanup(func() {
+			_ = ctlr.Stop()
+		})
+		return ctlr
+	}
+
+	// Reconcile the object before the replicas start.
+	ctlr := startController()
+	_, err := dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	})
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		return rr.reconciles.Load() == 1
+	})
+	err = ctlr.Stop()
+	tu.AssertNoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		startController()
+	}
+	// About five resyncs.
+	time.Sleep(time.Millisecond * 1600)
+	resyncs := rr.reconciles.Load() - 1
+	tu.AssertTrue(t, resyncs >= 3)
+	tu.AssertTrue(t, resyncs <= 7)
+}
+
+// WatchReconciler counts the reconciles of each object.
+type WatchReconciler struct {
+	mu         sync.Mutex
+	reconciles map[string]int
+}
+
+func (r *WatchReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reconciles == nil {
+		r.reconciles = make(map[string]int)
+	}
+	r.reconciles[request.Key.ObjectName()]++
+	return hz.Result{}, nil
+}
+
+func (r *WatchReconciler) Reconciles(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reconciles[name]
+}
+
+func TestReconcilerWatches(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	childClient := hz.ObjectClient[ChildObject]{Client: client}
+	wr := WatchReconciler{}
+	// Reconcile the dummy objects that a child object refers to with its
+	// labels, e.g. "a: parent".
+	mapFn := func(ctx context.Context, obj hz.GenericObject) []hz.Request {
+		var reqs []hz.Request
+		for name, value := range obj.Labels {
+			if value != "parent" {
+				continue
+			}
+			reqs = append(reqs, hz.Request{
+				Key: hz.ObjectKey{
+					Namespace: obj.Namespace,
+					Name:      name,
+				},
+			})
+		}
+		return reqs
+	}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&wr),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerWatches(&ChildObject{}, mapFn),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+	childCtlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&ChildReconciler{}),
+		hz.WithControllerFor(&ChildObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = childCtlr.Stop()
+	})
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := dummyClient.Apply(ctx, DummyObject{
+			ObjectMeta: hz.ObjectMeta{
+				Namespace: "test",
+				Name:      name,
+			},
+		})
+		tu.AssertNoError(t, err)
+	}
+	waitFor(t, func() bool {
+		return wr.Reconciles("a") == 1 &&
+			wr.Reconciles("b") == 1 &&
+			wr.Reconciles("c") == 1
+	})
+
+	// Changing the child reconciles the objects it refers to.
+	_, err = childClient.Apply(ctx, ChildObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "child",
+			Labels: map[string]string{
+				"a": "parent",
+				"b": "parent",
+			},
+		},
+	})
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		return wr.Reconciles("a") == 2 && wr.Reconciles("b") == 2
+	})
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, 2, wr.Reconciles("a"))
+	tu.AssertEqual(t, 2, wr.Reconciles("b"))
+	tu.AssertEqual(t, 1, wr.Reconciles("c"))
+}
+
+func TestReconcilerWatchesInvalid(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	mapFn := func(ctx context.Context, obj hz.GenericObject) []hz.Request {
+		return nil
+	}
+	_, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&WatchReconciler{}),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerWatches(&ChildObject{}, mapFn),
+		hz.WithControllerWatches(&ChildObject{}, mapFn),
+	)
+	tu.AssertTrue(t, err != nil, "expected error for duplicate watches")
+}
+
+// keyRecordingReconciler records the names of the objects it reconciles.
+type keyRecordingReconciler struct {
+	mu         sync.Mutex
+	names      map[string]struct{}
+	reconciles atomic.Int32
+}
+
+func (r *keyRecordingReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names == nil {
+		r.names = make(map[string]struct{})
+	}
+	r.names[request.Key.ObjectName()] = struct{}{}
+	r.reconciles.Add(1)
+	return hz.Result{}, nil
+}
+
+func (r *keyRecordingReconciler) reconciled() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.names))
+	for name := range r.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestReconcilerScope(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	_, err := client.Apply(ctx, hz.WithApplyObject(core.Namespace{
+		ObjectMeta: hz.ObjectMeta{
+			Name:      "other",
+			Namespace: hz.NamespaceRoot,
+		},
+	}))
+	tu.AssertNoError(t, err)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+
+	// Controllers with different scopes run side by side, each with their
+	// own consumer.
+	prodRecon := keyRecordingReconciler{}
+	prodCtlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&prodRecon),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerNamespaces("test"),
+		hz.WithControllerLabelSelector(hz.LabelSelector{
+			MatchLabels: map[string]string{"env": "prod"},
+		}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = prodCtlr.Stop()
+	})
+	otherRecon := keyRecordingReconciler{}
+	otherCtlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&otherRecon),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerNamespaces("other"),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = otherCtlr.Stop()
+	})
+	_, err = hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerNamespaces("invalid.namespace"),
+	)
+	tu.AssertTrue(t, err != nil, "expected error for invalid namespace")
+
+	apply := func(namespace string, name string, env string) {
+		_, err := dummyClient.Apply(ctx, DummyObject{
+			ObjectMeta: hz.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+				Labels:    map[string]string{"env": env},
+			},
+		})
+		tu.AssertNoError(t, err)
+	}
+	apply("test", "a", "prod")
+	apply("test", "b", "dev")
+	apply("other", "c", "prod")
+	waitFor(t, func() bool {
+		return len(prodRecon.reconciled()) > 0 &&
+			len(otherRecon.reconciled()) > 0
+	})
+	// Give the controllers a chance to reconcile objects out of scope.
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, []string{"a"}, prodRecon.reconciled())
+	tu.AssertEqual(t, []string{"c"}, otherRecon.reconciled())
+
+	// Once the labels match, the object is reconciled.
+	apply("test", "b", "prod")
+	waitFor(t, func() bool {
+		return len(prodRecon.reconciled()) == 2
+	})
+	tu.AssertEqual(t, []string{"a", "b"}, prodRecon.reconciled())
+}
+
+func TestReconcilerSharding(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(
+		t,
+		ctx,
+		// Check the instances of the controller every second.
+		server.WithStoreOptions(store.WithControllerTTL(time.Second*3)),
+	)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	startShard := func(r hz.Reconciler) *hz.Controller {
+		ctlr, err := hz.StartController(
+			ctx,
+			ti.Conn,
+			hz.WithControllerReconciler(r),
+			hz.WithControllerFor(&DummyObject{}),
+			hz.WithControllerSharding(),
+		)
+		tu.AssertNoError(t, err)
+		t.Cleanup(func() {
+			_ = ctlr.Stop()
+		})
+		return ctlr
+	}
+	reconA := keyRecordingReconciler{}
+	startShard(&reconA)
+	reconB := keyRecordingReconciler{}
+	ctlrB := startShard(&reconB)
+	// Wait for the first instance to find the second.
+	time.Sleep(time.Second * 2)
+
+	const numObjects = 20
+	for i := 0; i < numObjects; i++ {
+		_, err := dummyClient.Apply(ctx, DummyObject{
+			ObjectMeta: hz.ObjectMeta{
+				Namespace: "test",
+				Name:      fmt.Sprintf("dummy-%d", i),
+			},
+		})
+		tu.AssertNoError(t, err)
+	}
+	waitFor(t, func() bool {
+		return len(reconA.reconciled())+len(reconB.reconciled()) == numObjects
+	})
+	// Each object is reconciled by one instance.
+	reconciledA := reconA.reconciled()
+	reconciledB := reconB.reconciled()
+	tu.AssertTrue(t, len(reconciledA) > 0, "expected objects for instance a")
+	tu.AssertTrue(t, len(reconciledB) > 0, "expected objects for instance b")
+	for _, name := range reconciledA {
+		tu.AssertTrue(
+			t,
+			!slices.Contains(reconciledB, name),
+			"object reconciled by both instances: "+name,
+		)
+	}
+
+	// When an instance leaves, the other instance takes over its objects,
+	// without reconciling the objects it kept again.
+	err := ctlrB.Stop()
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		return len(reconA.reconciled()) == numObjects
+	})
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, int32(numObjects), reconA.reconciles.Load())
+}
+
+func TestReconcilerShardingLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	_, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&countingReconciler{}),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerSharding(),
+		hz.WithControllerLeaderElection("dummy"),
+	)
+	tu.AssertTrue(t, err != nil, "expected error for sharding with leader election")
+}
@@ -0,0 +1,391 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/tidwall/sjson"
+)
+
+// KindVersions describes the versions of a kind that are served by a
+// controller.
+// Objects of the kind are always stored in the store using the
+// StorageVersion, and converted on the fly to any of the served Versions.
+type KindVersions struct {
+	StorageVersion string   `json:"storageVersion"`
+	Versions       []string `json:"versions"`
+}
+
+// IsServed returns true if the given version is served for the kind.
+func (k KindVersions) IsServed(version string) bool {
+	return slices.Contains(k.Versions, version)
+}
+
+// KindVersionsKey returns the key of the versions of the kind of the given
+// object key, in the versions bucket.
+func KindVersionsKey(key ObjectKeyer) string {
+	return key.ObjectGroup() + "." + key.ObjectKind()
+}
+
+// WithControllerVersion registers an additional version of the controller's
+// kind (given by [WithControllerFor]) that is served by Horizon.
+//
+// Like [WithControllerFor], obj is an object of the version, e.g.
+// MyObjectV1{}.
+// The object given by [WithControllerFor] is the storage version of the kind.
+// Objects applied using version T are converted to the storage version S using
+// toStorage before being stored.
+// Objects read using version T are converted from the storage version using
+// fromStorage.
+//
+// The metadata of an object is always carried over from the source object, so
+// conversion functions need only worry about the rest of the object (e.g.
+// spec and status).
+func WithControllerVersion[T Objecter, S Objecter](
+	obj T,
+	toStorage func(T) (S, error),
+	fromStorage func(S) (T, error),
+) ControllerOption {
+	return func(ro *controllerOption) {
+		ro.versions = append(ro.versions, versionConversion{
+			object:         obj,
+			version:        obj.ObjectVersion(),
+			storageVersion: newObject[S]().ObjectVersion(),
+			group:          obj.ObjectGroup(),
+			kind:           obj.ObjectKind(),
+			toStorage: func(data []byte) ([]byte, error) {
+				return convertObject(data, toStorage)
+			},
+			fromStorage: func(data []byte) ([]byte, error) {
+				return convertObject(data, fromStorage)
+			},
+		})
+	}
+}
+
+// newObject returns an object of type T on which methods can be called, which
+// for a pointer type is a pointer to a new zero value.
+func newObject[T Objecter]() T {
+	var obj T
+	typ := reflect.TypeOf(&obj).Elem()
+	if typ.Kind() == reflect.Ptr {
+		return reflect.New(typ.Elem()).Interface().(T)
+	}
+	return obj
+}
+
+// versionConversion holds the conversion functions between a served version
+// and the storage version of a kind.
+type versionConversion struct {
+	object         Objecter
+	version        string
+	storageVersion string
+	group          string
+	kind           string
+
+	toStorage   func([]byte) ([]byte, error)
+	fromStorage func([]byte) ([]byte, error)
+}
+
+func convertObject[From Objecter, To Objecter](
+	data []byte,
+	fn func(From) (To, error),
+) ([]byte, error) {
+	var from From
+	if err := json.Unmarshal(data, &from); err != nil {
+		return nil, fmt.Errorf("unmarshalling object: %w", err)
+	}
+	to, err := fn(from)
+	if err != nil {
+		return nil, fmt.Errorf("converting object: %w", err)
+	}
+	bTo, err := marshalObjectWithTypeFields(to)
+	if err != nil {
+		return nil, err
+	}
+	// Carry over the metadata from the source object, so that conversion
+	// functions cannot lose things like the revision or managed fields.
+	var meta struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("unmarshalling metadata: %w", err)
+	}
+	if meta.Metadata == nil {
+		return bTo, nil
+	}
+	bTo, err = sjson.SetRawBytes(bTo, "metadata", meta.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("setting metadata: %w", err)
+	}
+	return bTo, nil
+}
+
+// kindVersions returns the versions served by the controller.
+func (opt controllerOption) kindVersions() KindVersions {
+	versions := make([]string, 0, len(opt.versions)+1)
+	for _, obj := range opt.servedObjects() {
+		versions = append(versions, obj.ObjectVersion())
+	}
+	return KindVersions{
+		StorageVersion: opt.forObject.ObjectVersion(),
+		Versions:       versions,
+	}
+}
+
+// servedObjects returns an object for each version served by the controller,
+// starting with the storage version.
+func (opt controllerOption) servedObjects() []Objecter {
+	objects := []Objecter{opt.forObject}
+	for _, conv := range opt.versions {
+		objects = append(objects, conv.object)
+	}
+	return objects
+}
+
+// convert converts the object in data to the given version.
+// If needed, the object is first converted to the storage version, and then to
+// the requested version.
+func (opt controllerOption) convert(
+	data []byte,
+	version string,
+) ([]byte, error) {
+	var typeMeta TypeMeta
+	if err := json.Unmarshal(data, &typeMeta); err != nil {
+		return nil, fmt.Errorf("unmarshalling type meta: %w", err)
+	}
+	storageVersion := opt.forObject.ObjectVersion()
+	from := typeMeta.ObjectVersion()
+	if from == version {
+		return data, nil
+	}
+	conversion := func(v string) (versionConversion, error) {
+		for _, conv := range opt.versions {
+			if conv.version == v {
+				return conv, nil
+			}
+		}
+		return versionConversion{}, fmt.Errorf("version %q not served", v)
+	}
+	if from != storageVersion {
+		conv, err := conversion(from)
+		if err != nil {
+			return nil, err
+		}
+		data, err = conv.toStorage(data)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"converting %q to %q: %w",
+				from,
+				storageVersion,
+				err,
+			)
+		}
+	}
+	if version == storageVersion {
+		return data, nil
+	}
+	conv, err := conversion(version)
+	if err != nil {
+		return nil, err
+	}
+	data, err = conv.fromStorage(data)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"converting %q to %q: %w",
+			storageVersion,
+			version,
+			err,
+		)
+	}
+	return data, nil
+}
+
+// validateVersions checks that the registered versions belong to the same kind
+// as the controller and convert to its storage version.
+func (opt controllerOption) validateVersions() error {
+	obj := opt.forObject
+	for _, conv := range opt.versions {
+		if conv.group != obj.ObjectGroup() || conv.kind != obj.ObjectKind() {
+			return fmt.Errorf(
+				"version %q is for %s/%s, expected %s/%s",
+				conv.version,
+				conv.group,
+				conv.kind,
+				obj.ObjectGroup(),
+				obj.ObjectKind(),
+			)
+		}
+		if conv.storageVersion != obj.ObjectVersion() {
+			return fmt.Errorf(
+				"version %q converts to %q, expected storage version %q",
+				conv.version,
+				conv.storageVersion,
+				obj.ObjectVersion(),
+			)
+		}
+		if conv.version == obj.ObjectVersion() {
+			return fmt.Errorf(
+				"version %q is already the storage version",
+				conv.version,
+			)
+		}
+	}
+	return nil
+}
+
+// startConversion registers the versions of the controller's kind, which the
+// store reads to find the objects of the kind, and subscribes to the subject
+// used by the store to convert objects between them.
+func (c *Controller) startConversion(
+	ctx context.Context,
+	opt controllerOption,
+) error {
+	if err := opt.validateVersions(); err != nil {
+		return fmt.Errorf("validating versions: %w", err)
+	}
+	obj := opt.forObject
+	if err := c.registerVersions(ctx, opt); err != nil {
+		return fmt.Errorf("registering versions: %w", err)
+	}
+	{
+		subject := fmt.Sprintf(
+			SubjectCtlrConvert,
+			obj.ObjectGroup(),
+			obj.ObjectKind(),
+		)
+		sub, err := c.Conn.QueueSubscribe(
+			subject,
+			"convert",
+			func(msg *nats.Msg) {
+				go c.handleConvert(opt, msg)
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("subscribing convert %q: %w", subject, err)
+		}
+		c.subscriptions = append(c.subscriptions, sub)
+	}
+	{
+		subject := fmt.Sprintf(
+			SubjectCtlrConvertList,
+			obj.ObjectGroup(),
+			obj.ObjectKind(),
+		)
+		sub, err := c.Conn.QueueSubscribe(
+			subject,
+			"convert-list",
+			func(msg *nats.Msg) {
+				go c.handleConvertList(opt, msg)
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("subscribing convert list %q: %w", subject, err)
+		}
+		c.subscriptions = append(c.subscriptions, sub)
+	}
+	return nil
+}
+
+// registerVersions puts the versions of the controller's kind into the
+// versions bucket.
+// The versions are persisted, so that the store finds the objects of the kind
+// while the controller is down.
+func (c *Controller) registerVersions(
+	ctx context.Context,
+	opt controllerOption,
+) error {
+	js, err := jetstream.New(c.Conn)
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := keyValue(ctx, js, jetstream.KeyValueConfig{
+		Bucket:      BucketVersions,
+		Description: "Versions of the kinds in " + BucketObjects,
+		History:     1,
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(opt.kindVersions())
+	if err != nil {
+		return fmt.Errorf("marshalling versions: %w", err)
+	}
+	if _, err := kv.Put(ctx, KindVersionsKey(opt.forObject), data); err != nil {
+		return fmt.Errorf("putting versions: %w", err)
+	}
+	return nil
+}
+
+func (c *Controller) handleConvert(
+	opt controllerOption,
+	msg *nats.Msg,
+) {
+	version := msg.Header.Get(HeaderConvertVersion)
+	if version == "" {
+		_ = RespondError(msg, &Error{
+			Status:  http.StatusBadRequest,
+			Message: "missing convert version",
+		})
+		return
+	}
+	data, err := opt.convert(msg.Data, version)
+	if err != nil {
+		_ = RespondError(msg, &Error{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+	_ = RespondOK(msg, data)
+}
+
+// handleConvertList converts a JSON array of objects, so that the store can
+// convert the objects of a list in a single request.
+func (c *Controller) handleConvertList(
+	opt controllerOption,
+	msg *nats.Msg,
+) {
+	version := msg.Header.Get(HeaderConvertVersion)
+	if version == "" {
+		_ = RespondError(msg, &Error{
+			Status:  http.StatusBadRequest,
+			Message: "missing convert version",
+		})
+		return
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(msg.Data, &items); err != nil {
+		_ = RespondError(msg, &Error{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("unmarshalling objects: %s", err.Error()),
+		})
+		return
+	}
+	for i, item := range items {
+		data, err := opt.convert(item, version)
+		if err != nil {
+			_ = RespondError(msg, &Error{
+				Status:  http.StatusBadRequest,
+				Message: err.Error(),
+			})
+			return
+		}
+		items[i] = data
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		_ = RespondError(msg, &Error{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("marshalling objects: %s", err.Error()),
+		})
+		return
+	}
+	_ = RespondOK(msg, data)
+}
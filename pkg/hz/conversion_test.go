@@ -0,0 +1,417 @@
+package hz_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+type WidgetV1 struct {
+	hz.ObjectMeta `json:"metadata,omitempty" cue:""`
+
+	Spec WidgetV1Spec `json:"spec,omitempty" cue:""`
+}
+
+type WidgetV1Spec struct {
+	Size int `json:"size,omitempty"`
+}
+
+func (o WidgetV1) ObjectGroup() string {
+	return "WidgetGroup"
+}
+
+func (o WidgetV1) ObjectVersion() string {
+	return "v1"
+}
+
+func (o WidgetV1) ObjectKind() string {
+	return "Widget"
+}
+
+type WidgetV2 struct {
+	hz.ObjectMeta `json:"metadata,omitempty" cue:""`
+
+	Spec WidgetV2Spec `json:"spec,omitempty" cue:""`
+}
+
+type WidgetV2Spec struct {
+	Replicas int `json:"replicas,omitempty"`
+}
+
+func (o WidgetV2) ObjectGroup() string {
+	return "WidgetGroup"
+}
+
+func (o WidgetV2) ObjectVersion() string {
+	return "v2"
+}
+
+func (o WidgetV2) ObjectKind() string {
+	return "Widget"
+}
+
+func widgetV1ToV2(v1 WidgetV1) (WidgetV2, error) {
+	return WidgetV2{
+		Spec: WidgetV2Spec{Replicas: v1.Spec.Size},
+	}, nil
+}
+
+func widgetV2ToV1(v2 WidgetV2) (WidgetV1, error) {
+	return WidgetV1{
+		Spec: WidgetV1Spec{Size: v2.Spec.Replicas},
+	}, nil
+}
+
+func TestConversion(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	v1Client := hz.ObjectClient[WidgetV1]{Client: client}
+	v2Client := hz.ObjectClient[WidgetV2]{Client: client}
+
+	// Start with a controller that only knows about v1, and create an object
+	// that is stored as v1.
+	v1Ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV1{}),
+	)
+	tu.AssertNoError(t, err)
+	old := WidgetV1{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "old",
+		},
+		Spec: WidgetV1Spec{Size: 1},
+	}
+	_, err = v1Client.Apply(ctx, old)
+	tu.AssertNoError(t, err)
+	err = v1Ctlr.Stop()
+	tu.AssertNoError(t, err)
+
+	// Upgrade the controller to store v2, and serve v1 with conversion.
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV2{}),
+		hz.WithControllerVersion(WidgetV1{}, widgetV1ToV2, widgetV2ToV1),
+	)
+	tu.AssertNoError(t, err)
+	defer ctlr.Stop()
+
+	// Apply a new object using v1, which should be stored as v2.
+	w := WidgetV1{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "new",
+		},
+		Spec: WidgetV1Spec{Size: 3},
+	}
+	_, err = v1Client.Apply(ctx, w)
+	tu.AssertNoError(t, err)
+
+	getV2, err := v2Client.Get(ctx, hz.WithGetKey(w))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, getV2.Spec.Replicas, 3)
+	getV1, err := v1Client.Get(ctx, hz.WithGetKey(w))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, getV1.Spec.Size, 3)
+
+	// The object stored as v1 should be readable using both versions.
+	oldV2, err := v2Client.Get(ctx, hz.WithGetKey(old))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, oldV2.Spec.Replicas, 1)
+
+	// Updating the object stored as v1 using v2 should not create a second
+	// object.
+	oldV2.Spec.Replicas = 2
+	oldV2.ObjectMeta = hz.ObjectMeta{
+		Namespace: old.Namespace,
+		Name:      old.Name,
+	}
+	_, err = v2Client.Apply(ctx, oldV2)
+	tu.AssertNoError(t, err)
+	oldV1, err := v1Client.Get(ctx, hz.WithGetKey(old))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, oldV1.Spec.Size, 2)
+
+	// Lists convert the objects in a single request, instead of one request
+	// per object.
+	var convertReqs, convertListReqs atomic.Int32
+	convertSub, err := ti.Conn.Subscribe(
+		fmt.Sprintf(hz.SubjectCtlrConvert, "WidgetGroup", "Widget"),
+		func(*nats.Msg) { convertReqs.Add(1) },
+	)
+	tu.AssertNoError(t, err)
+	defer convertSub.Unsubscribe()
+	convertListSub, err := ti.Conn.Subscribe(
+		fmt.Sprintf(hz.SubjectCtlrConvertList, "WidgetGroup", "Widget"),
+		func(*nats.Msg) { convertListReqs.Add(1) },
+	)
+	tu.AssertNoError(t, err)
+	defer convertListSub.Unsubscribe()
+
+	listV1, err := v1Client.List(ctx)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(listV1), 2)
+	for _, item := range listV1 {
+		tu.AssertTrue(t, item.Spec.Size > 0)
+	}
+	listV2, err := v2Client.List(ctx)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(listV2), 2)
+	for _, item := range listV2 {
+		tu.AssertTrue(t, item.Spec.Replicas > 0)
+	}
+	tu.AssertNoError(t, ti.Conn.Flush())
+	tu.AssertEqual(t, convertReqs.Load(), int32(0))
+	tu.AssertEqual(t, convertListReqs.Load(), int32(2))
+
+	// Versions that are not served should not be found.
+	_, err = client.Get(ctx, hz.WithGetKey(hz.ObjectKey{
+		Group:     "WidgetGroup",
+		Version:   "v3",
+		Kind:      "Widget",
+		Namespace: "test",
+		Name:      "new",
+	}))
+	tu.AssertTrue(t, err != nil)
+}
+
+func TestConversionPointers(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	v1Client := hz.ObjectClient[WidgetV1]{Client: client}
+	v2Client := hz.ObjectClient[WidgetV2]{Client: client}
+
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&WidgetV2{}),
+		hz.WithControllerVersion(
+			&WidgetV1{},
+			func(v1 *WidgetV1) (*WidgetV2, error) {
+				v2, err := widgetV1ToV2(*v1)
+				return &v2, err
+			},
+			func(v2 *WidgetV2) (*WidgetV1, error) {
+				v1, err := widgetV2ToV1(*v2)
+				return &v1, err
+			},
+		),
+	)
+	tu.AssertNoError(t, err)
+	defer ctlr.Stop()
+
+	w := WidgetV1{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "pointer",
+		},
+		Spec: WidgetV1Spec{Size: 3},
+	}
+	_, err = v1Client.Apply(ctx, w)
+	tu.AssertNoError(t, err)
+	getV2, err := v2Client.Get(ctx, hz.WithGetKey(w))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, getV2.Spec.Replicas, 3)
+}
+
+// TestConversionMissingBuckets tests that a controller starts against a store
+// that has not created the buckets for versions, validators and merge
+// schemas, e.g. while the store is being upgraded.
+func TestConversionMissingBuckets(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	js, err := jetstream.New(ti.Conn)
+	tu.AssertNoError(t, err)
+	for _, bucket := range []string{
+		hz.BucketVersions,
+		hz.BucketValidators,
+		hz.BucketMergeSchemas,
+	} {
+		err := js.DeleteKeyValue(ctx, bucket)
+		tu.AssertNoError(t, err)
+	}
+
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV2{}),
+		hz.WithControllerVersion(WidgetV1{}, widgetV1ToV2, widgetV2ToV1),
+	)
+	tu.AssertNoError(t, err)
+	defer ctlr.Stop()
+
+	for _, bucket := range []string{
+		hz.BucketVersions,
+		hz.BucketValidators,
+		hz.BucketMergeSchemas,
+	} {
+		_, err := js.KeyValue(ctx, bucket)
+		tu.AssertNoError(t, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	v1Client := hz.ObjectClient[WidgetV1]{Client: client}
+
+	v1Ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV1{}),
+	)
+	tu.AssertNoError(t, err)
+	w := WidgetV1{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "old",
+		},
+		Spec: WidgetV1Spec{Size: 1},
+	}
+	_, err = v1Client.Apply(ctx, w)
+	tu.AssertNoError(t, err)
+	err = v1Ctlr.Stop()
+	tu.AssertNoError(t, err)
+
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV2{}),
+		hz.WithControllerVersion(WidgetV1{}, widgetV1ToV2, widgetV2ToV1),
+	)
+	tu.AssertNoError(t, err)
+	defer ctlr.Stop()
+
+	v1Key := hz.ObjectKeyFromObject(w)
+	v2Key := v1Key
+	v2Key.Version = "v2"
+
+	result, err := client.Migrate(ctx, hz.WithMigrateKey(v1Key))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, result, hz.MigrateOpResultMigrated)
+
+	// The object should now only be stored as v2.
+	var list hz.GenericObjectList
+	err = client.List(
+		ctx,
+		hz.WithListKey(hz.ObjectKey{
+			Group: "WidgetGroup",
+			Kind:  "Widget",
+		}),
+		hz.WithListResponseGenericObjects(&list),
+	)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(list.Items), 1)
+	tu.AssertEqual(t, list.Items[0].ObjectVersion(), "v2")
+
+	// Migrating again is a no-op, which makes migrations resumable.
+	result, err = client.Migrate(ctx, hz.WithMigrateKey(v2Key))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, result, hz.MigrateOpResultNoop)
+	_, err = client.Migrate(ctx, hz.WithMigrateKey(v1Key))
+	tu.AssertErrorIs(t, err, hz.ErrNotFound)
+
+	getV1, err := v1Client.Get(ctx, hz.WithGetKey(w))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, getV1.Spec.Size, 1)
+}
+
+func TestConversionListDuplicates(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	v1Client := hz.ObjectClient[WidgetV1]{Client: client}
+	v2Client := hz.ObjectClient[WidgetV2]{Client: client}
+
+	v1Ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV1{}),
+	)
+	tu.AssertNoError(t, err)
+	w := WidgetV1{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "old",
+		},
+		Spec: WidgetV1Spec{Size: 1},
+	}
+	_, err = v1Client.Apply(ctx, w)
+	tu.AssertNoError(t, err)
+	err = v1Ctlr.Stop()
+	tu.AssertNoError(t, err)
+
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(WidgetV2{}),
+		hz.WithControllerVersion(WidgetV1{}, widgetV1ToV2, widgetV2ToV1),
+	)
+	tu.AssertNoError(t, err)
+	defer ctlr.Stop()
+
+	// Store the object under the storage version as well, as happens while
+	// the object is being migrated.
+	js, err := jetstream.New(ti.Conn)
+	tu.AssertNoError(t, err)
+	kv, err := js.KeyValue(ctx, hz.BucketObjects)
+	tu.AssertNoError(t, err)
+	v2Key := hz.ObjectKeyFromObject(w)
+	v2Key.Version = "v2"
+	data, err := json.Marshal(map[string]any{
+		"apiVersion": "WidgetGroup/v2",
+		"kind":       "Widget",
+		"metadata": map[string]any{
+			"namespace": "test",
+			"name":      "old",
+		},
+		"spec": map[string]any{"replicas": 2},
+	})
+	tu.AssertNoError(t, err)
+	_, err = kv.Put(ctx, hz.KeyFromObject(v2Key), data)
+	tu.AssertNoError(t, err)
+
+	// The object is listed once, using the storage version.
+	listV1, err := v1Client.List(ctx)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(listV1), 1)
+	tu.AssertEqual(t, listV1[0].Spec.Size, 2)
+	listV2, err := v2Client.List(ctx)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(listV2), 1)
+	tu.AssertEqual(t, listV2[0].Spec.Replicas, 2)
+}
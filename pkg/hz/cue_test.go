@@ -92,9 +92,12 @@ func TestCueDefinition(t *testing.T) {
 			}]
 			deletionTimestamp?: string
 			managedFields?: [...{
-				manager:    =~"^[a-zA-Z0-9-_]+$"
-				fieldsType: =~"^FieldsV1$"
-				fieldsV1: _
+				manager:     =~"^[a-zA-Z0-9-_]+$"
+				fieldsType:  =~"^FieldsV1$"
+				fieldsV1:    _
+				operation?:  string
+				apiVersion?: string
+				time?:       string
 			}]
 			finalizers?: [...string]
 		}
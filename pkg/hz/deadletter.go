@@ -0,0 +1,363 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// DeadLetter is an object that a controller stopped retrying, because its
+// reconciler failed more times than the controller's max retries.
+// The controller writes it to the dead-letter bucket, keyed by the object's
+// key.
+type DeadLetter struct {
+	// Key is the key of the object, i.e. group.version.kind.namespace.name.
+	Key       string `json:"key"`
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Revision is the revision of the object when it was dead-lettered.
+	// The controller does not reconcile this revision again.
+	Revision uint64 `json:"revision"`
+	// Attempts is the number of times the object was reconciled.
+	Attempts int `json:"attempts"`
+	// Error is the error of the last reconcile.
+	Error string `json:"error"`
+	// Time is when the object was dead-lettered.
+	Time time.Time `json:"time"`
+}
+
+// ListDeadLetters returns the entries in the dead-letter bucket, sorted by
+// key.
+func ListDeadLetters(
+	ctx context.Context,
+	conn *nats.Conn,
+) ([]DeadLetter, error) {
+	kv, err := deadLettersBucket(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := kv.Keys(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return []DeadLetter{}, nil
+		}
+		return nil, fmt.Errorf("listing dead letters: %w", err)
+	}
+	deadLetters := make([]DeadLetter, 0, len(keys))
+	for _, key := range keys {
+		deadLetter, _, err := getDeadLetter(ctx, kv, key)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				// The object was reconciled since listing the keys.
+				continue
+			}
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].Key < deadLetters[j].Key
+	})
+	return deadLetters, nil
+}
+
+// RedriveDeadLetter makes the controller reconcile a dead-lettered object
+// again, e.g. once the cause of the failure is fixed.
+//
+// The controller instance that runs the reconciler for the object removes the
+// entry from the dead-letter bucket and reconciles the object again, without
+// writing the object.
+// If the object fails again, it is dead-lettered again once it exceeds the
+// max retries.
+// If the object no longer exists, the entry is removed and [ErrNotFound] is
+// returned.
+func RedriveDeadLetter(
+	ctx context.Context,
+	conn *nats.Conn,
+	key string,
+) error {
+	kv, err := deadLettersBucket(ctx, conn)
+	if err != nil {
+		return err
+	}
+	deadLetter, _, err := getDeadLetter(ctx, kv, key)
+	if err != nil {
+		return err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	objects, err := js.KeyValue(ctx, BucketObjects)
+	if err != nil {
+		return fmt.Errorf(
+			"get objects bucket %q: %w",
+			BucketObjects,
+			err,
+		)
+	}
+	if _, err := objects.Get(ctx, key); err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			if err := kv.Delete(ctx, key); err != nil {
+				return fmt.Errorf("deleting dead letter %q: %w", key, err)
+			}
+			return fmt.Errorf("object %q: %w", key, ErrNotFound)
+		}
+		return fmt.Errorf("getting object %q: %w", key, err)
+	}
+	msg := nats.NewMsg(
+		fmt.Sprintf(SubjectCtlrRedrive, deadLetter.Group, deadLetter.Kind),
+	)
+	msg.Data = []byte(key)
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	reply, err := conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return &Error{
+				Status: http.StatusServiceUnavailable,
+				Message: fmt.Sprintf(
+					"no controller running for %s/%s",
+					deadLetter.Group,
+					deadLetter.Kind,
+				),
+			}
+		}
+		return ErrorFromNATSErr(err)
+	}
+	return ErrorFromNATS(reply)
+}
+
+// startRedrive subscribes to the subject used by [RedriveDeadLetter].
+//
+// Every instance of the controller receives the request, and the instance
+// that runs the reconciler for the object (e.g. the leader, or the owner of
+// the object's shard) handles it.
+func (c *Controller) startRedrive(opt controllerOption) error {
+	subject := fmt.Sprintf(
+		SubjectCtlrRedrive,
+		opt.forObject.ObjectGroup(),
+		opt.forObject.ObjectKind(),
+	)
+	sub, err := c.Conn.Subscribe(subject, func(msg *nats.Msg) {
+		go c.handleRedrive(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("subscribing redrive %q: %w", subject, err)
+	}
+	c.subscriptions = append(c.subscriptions, sub)
+	return nil
+}
+
+func (c *Controller) handleRedrive(msg *nats.Msg) {
+	key := string(msg.Data)
+	requeue := c.getRequeue()
+	if requeue == nil {
+		// The reconciler does not run in this instance.
+		return
+	}
+	if c.shards != nil && !c.shards.owns(key) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	kv, err := deadLettersBucket(ctx, c.Conn)
+	if err != nil {
+		_ = RespondError(msg, err)
+		return
+	}
+	_, revision, err := getDeadLetter(ctx, kv, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			// Another instance claimed the redrive.
+			return
+		}
+		_ = RespondError(msg, err)
+		return
+	}
+	// Claim the redrive by removing the dead letter, so that the object is
+	// reconciled once if several instances handle the request (e.g. while
+	// the shards are rebalanced).
+	if err := kv.Delete(
+		ctx,
+		key,
+		jetstream.LastRevision(revision),
+	); err != nil {
+		var apiErr *jetstream.APIError
+		if errors.As(err, &apiErr) &&
+			apiErr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence {
+			// Another instance claimed the redrive.
+			return
+		}
+		_ = RespondError(msg, fmt.Errorf("deleting dead letter: %w", err))
+		return
+	}
+	requeue(key, 0)
+	_ = RespondOK(msg, nil)
+}
+
+func deadLettersBucket(
+	ctx context.Context,
+	conn *nats.Conn,
+) (jetstream.KeyValue, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := js.KeyValue(ctx, BucketDeadLetters)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"get dead letters bucket %q: %w",
+			BucketDeadLetters,
+			err,
+		)
+	}
+	return kv, nil
+}
+
+// getDeadLetter returns the dead letter and its revision in the bucket.
+// It returns [ErrNotFound] if the key has no dead letter.
+func getDeadLetter(
+	ctx context.Context,
+	kv jetstream.KeyValue,
+	key string,
+) (DeadLetter, uint64, error) {
+	kve, err := kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return DeadLetter{}, 0, fmt.Errorf(
+				"dead letter %q: %w",
+				key,
+				ErrNotFound,
+			)
+		}
+		return DeadLetter{}, 0, fmt.Errorf(
+			"getting dead letter %q: %w",
+			key,
+			err,
+		)
+	}
+	var deadLetter DeadLetter
+	if err := json.Unmarshal(kve.Value(), &deadLetter); err != nil {
+		return DeadLetter{}, 0, fmt.Errorf(
+			"unmarshalling dead letter %q: %w",
+			key,
+			err,
+		)
+	}
+	return deadLetter, kve.Revision(), nil
+}
+
+// deadLetter stops retrying the object of the message, after the reconciler
+// failed more times than the controller's max retries.
+//
+// The message is terminated, the object gets a ReconcileFailed condition (if
+// it has conditions) and the key is written to the dead-letter bucket.
+func (c *Controller) deadLetter(
+	ctx context.Context,
+	kv jetstream.KeyValue,
+	key string,
+	objKey ObjectKey,
+	attempts int,
+	reconcileErr error,
+	msg jetstream.Msg,
+) {
+	slog.Error(
+		"reconcile: max retries exceeded",
+		"key", key,
+		"attempts", attempts,
+		"error", reconcileErr,
+	)
+	if err := msg.Term(); err != nil {
+		slog.Error("max retries exceeded: term", "error", err)
+	}
+	if c.conditions {
+		if err := c.setCondition(ctx, objKey, Condition{
+			Type:    ConditionTypeReconcileFailed,
+			Status:  ConditionTrue,
+			Reason:  ConditionReasonMaxRetriesExceeded,
+			Message: reconcileErr.Error(),
+		}); err != nil {
+			slog.Error("setting max retries exceeded condition", "error", err)
+		}
+	}
+	// Record the latest revision, which includes the condition, so that the
+	// controller does not retry the object because of the condition.
+	var revision uint64
+	if kve, err := kv.Get(ctx, key); err == nil {
+		revision = kve.Revision()
+	}
+	deadLetter := DeadLetter{
+		Key:       key,
+		Group:     objKey.Group,
+		Version:   objKey.Version,
+		Kind:      objKey.Kind,
+		Namespace: objKey.Namespace,
+		Name:      objKey.Name,
+		Revision:  revision,
+		Attempts:  attempts,
+		Error:     reconcileErr.Error(),
+		Time:      time.Now().UTC(),
+	}
+	data, err := json.Marshal(deadLetter)
+	if err != nil {
+		slog.Error("marshalling dead letter", "error", err)
+		return
+	}
+	if _, err := c.deadLetters.Put(ctx, key, data); err != nil {
+		slog.Error("writing dead letter", "key", key, "error", err)
+	}
+}
+
+// isDeadLettered returns true if the message is the revision of the object
+// that was dead-lettered.
+// Later revisions, e.g. from fixing the object or re-driving it, are
+// reconciled.
+func (c *Controller) isDeadLettered(
+	ctx context.Context,
+	key string,
+	msg jetstream.Msg,
+) bool {
+	if c.deadLetters == nil {
+		return false
+	}
+	deadLetter, _, err := getDeadLetter(ctx, c.deadLetters, key)
+	if err != nil {
+		return false
+	}
+	meta, err := msg.Metadata()
+	if err != nil {
+		return false
+	}
+	return deadLetter.Revision == meta.Sequence.Stream
+}
+
+// clearDeadLetter removes the dead letter of the object, if it has one, after
+// the object reconciled successfully.
+func (c *Controller) clearDeadLetter(ctx context.Context, key string) {
+	if c.deadLetters == nil {
+		return
+	}
+	_, revision, err := getDeadLetter(ctx, c.deadLetters, key)
+	if err != nil {
+		return
+	}
+	if err := c.deadLetters.Delete(
+		ctx,
+		key,
+		jetstream.LastRevision(revision),
+	); err != nil {
+		slog.Error("deleting dead letter", "key", key, "error", err)
+	}
+}
@@ -0,0 +1,184 @@
+package hz_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+// FailingReconciler counts its reconciles, and fails while fail is true.
+type FailingReconciler struct {
+	fail       atomic.Bool
+	reconciles atomic.Int64
+}
+
+func (r *FailingReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	r.reconciles.Add(1)
+	if r.fail.Load() {
+		return hz.Result{}, errors.New("always fails")
+	}
+	return hz.Result{}, nil
+}
+
+func TestDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	condClient := hz.ObjectClient[ConditionObject]{Client: client}
+	fr := FailingReconciler{}
+	fr.fail.Store(true)
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&fr),
+		hz.WithControllerFor(ConditionObject{}),
+		hz.WithControllerMaxRetries(2),
+		hz.WithControllerRateLimiter(&hz.FixedIntervalRateLimiter{
+			Interval: time.Millisecond * 100,
+		}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	obj := ConditionObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "failing",
+		},
+	}
+	_, err = condClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+
+	reconcileFailed := func() (hz.Condition, bool) {
+		obj, err := condClient.Get(ctx, hz.WithGetKey(obj))
+		tu.AssertNoError(t, err)
+		if obj.Status == nil {
+			return hz.Condition{}, false
+		}
+		return hz.FindCondition(
+			obj.Status.Conditions,
+			hz.ConditionTypeReconcileFailed,
+		)
+	}
+	deadLetters := func() []hz.DeadLetter {
+		deadLetters, err := hz.ListDeadLetters(ctx, ti.Conn)
+		tu.AssertNoError(t, err)
+		return deadLetters
+	}
+	// After the first attempt and two retries, the object is dead-lettered.
+	waitFor(t, func() bool {
+		return len(deadLetters()) == 1
+	})
+	cond, ok := reconcileFailed()
+	tu.AssertTrue(t, ok, "expected reconcile failed condition")
+	tu.AssertEqual(t, hz.ConditionTrue, cond.Status)
+	tu.AssertEqual(t, hz.ConditionReasonMaxRetriesExceeded, cond.Reason)
+	tu.AssertEqual(t, "always fails", cond.Message)
+
+	deadLetter := deadLetters()[0]
+	tu.AssertEqual(t, hz.KeyFromObject(obj), deadLetter.Key)
+	tu.AssertEqual(t, "failing", deadLetter.Name)
+	tu.AssertEqual(t, 3, deadLetter.Attempts)
+	tu.AssertEqual(t, "always fails", deadLetter.Error)
+
+	// The controller stops retrying, including for the revision with the
+	// condition.
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, int64(3), fr.reconciles.Load())
+
+	// Once re-driven, the dead letter is removed and the object reconciles
+	// again.
+	fr.fail.Store(false)
+	err = hz.RedriveDeadLetter(ctx, ti.Conn, deadLetter.Key)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, 0, len(deadLetters()))
+	waitFor(t, func() bool {
+		cond, _ := reconcileFailed()
+		return cond.Status == hz.ConditionFalse
+	})
+	cond, _ = reconcileFailed()
+	tu.AssertEqual(t, hz.ConditionReasonReconciled, cond.Reason)
+	tu.AssertEqual(t, int64(4), fr.reconciles.Load())
+
+	err = hz.RedriveDeadLetter(ctx, ti.Conn, deadLetter.Key)
+	tu.AssertErrorIs(t, err, hz.ErrNotFound)
+}
+
+func TestDeadLettersAfterResyncs(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	condClient := hz.ObjectClient[ConditionObject]{Client: client}
+	fr := FailingReconciler{}
+	startController := func() *hz.Controller {
+		ctlr, err := hz.StartController(
+			ctx,
+			ti.Conn,
+			hz.WithControllerReconciler(&fr),
+			hz.WithControllerFor(ConditionObject{}),
+			hz.WithControllerMaxRetries(2),
+			hz.WithControllerResyncPeriod(time.Millisecond*200),
+			hz.WithControllerRateLimiter(&hz.FixedIntervalRateLimiter{
+				Interval: time.Millisecond * 100,
+			}),
+		)
+		tu.AssertNoError(t, err)
+		t.Cleanup(func() {
+			_ = ctlr.Stop()
+		})
+		return ctlr
+	}
+	ctlr := startController()
+
+	obj := ConditionObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "resynced",
+		},
+	}
+	_, err := condClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+	// Resync the object a few times, more than the max retries.
+	waitFor(t, func() bool {
+		return fr.reconciles.Load() >= 5
+	})
+
+	// After a restart, the resyncs before the restart do not count as
+	// retries, so the object is retried before it is dead-lettered.
+	err = ctlr.Stop()
+	tu.AssertNoError(t, err)
+	fr.fail.Store(true)
+	before := fr.reconciles.Load()
+	startController()
+
+	waitFor(t, func() bool {
+		deadLetters, err := hz.ListDeadLetters(ctx, ti.Conn)
+		tu.AssertNoError(t, err)
+		return len(deadLetters) == 1
+	})
+	deadLetters, err := hz.ListDeadLetters(ctx, ti.Conn)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, 3, deadLetters[0].Attempts)
+	tu.AssertEqual(t, int64(3), fr.reconciles.Load()-before)
+}
@@ -2,6 +2,7 @@ package hz
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -17,8 +18,30 @@ type Error struct {
 	// Status is the HTTP status code applicable to this problem.
 	Status  int    `json:"status,omitempty"`
 	Message string `json:"message,omitempty"`
+	// Details contains structured information about the problem, if any.
+	// If set, the error is sent as a JSON body instead of just the message.
+	Details *ErrorDetails `json:"details,omitempty"`
 }
 
+// ErrorDetails contains structured information about an [Error].
+type ErrorDetails struct {
+	// Conflicts are the fields that conflict in a server-side apply.
+	Conflicts []ApplyConflict `json:"conflicts,omitempty"`
+}
+
+// ApplyConflict is a field that a server-side apply tried to set, but which
+// is owned by a different manager.
+type ApplyConflict struct {
+	// Field is the path to the field, e.g. "spec.env[name=A].value".
+	Field string `json:"field"`
+	// Manager is the manager that currently owns the field.
+	Manager string `json:"manager"`
+}
+
+// contentTypeJSON is the content type of error responses that are
+// JSON-encoded [Error] values, because they have details.
+const contentTypeJSON = "application/json"
+
 func (e *Error) Error() string {
 	return fmt.Sprintf("%s (status %d)", e.Message, e.Status)
 }
@@ -63,6 +86,9 @@ func ErrorFromNATS(msg *nats.Msg) error {
 	if status >= http.StatusOK && status < http.StatusMultipleChoices {
 		return nil
 	}
+	if msg.Header.Get("Content-Type") == contentTypeJSON {
+		return errorFromJSON(status, msg.Data)
+	}
 	return &Error{
 		Status:  status,
 		Message: string(msg.Data),
@@ -81,12 +107,54 @@ func ErrorFromHTTP(resp *http.Response) error {
 			Message: fmt.Sprintf("reading body: %s", err.Error()),
 		}
 	}
+	if resp.Header.Get("Content-Type") == contentTypeJSON {
+		return errorFromJSON(resp.StatusCode, body)
+	}
 	return &Error{
 		Status:  resp.StatusCode,
 		Message: string(body),
 	}
 }
 
+// errorFromJSON decodes a JSON-encoded [Error].
+// If data is not valid JSON, it is used as the message.
+func errorFromJSON(status int, data []byte) error {
+	var hErr Error
+	if err := json.Unmarshal(data, &hErr); err != nil {
+		return &Error{
+			Status:  status,
+			Message: string(data),
+		}
+	}
+	hErr.Status = status
+	return &hErr
+}
+
+// WriteHTTPError writes err to the HTTP response.
+// If err is an [Error], its status is used, and if it has details it is
+// written as JSON.
+// Otherwise, a http.StatusInternalServerError is written.
+func WriteHTTPError(w http.ResponseWriter, err error) {
+	var hErr *Error
+	if !errors.As(err, &hErr) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if hErr.Details == nil {
+		http.Error(w, hErr.Message, hErr.Status)
+		return
+	}
+	body, mErr := json.Marshal(hErr)
+	if mErr != nil {
+		http.Error(w, hErr.Message, hErr.Status)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(hErr.Status)
+	_, _ = w.Write(body)
+}
+
 // ErrorWrap takes an error and checks if it is an [Error].
 // If it is, it will make a copy of the [Error], add the given message and
 // return it. The status will remain the same.
@@ -106,6 +174,7 @@ func ErrorWrap(
 		return &Error{
 			Status:  hErr.Status,
 			Message: fmt.Sprintf("%s: %s", message, hErr.Message),
+			Details: hErr.Details,
 		}
 	}
 	return &Error{
@@ -128,14 +197,19 @@ func RespondError(
 	text := err.Error()
 	status := http.StatusInternalServerError
 
+	response := nats.NewMsg(msg.Reply)
 	var respErr *Error
 	if errors.As(err, &respErr) {
 		status = respErr.Status
 		text = respErr.Message
+		if respErr.Details != nil {
+			if body, err := json.Marshal(respErr); err == nil {
+				text = string(body)
+				response.Header.Set("Content-Type", contentTypeJSON)
+			}
+		}
 	}
-	response := nats.NewMsg(msg.Reply)
 	response.Data = []byte(text)
-	response.Header = make(nats.Header)
 	response.Header.Add(HeaderStatus, fmt.Sprintf("%d", status))
 	return msg.RespondMsg(response)
 }
@@ -0,0 +1,222 @@
+package hz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// EventTypeNormal is the type of events for things that are expected,
+	// e.g. an external resource was created.
+	EventTypeNormal = "Normal"
+	// EventTypeWarning is the type of events for things that went wrong,
+	// e.g. an external resource could not be created.
+	EventTypeWarning = "Warning"
+)
+
+// DefaultEventTTL is how long an event is kept after it last occurred, if the
+// [EventRecorder] does not set a TTL.
+const DefaultEventTTL = time.Hour
+
+// eventManagerSuffix is appended to the kind of a controller to get the field
+// manager of the events that the controller records.
+const eventManagerSuffix = "-events"
+
+// EventRecorder records events about objects, as core/v1 Event objects in the
+// namespace of the object.
+//
+// Events give a history of what happened to an object, e.g. why a reconcile
+// failed, which is lost if a reconciler only writes the object's status.
+// Recording the same event (type, reason and message) for an object again
+// increments the count of the existing event, instead of creating a new one.
+//
+// Controllers add an EventRecorder to the context of each reconcile, which
+// reconcilers get with [EventRecorderFromContext].
+// A nil EventRecorder does not record events.
+type EventRecorder struct {
+	// Client records the events.
+	// It must be able to apply core/v1 Event objects.
+	Client Client
+	// Source is the component that records the events, e.g. "ctlr-MyObject".
+	Source string
+	// TTL is how long an event is kept after it last occurred.
+	// Defaults to [DefaultEventTTL].
+	TTL time.Duration
+}
+
+// Eventf records an event with a formatted message.
+func (r *EventRecorder) Eventf(
+	ctx context.Context,
+	obj ObjectKeyer,
+	eventType string,
+	reason string,
+	format string,
+	args ...interface{},
+) error {
+	return r.Event(ctx, obj, eventType, reason, fmt.Sprintf(format, args...))
+}
+
+// Event records an event for the object.
+// The reason should be a short CamelCase string, e.g. "CreatedBucket", and
+// the message a human readable description.
+func (r *EventRecorder) Event(
+	ctx context.Context,
+	obj ObjectKeyer,
+	eventType string,
+	reason string,
+	message string,
+) error {
+	if r == nil {
+		return nil
+	}
+	if eventType != EventTypeNormal && eventType != EventTypeWarning {
+		return fmt.Errorf("invalid event type: %q", eventType)
+	}
+	if reason == "" {
+		return fmt.Errorf("event reason is required")
+	}
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultEventTTL
+	}
+	namespace := obj.ObjectNamespace()
+	if namespace == "" || namespace == "*" {
+		namespace = NamespaceRoot
+	}
+	key := ObjectKey{
+		Group:     eventGroup,
+		Version:   eventVersion,
+		Kind:      eventKind,
+		Namespace: namespace,
+		Name:      EventName(obj, eventType, reason, message),
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	count := 1
+	firstTimestamp := now
+	existing, err := r.getEvent(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if err == nil && existing.Count > 0 {
+		count = existing.Count + 1
+		if existing.FirstTimestamp != nil {
+			firstTimestamp = existing.FirstTimestamp.Time
+		}
+	}
+	event := map[string]interface{}{
+		"apiVersion": eventGroup + "/" + eventVersion,
+		"kind":       eventKind,
+		"metadata": map[string]interface{}{
+			"namespace": key.Namespace,
+			"name":      key.Name,
+		},
+		"type":    eventType,
+		"reason":  reason,
+		"message": message,
+		"involvedObject": map[string]interface{}{
+			"group":     obj.ObjectGroup(),
+			"version":   obj.ObjectVersion(),
+			"kind":      obj.ObjectKind(),
+			"namespace": obj.ObjectNamespace(),
+			"name":      obj.ObjectName(),
+		},
+		"source":              r.Source,
+		"count":               count,
+		"firstTimestamp":      Time{Time: firstTimestamp},
+		"lastTimestamp":       Time{Time: now},
+		"expirationTimestamp": Time{Time: now.Add(ttl)},
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshalling event: %w", err)
+	}
+	// The recorder owns the whole event, so force the apply in case another
+	// recorder (e.g. another instance of a controller) recorded it.
+	if _, err := r.Client.Apply(
+		ctx,
+		WithApplyData(data),
+		WithApplyForce(true),
+	); err != nil {
+		return fmt.Errorf("applying event: %w", err)
+	}
+	return nil
+}
+
+const (
+	eventGroup   = "core"
+	eventVersion = "v1"
+	eventKind    = "Event"
+)
+
+// getEvent returns the count and first timestamp of an existing event.
+func (r *EventRecorder) getEvent(
+	ctx context.Context,
+	key ObjectKey,
+) (recordedEvent, error) {
+	data, err := r.Client.Get(ctx, WithGetKey(key))
+	if err != nil {
+		return recordedEvent{}, err
+	}
+	var event recordedEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return recordedEvent{}, fmt.Errorf("unmarshalling event: %w", err)
+	}
+	return event, nil
+}
+
+type recordedEvent struct {
+	Count          int   `json:"count"`
+	FirstTimestamp *Time `json:"firstTimestamp"`
+}
+
+// EventName returns the name of the event object for an event about the
+// object.
+// Events with the same type, reason and message for an object have the same
+// name, so that they are counted as one event.
+func EventName(
+	obj ObjectKeyer,
+	eventType string,
+	reason string,
+	message string,
+) string {
+	h := sha256.New()
+	for _, s := range []string{
+		obj.ObjectGroup(),
+		obj.ObjectKind(),
+		obj.ObjectNamespace(),
+		obj.ObjectName(),
+		eventType,
+		reason,
+		message,
+	} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return obj.ObjectName() + "-" + hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+type eventRecorderContextKey struct{}
+
+// ContextWithEventRecorder returns a copy of the context with the event
+// recorder.
+func ContextWithEventRecorder(
+	ctx context.Context,
+	recorder *EventRecorder,
+) context.Context {
+	return context.WithValue(ctx, eventRecorderContextKey{}, recorder)
+}
+
+// EventRecorderFromContext returns the event recorder of the context, such as
+// the context of a reconcile.
+// It returns nil if the context has no event recorder, which does not record
+// events.
+func EventRecorderFromContext(ctx context.Context) *EventRecorder {
+	recorder, _ := ctx.Value(eventRecorderContextKey{}).(*EventRecorder)
+	return recorder
+}
@@ -0,0 +1,86 @@
+package hz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/verifa/horizon/pkg/extensions/core"
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+// EventReconciler records an event for each reconcile.
+type EventReconciler struct{}
+
+func (r *EventReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	recorder := hz.EventRecorderFromContext(ctx)
+	if err := recorder.Event(
+		ctx,
+		request.Key,
+		hz.EventTypeNormal,
+		"Reconciled",
+		"reconciled the object",
+	); err != nil {
+		return hz.Result{}, err
+	}
+	return hz.Result{}, nil
+}
+
+func TestReconcilerEvents(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	eventClient := hz.ObjectClient[core.Event]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&EventReconciler{}),
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	obj := DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	}
+	_, err = dummyClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+
+	var events []core.Event
+	waitFor(t, func() bool {
+		events, err = eventClient.List(ctx, hz.WithListKey(hz.ObjectKey{
+			Namespace: "test",
+		}))
+		tu.AssertNoError(t, err)
+		return len(events) > 0
+	})
+	tu.AssertEqual(t, 1, len(events))
+	tu.AssertTrue(t, events[0].IsFor(obj), "expected event for object")
+	tu.AssertEqual(t, "Reconciled", events[0].Reason)
+	tu.AssertEqual(t, "ctlr-DummyObject", events[0].Source)
+
+	// Without a recorder in the context, events are not recorded.
+	err = hz.EventRecorderFromContext(ctx).Event(
+		ctx,
+		obj,
+		hz.EventTypeNormal,
+		"Reconciled",
+		"reconciled the object",
+	)
+	tu.AssertNoError(t, err)
+}
@@ -0,0 +1,188 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/verifa/horizon/pkg/internal/managedfields"
+)
+
+// finalizerManagerSuffix is appended to the client's manager to get the field
+// manager that owns the finalizers added by [ObjectClient.AddFinalizer].
+//
+// Finalizers are applied by a separate manager so that applying only the
+// finalizers does not remove the other fields owned by the client's manager.
+const finalizerManagerSuffix = "-finalizers"
+
+// AddFinalizer adds the finalizer to the object in the store, if the object
+// does not have it already.
+// Only the namespace and name of object are used, and the latest revision of
+// the object is fetched from the store.
+func (oc ObjectClient[T]) AddFinalizer(
+	ctx context.Context,
+	object T,
+	finalizer string,
+) error {
+	current, err := oc.Get(ctx, WithGetKey(object))
+	if err != nil {
+		return err
+	}
+	if current.ObjectFinalizers().Contains(finalizer) {
+		return nil
+	}
+	owned := oc.ownedFinalizers(current)
+	owned = append(owned, finalizer)
+	if err := oc.applyFinalizers(ctx, current, owned); err != nil {
+		return fmt.Errorf("adding finalizer %q: %w", finalizer, err)
+	}
+	return nil
+}
+
+// RemoveFinalizer removes the finalizer from the object in the store, if the
+// object has it.
+// Only the namespace and name of object are used, and the latest revision of
+// the object is fetched from the store.
+//
+// Only finalizers added by the client's manager are removed, as the
+// finalizers owned by other managers are kept by the store.
+func (oc ObjectClient[T]) RemoveFinalizer(
+	ctx context.Context,
+	object T,
+	finalizer string,
+) error {
+	current, err := oc.Get(ctx, WithGetKey(object))
+	if err != nil {
+		return err
+	}
+	owned := oc.ownedFinalizers(current)
+	if !owned.Contains(finalizer) {
+		return nil
+	}
+	owned = slices.DeleteFunc(owned, func(f string) bool {
+		return f == finalizer
+	})
+	if err := oc.applyFinalizers(ctx, current, owned); err != nil {
+		return fmt.Errorf("removing finalizer %q: %w", finalizer, err)
+	}
+	return nil
+}
+
+// ownedFinalizers returns the finalizers of the object that are owned by the
+// client's finalizer manager.
+func (oc ObjectClient[T]) ownedFinalizers(object T) Finalizers {
+	owned := Finalizers{}
+	fm, ok := object.ObjectManagedFields().FieldManager(
+		oc.Client.Manager + finalizerManagerSuffix,
+	)
+	if !ok {
+		return owned
+	}
+	fields, ok := fm.FieldsV1.Lookup(managedfields.FieldsV1Path{
+		{Key: managedfields.FieldsV1Key{Key: "metadata"}},
+		{Key: managedfields.FieldsV1Key{Key: "finalizers"}},
+	})
+	if !ok {
+		return owned
+	}
+	// Elements of sets are keyed by their JSON value.
+	for key := range fields.Elements {
+		var finalizer string
+		if key.Type != managedfields.FieldsV1KeySet ||
+			json.Unmarshal([]byte(key.Value), &finalizer) != nil {
+			continue
+		}
+		if object.ObjectFinalizers().Contains(finalizer) {
+			owned = append(owned, finalizer)
+		}
+	}
+	slices.Sort(owned)
+	return owned
+}
+
+// applyFinalizers applies the finalizers owned by the client's finalizer
+// manager.
+// Finalizers are a set, so the finalizers owned by other managers are kept,
+// and concurrent changes to them are not lost.
+func (oc ObjectClient[T]) applyFinalizers(
+	ctx context.Context,
+	object T,
+	finalizers Finalizers,
+) error {
+	obj := MetaOnlyObject{
+		TypeMeta: TypeMeta{
+			APIVersion: object.ObjectGroup() + "/" + object.ObjectVersion(),
+			Kind:       object.ObjectKind(),
+		},
+		ObjectMeta: ObjectMeta{
+			Namespace:  object.ObjectNamespace(),
+			Name:       object.ObjectName(),
+			Finalizers: &finalizers,
+		},
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("marshalling object: %w", err)
+	}
+	client := oc.Client
+	client.Manager = client.Manager + finalizerManagerSuffix
+	if _, err := client.Apply(ctx, WithApplyData(data)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// FinalizerReconciler is implemented by reconcilers that need to clean up
+// before an object is deleted.
+// Use it with [FinalizingReconciler].
+type FinalizerReconciler[T Objecter] interface {
+	// TypedReconciler reconciles objects that are not being deleted.
+	TypedReconciler[T]
+	// Finalize cleans up an object that is being deleted.
+	// The finalizer is removed from the object only if Finalize returns a zero
+	// result and no error.
+	Finalize(ctx context.Context, object T) (Result, error)
+}
+
+// FinalizingReconciler is a [Reconciler] that adds a finalizer to objects,
+// so that the objects are not garbage collected until they have been
+// finalized.
+//
+// Objects that are not being deleted get the finalizer before they are
+// passed to Reconciler.Reconcile.
+// Objects that are being deleted, and have the finalizer, are passed to
+// Reconciler.Finalize, after which the finalizer is removed.
+type FinalizingReconciler[T Objecter] struct {
+	Client ObjectClient[T]
+	// Finalizer is the name of the finalizer, e.g. "example.com/cleanup".
+	Finalizer  string
+	Reconciler FinalizerReconciler[T]
+}
+
+func (r *FinalizingReconciler[T]) Reconcile(
+	ctx context.Context,
+	req Request,
+) (Result, error) {
+	object, err := r.Client.Get(ctx, WithGetKey(req.Key))
+	if err != nil {
+		return Result{}, IgnoreNotFound(err)
+	}
+	if object.ObjectDeletionTimestamp() != nil {
+		if !object.ObjectFinalizers().Contains(r.Finalizer) {
+			return Result{}, nil
+		}
+		result, err := r.Reconciler.Finalize(ctx, object)
+		if err != nil || !result.IsZero() {
+			return result, err
+		}
+		if err := r.Client.RemoveFinalizer(ctx, object, r.Finalizer); err != nil {
+			return Result{}, IgnoreNotFound(err)
+		}
+		return Result{}, nil
+	}
+	if err := r.Client.AddFinalizer(ctx, object, r.Finalizer); err != nil {
+		return Result{}, IgnoreNotFound(err)
+	}
+	return r.Reconciler.Reconcile(ctx, object)
+}
@@ -0,0 +1,189 @@
+package hz_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+const testFinalizer = "test/cleanup"
+
+type finalizerReconciler struct {
+	reconciled atomic.Int32
+	finalized  atomic.Int32
+}
+
+func (r *finalizerReconciler) Reconcile(
+	ctx context.Context,
+	object DummyObject,
+) (hz.Result, error) {
+	r.reconciled.Add(1)
+	return hz.Result{}, nil
+}
+
+func (r *finalizerReconciler) Finalize(
+	ctx context.Context,
+	object DummyObject,
+) (hz.Result, error) {
+	r.finalized.Add(1)
+	return hz.Result{}, nil
+}
+
+func TestFinalizingReconciler(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	fr := finalizerReconciler{}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&hz.FinalizingReconciler[DummyObject]{
+			Client:     dummyClient,
+			Finalizer:  testFinalizer,
+			Reconciler: &fr,
+		}),
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	do := DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	}
+	_, err = dummyClient.Apply(ctx, do)
+	tu.AssertNoError(t, err)
+
+	// The finalizer should be added before the object is reconciled.
+	waitFor(t, func() bool {
+		obj, err := dummyClient.Get(ctx, hz.WithGetKey(do))
+		tu.AssertNoError(t, err)
+		return obj.Finalizers.Contains(testFinalizer) &&
+			fr.reconciled.Load() > 0
+	})
+	// Applying the object again should not remove the finalizer, as it is
+	// owned by a different field manager.
+	_, err = dummyClient.Apply(ctx, do)
+	tu.AssertNoError(t, err)
+	obj, err := dummyClient.Get(ctx, hz.WithGetKey(do))
+	tu.AssertNoError(t, err)
+	tu.AssertTrue(t, obj.Finalizers.Contains(testFinalizer))
+
+	// Deleting the object should finalize it, remove the finalizer and let
+	// the garbage collector delete it.
+	err = dummyClient.Delete(ctx, do)
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		_, err := dummyClient.Get(ctx, hz.WithGetKey(do))
+		return errors.Is(err, hz.ErrNotFound)
+	})
+	tu.AssertEqual(t, fr.finalized.Load(), int32(1))
+}
+
+func TestObjectClientFinalizers(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	do := DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	}
+	err = dummyClient.AddFinalizer(ctx, do, "a")
+	tu.AssertErrorIs(t, err, hz.ErrNotFound)
+
+	_, err = dummyClient.Apply(ctx, do)
+	tu.AssertNoError(t, err)
+
+	err = dummyClient.AddFinalizer(ctx, do, "a")
+	tu.AssertNoError(t, err)
+	err = dummyClient.AddFinalizer(ctx, do, "b")
+	tu.AssertNoError(t, err)
+	// Adding an existing finalizer is a no-op.
+	err = dummyClient.AddFinalizer(ctx, do, "a")
+	tu.AssertNoError(t, err)
+	obj, err := dummyClient.Get(ctx, hz.WithGetKey(do))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, *obj.Finalizers, hz.Finalizers{"a", "b"})
+
+	err = dummyClient.RemoveFinalizer(ctx, do, "a")
+	tu.AssertNoError(t, err)
+	// Removing a missing finalizer is a no-op.
+	err = dummyClient.RemoveFinalizer(ctx, do, "c")
+	tu.AssertNoError(t, err)
+	obj, err = dummyClient.Get(ctx, hz.WithGetKey(do))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, *obj.Finalizers, hz.Finalizers{"b"})
+
+	// Finalizers are owned by the manager that added them, so managers do not
+	// remove each other's finalizers.
+	otherClient := hz.ObjectClient[DummyObject]{
+		Client: hz.NewClient(
+			ti.Conn,
+			hz.WithClientInternal(true),
+			hz.WithClientManager("other"),
+		),
+	}
+	err = otherClient.AddFinalizer(ctx, do, "c")
+	tu.AssertNoError(t, err)
+	err = dummyClient.RemoveFinalizer(ctx, do, "c")
+	tu.AssertNoError(t, err)
+	err = dummyClient.RemoveFinalizer(ctx, do, "b")
+	tu.AssertNoError(t, err)
+	obj, err = dummyClient.Get(ctx, hz.WithGetKey(do))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, *obj.Finalizers, hz.Finalizers{"c"})
+	tu.AssertEqual(t, obj.Name, "dummy")
+
+	err = otherClient.RemoveFinalizer(ctx, do, "c")
+	tu.AssertNoError(t, err)
+	obj, err = dummyClient.Get(ctx, hz.WithGetKey(do))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, len(*obj.Finalizers), 0)
+}
+
+// waitFor polls cond until it returns true, or fails the test after a
+// timeout.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
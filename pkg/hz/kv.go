@@ -1 +1,38 @@
 package hz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// keyValue returns the bucket of the config, and creates it if it does not
+// exist.
+//
+// The store creates the buckets when it starts, but a controller can be newer
+// than the store (e.g. during an upgrade), so the controller creates the
+// buckets it needs instead of failing to start.
+func keyValue(
+	ctx context.Context,
+	js jetstream.JetStream,
+	cfg jetstream.KeyValueConfig,
+) (jetstream.KeyValue, error) {
+	kv, err := js.KeyValue(ctx, cfg.Bucket)
+	if err == nil {
+		return kv, nil
+	}
+	if !errors.Is(err, jetstream.ErrBucketNotFound) {
+		return nil, fmt.Errorf("get bucket %q: %w", cfg.Bucket, err)
+	}
+	kv, err = js.CreateKeyValue(ctx, cfg)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrBucketExists) {
+			// Another instance created the bucket in the meantime.
+			return js.KeyValue(ctx, cfg.Bucket)
+		}
+		return nil, fmt.Errorf("create bucket %q: %w", cfg.Bucket, err)
+	}
+	return kv, nil
+}
@@ -0,0 +1,323 @@
+package hz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type LeaderElectorOption func(*leaderElectorOptions)
+
+// WithLeaderElectorBucket sets the KV bucket that stores the leases.
+// The TTL of the bucket is the duration of a lease.
+func WithLeaderElectorBucket(bucket string) LeaderElectorOption {
+	return func(o *leaderElectorOptions) {
+		o.bucket = bucket
+	}
+}
+
+// WithLeaderElectorIdentity sets the identity of the candidate, which is
+// stored in the lease while it is the leader.
+// Defaults to the hostname and a random suffix.
+func WithLeaderElectorIdentity(identity string) LeaderElectorOption {
+	return func(o *leaderElectorOptions) {
+		o.identity = identity
+	}
+}
+
+// WithLeaderElectorOnGained adds a callback for when the candidate becomes the
+// leader.
+// The context is cancelled when the candidate stops being the leader.
+//
+// Callbacks are called from the election loop, which does not renew the lease
+// until they return, so they must return well within a third of the lease
+// duration.
+// E.g. a controller's callback starts the consumers of its reconciler, which
+// takes a few requests to NATS.
+func WithLeaderElectorOnGained(fn func(ctx context.Context)) LeaderElectorOption {
+	return func(o *leaderElectorOptions) {
+		o.onGained = append(o.onGained, fn)
+	}
+}
+
+// WithLeaderElectorOnLost adds a callback for when the candidate stops being
+// the leader, e.g. because it could not renew the lease, or it was stopped.
+//
+// Callbacks are called from the election loop, so they must not block.
+// Work started with the context of [WithLeaderElectorOnGained] is cancelled
+// before the callbacks are called, but might still be running.
+func WithLeaderElectorOnLost(fn func()) LeaderElectorOption {
+	return func(o *leaderElectorOptions) {
+		o.onLost = append(o.onLost, fn)
+	}
+}
+
+type leaderElectorOptions struct {
+	bucket   string
+	identity string
+	onGained []func(ctx context.Context)
+	onLost   []func()
+}
+
+var leaderElectorOptionsDefault = leaderElectorOptions{
+	bucket: BucketLeases,
+}
+
+// leaderElection is the configuration of a leader election, for controllers
+// and watchers.
+type leaderElection struct {
+	name string
+	opts []LeaderElectorOption
+}
+
+// LeaderElector elects a single leader among the candidates with the same
+// name, using a lease in a NATS KV bucket.
+//
+// The leader renews the lease at a third of its duration (the TTL of the
+// bucket).
+// If the leader fails to renew the lease, it stops being the leader, and
+// another candidate can acquire the lease once it expires.
+type LeaderElector struct {
+	Conn *nats.Conn
+
+	name     string
+	identity string
+	kv       jetstream.KeyValue
+	ttl      time.Duration
+	onGained []func(ctx context.Context)
+	onLost   []func()
+
+	mu            sync.Mutex
+	leading       bool
+	rev           uint64
+	cancelLeading context.CancelFunc
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	// campaigned is closed after the first attempt to acquire the lease.
+	campaigned chan struct{}
+}
+
+// StartLeaderElector starts a leader election for the given name, with the
+// current process as a candidate.
+func StartLeaderElector(
+	ctx context.Context,
+	nc *nats.Conn,
+	name string,
+	opts ...LeaderElectorOption,
+) (*LeaderElector, error) {
+	le := LeaderElector{
+		Conn: nc,
+	}
+	if err := le.Start(ctx, name, opts...); err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+	return &le, nil
+}
+
+func (le *LeaderElector) Start(
+	ctx context.Context,
+	name string,
+	opts ...LeaderElectorOption,
+) error {
+	opt := leaderElectorOptionsDefault
+	for _, o := range opts {
+		o(&opt)
+	}
+	if name == "" {
+		return fmt.Errorf("leader election name is required")
+	}
+	if opt.identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		opt.identity = hostname + "-" + uuid.NewString()[:8]
+	}
+	js, err := jetstream.New(le.Conn)
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := js.KeyValue(ctx, opt.bucket)
+	if err != nil {
+		return fmt.Errorf("get lease bucket %q: %w", opt.bucket, err)
+	}
+	status, err := kv.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("get lease bucket %q status: %w", opt.bucket, err)
+	}
+	if status.TTL() <= 0 {
+		return fmt.Errorf("lease bucket %q has no TTL", opt.bucket)
+	}
+
+	le.name = name
+	le.identity = opt.identity
+	le.kv = kv
+	le.ttl = status.TTL()
+	le.onGained = opt.onGained
+	le.onLost = opt.onLost
+	le.stop = make(chan struct{})
+	le.done = make(chan struct{})
+	le.campaigned = make(chan struct{})
+
+	go le.run(ctx)
+	return nil
+}
+
+// Stop stops the candidate from taking part in the election.
+// If it is the leader, it releases the lease so that another candidate can
+// take over without waiting for the lease to expire.
+func (le *LeaderElector) Stop() error {
+	le.stopOnce.Do(func() {
+		close(le.stop)
+	})
+	<-le.done
+	return le.release()
+}
+
+// IsLeader returns true if the candidate is the leader.
+func (le *LeaderElector) IsLeader() bool {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	return le.leading
+}
+
+// Identity returns the identity of the candidate.
+func (le *LeaderElector) Identity() string {
+	return le.identity
+}
+
+func (le *LeaderElector) run(ctx context.Context) {
+	defer close(le.done)
+	ticker := time.NewTicker(le.ttl / 3)
+	defer ticker.Stop()
+	le.tryAcquireOrRenew(ctx)
+	close(le.campaigned)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-le.stop:
+			return
+		case <-ticker.C:
+		}
+		le.tryAcquireOrRenew(ctx)
+	}
+}
+
+// tryAcquireOrRenew renews the lease if the candidate is the leader, or
+// otherwise tries to acquire the lease.
+func (le *LeaderElector) tryAcquireOrRenew(ctx context.Context) {
+	le.mu.Lock()
+	leading, rev := le.leading, le.rev
+	le.mu.Unlock()
+
+	if leading {
+		newRev, err := le.kv.Update(ctx, le.name, []byte(le.identity), rev)
+		if err != nil {
+			slog.Error(
+				"renewing lease",
+				"name", le.name,
+				"identity", le.identity,
+				"error", err,
+			)
+			le.lost()
+			return
+		}
+		le.mu.Lock()
+		le.rev = newRev
+		le.mu.Unlock()
+		return
+	}
+
+	var newRev uint64
+	kve, err := le.kv.Get(ctx, le.name)
+	switch {
+	case errors.Is(err, jetstream.ErrKeyNotFound):
+		newRev, err = le.kv.Create(ctx, le.name, []byte(le.identity))
+		if err != nil {
+			if !errors.Is(err, jetstream.ErrKeyExists) {
+				slog.Error("acquiring lease", "name", le.name, "error", err)
+			}
+			return
+		}
+	case err != nil:
+		slog.Error("getting lease", "name", le.name, "error", err)
+		return
+	case len(kve.Value()) == 0:
+		// The lease was released.
+		newRev, err = le.kv.Update(
+			ctx,
+			le.name,
+			[]byte(le.identity),
+			kve.Revision(),
+		)
+		if err != nil {
+			if !isErrWrongLastSequence(err) {
+				slog.Error("acquiring lease", "name", le.name, "error", err)
+			}
+			return
+		}
+	default:
+		// Another candidate holds the lease.
+		return
+	}
+	le.gained(ctx, newRev)
+}
+
+func (le *LeaderElector) gained(ctx context.Context, rev uint64) {
+	leadingCtx, cancel := context.WithCancel(ctx)
+	le.mu.Lock()
+	le.leading = true
+	le.rev = rev
+	le.cancelLeading = cancel
+	le.mu.Unlock()
+
+	slog.Info("gained leadership", "name", le.name, "identity", le.identity)
+	metricLeader.WithLabelValues(le.name).Set(1)
+	for _, fn := range le.onGained {
+		fn(leadingCtx)
+	}
+}
+
+func (le *LeaderElector) lost() {
+	le.mu.Lock()
+	if !le.leading {
+		le.mu.Unlock()
+		return
+	}
+	le.leading = false
+	le.cancelLeading()
+	le.mu.Unlock()
+
+	slog.Info("lost leadership", "name", le.name, "identity", le.identity)
+	metricLeader.WithLabelValues(le.name).Set(0)
+	for _, fn := range le.onLost {
+		fn()
+	}
+}
+
+// release gives up the lease, if the candidate is the leader.
+func (le *LeaderElector) release() error {
+	le.mu.Lock()
+	leading, rev := le.leading, le.rev
+	le.mu.Unlock()
+	if !leading {
+		return nil
+	}
+	le.lost()
+	// If releasing the lease fails, it expires after its TTL.
+	if _, err := le.kv.Update(context.Background(), le.name, nil, rev); err != nil {
+		return fmt.Errorf("releasing lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package hz_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	"github.com/verifa/horizon/pkg/store"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+func TestLeaderElector(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(
+		t,
+		ctx,
+		server.WithStoreOptions(store.WithLeaseTTL(time.Second)),
+	)
+
+	var gained1, lost1, gained2 atomic.Int32
+	le1, err := hz.StartLeaderElector(
+		ctx,
+		ti.Conn,
+		"test-election",
+		hz.WithLeaderElectorIdentity("one"),
+		hz.WithLeaderElectorOnGained(func(ctx context.Context) {
+			gained1.Add(1)
+		}),
+		hz.WithLeaderElectorOnLost(func() {
+			lost1.Add(1)
+		}),
+	)
+	tu.AssertNoError(t, err)
+	waitFor(t, le1.IsLeader)
+
+	le2, err := hz.StartLeaderElector(
+		ctx,
+		ti.Conn,
+		"test-election",
+		hz.WithLeaderElectorIdentity("two"),
+		hz.WithLeaderElectorOnGained(func(ctx context.Context) {
+			gained2.Add(1)
+		}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = le2.Stop()
+	})
+	// The lease is renewed, so the leader does not change.
+	time.Sleep(time.Second * 2)
+	tu.AssertTrue(t, le1.IsLeader(), "expected first candidate to lead")
+	tu.AssertTrue(t, !le2.IsLeader(), "expected second candidate to follow")
+
+	// Stopping the leader releases the lease.
+	err = le1.Stop()
+	tu.AssertNoError(t, err)
+	waitFor(t, le2.IsLeader)
+	tu.AssertEqual(t, int32(1), gained1.Load())
+	tu.AssertEqual(t, int32(1), lost1.Load())
+	tu.AssertEqual(t, int32(1), gained2.Load())
+}
+
+// countingReconciler counts its reconciles.
+type countingReconciler struct {
+	reconciles atomic.Int32
+}
+
+func (r *countingReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	r.reconciles.Add(1)
+	return hz.Result{}, nil
+}
+
+func TestReconcilerLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(
+		t,
+		ctx,
+		server.WithStoreOptions(store.WithLeaseTTL(time.Second)),
+	)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	cr1 := countingReconciler{}
+	ctlr1, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&cr1),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerLeaderElection(
+			"dummy-controller",
+			hz.WithLeaderElectorIdentity("one"),
+		),
+	)
+	tu.AssertNoError(t, err)
+	waitFor(t, ctlr1.IsLeader)
+	cr2 := countingReconciler{}
+	ctlr2, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&cr2),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerLeaderElection(
+			"dummy-controller",
+			hz.WithLeaderElectorIdentity("two"),
+		),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr2.Stop()
+	})
+	tu.AssertTrue(t, !ctlr2.IsLeader(), "expected second controller to follow")
+
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "one",
+		},
+	})
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		return cr1.reconciles.Load() == 1
+	})
+	tu.AssertEqual(t, int32(0), cr2.reconciles.Load())
+
+	// Once the leader stops, the other controller takes over.
+	err = ctlr1.Stop()
+	tu.AssertNoError(t, err)
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "two",
+		},
+	})
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		return cr2.reconciles.Load() >= 1
+	})
+	tu.AssertEqual(t, int32(1), cr1.reconciles.Load())
+}
+
+func TestWatcherLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	var events [2]atomic.Int32
+	for i := range events {
+		i := i
+		w, err := hz.StartWatcher(
+			ctx,
+			ti.Conn,
+			hz.WithWatcherFor(&DummyObject{}),
+			hz.WithWatcherFn(func(event hz.Event) (hz.Result, error) {
+				events[i].Add(1)
+				return hz.Result{}, nil
+			}),
+			hz.WithWatcherLeaderElection("dummy-watcher"),
+		)
+		tu.AssertNoError(t, err)
+		t.Cleanup(w.Close)
+	}
+
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	})
+	tu.AssertNoError(t, err)
+	// Only the leader receives the event.
+	waitFor(t, func() bool {
+		return events[0].Load()+events[1].Load() > 0
+	})
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, int32(1), events[0].Load()+events[1].Load())
+}
+
+func TestWatcherLeaderElectionInit(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	})
+	tu.AssertNoError(t, err)
+
+	// Both the leader and the other watcher are initialised.
+	for i := 0; i < 2; i++ {
+		w, err := hz.StartWatcher(
+			ctx,
+			ti.Conn,
+			hz.WithWatcherFor(&DummyObject{}),
+			hz.WithWatcherFn(func(event hz.Event) (hz.Result, error) {
+				return hz.Result{}, nil
+			}),
+			hz.WithWatcherLeaderElection("dummy-watcher-init"),
+		)
+		tu.AssertNoError(t, err)
+		t.Cleanup(w.Close)
+		select {
+		case <-w.Init:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("timed out waiting for watcher %d to initialise", i)
+		}
+	}
+}
+
+// blockingReconciler blocks until its context is cancelled.
+type blockingReconciler struct {
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (r *blockingReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	r.started <- struct{}{}
+	<-ctx.Done()
+	r.cancelled <- struct{}{}
+	return hz.Result{}, ctx.Err()
+}
+
+func TestReconcilerLeaderElectionLost(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(
+		t,
+		ctx,
+		server.WithStoreOptions(store.WithLeaseTTL(time.Second)),
+	)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	br := blockingReconciler{
+		started:   make(chan struct{}, 1),
+		cancelled: make(chan struct{}, 1),
+	}
+	ctlr1, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&br),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerLeaderElection(
+			"dummy-controller",
+			hz.WithLeaderElectorIdentity("one"),
+		),
+	)
+	tu.AssertNoError(t, err)
+	waitFor(t, ctlr1.IsLeader)
+
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "one",
+		},
+	})
+	tu.AssertNoError(t, err)
+	<-br.started
+
+	// Once the leadership is lost, the reconcile in progress is cancelled,
+	// and the new leader reconciles the object.
+	err = ctlr1.Stop()
+	tu.AssertNoError(t, err)
+	<-br.cancelled
+	cr2 := countingReconciler{}
+	ctlr2, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&cr2),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerLeaderElection(
+			"dummy-controller",
+			hz.WithLeaderElectorIdentity("two"),
+		),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr2.Stop()
+	})
+	waitFor(t, func() bool {
+		return cr2.reconciles.Load() >= 1
+	})
+}
@@ -2,7 +2,10 @@ package hz
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/verifa/horizon/pkg/internal/managedfields"
 )
@@ -88,3 +91,240 @@ func mapToObject[T Objecter](m map[string]interface{}) (T, error) {
 	}
 	return t, nil
 }
+
+// MergeSchemaKey returns the key of the merge schema for the kind and version
+// of the given object key.
+func MergeSchemaKey(key ObjectKeyer) string {
+	return key.ObjectGroup() + "." + key.ObjectVersion() + "." + key.ObjectKind()
+}
+
+// mergeSchemaFromObject returns the schema for merging the lists of the
+// object during server-side apply.
+//
+// The list type of a field is set using the hz struct tag, e.g.
+//
+//	Env   []EnvVar `json:"env"   hz:"listType=map,listMapKey=name"`
+//	Tags  []string `json:"tags"  hz:"listType=set"`
+//	Args  []string `json:"args"  hz:"listType=atomic"`
+func mergeSchemaFromObject(obj Objecter) (managedfields.Schema, error) {
+	return mergeSchemaFromType(reflect.TypeOf(obj), map[reflect.Type]bool{})
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+func mergeSchemaFromType(
+	t reflect.Type,
+	visited map[reflect.Type]bool,
+) (managedfields.Schema, error) {
+	if t.Kind() == reflect.Ptr {
+		return mergeSchemaFromType(t.Elem(), visited)
+	}
+	schema := managedfields.Schema{}
+	// Types with custom JSON encoding (like [Time]) have no lists that we
+	// know of, and recursive types are not followed.
+	if t.Kind() != reflect.Struct ||
+		t.Implements(jsonMarshalerType) ||
+		reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		visited[t] {
+		return schema, nil
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		// Fields of embedded structs without a JSON name are inlined into the
+		// parent.
+		if isInlined(field) {
+			embedded, err := mergeSchemaFromType(field.Type, visited)
+			if err != nil {
+				return schema, err
+			}
+			for k, v := range embedded.Fields {
+				schema = withSchemaField(schema, k, v)
+			}
+			continue
+		}
+		fieldSchema, err := mergeSchemaFromField(field, visited)
+		if err != nil {
+			return schema, fmt.Errorf("field %q: %w", field.Name, err)
+		}
+		if !fieldSchema.IsZero() {
+			schema = withSchemaField(schema, name, fieldSchema)
+		}
+	}
+	return schema, nil
+}
+
+func mergeSchemaFromField(
+	field reflect.StructField,
+	visited map[reflect.Type]bool,
+) (managedfields.Schema, error) {
+	fieldType := field.Type
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	tag, hasTag := field.Tag.Lookup("hz")
+	isList := (fieldType.Kind() == reflect.Slice ||
+		fieldType.Kind() == reflect.Array) &&
+		fieldType.Elem().Kind() != reflect.Uint8 &&
+		!fieldType.Implements(jsonMarshalerType)
+	if !isList {
+		if hasTag {
+			return managedfields.Schema{}, errors.New(
+				"hz tag can only be used on lists",
+			)
+		}
+		return mergeSchemaFromType(fieldType, visited)
+	}
+	schema, err := parseListTag(tag)
+	if err != nil {
+		return managedfields.Schema{}, err
+	}
+	elemType := fieldType.Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	// Conditions are identified by their type, unless the tag says otherwise.
+	if !hasTag && elemType == conditionType {
+		schema = managedfields.Schema{
+			ListType:   managedfields.ListTypeMap,
+			ListMapKey: "type",
+		}
+	}
+	elemSchema, err := mergeSchemaFromType(elemType, visited)
+	if err != nil {
+		return managedfields.Schema{}, err
+	}
+	schema.Fields = elemSchema.Fields
+	switch schema.ListType {
+	case managedfields.ListTypeSet:
+		if elemType.Kind() == reflect.Struct ||
+			elemType.Kind() == reflect.Map ||
+			elemType.Kind() == reflect.Slice {
+			return managedfields.Schema{}, fmt.Errorf(
+				"listType=set requires scalar elements, got %s",
+				elemType,
+			)
+		}
+	case managedfields.ListTypeMap:
+		if elemType.Kind() != reflect.Struct {
+			return managedfields.Schema{}, fmt.Errorf(
+				"listType=map requires struct elements, got %s",
+				elemType,
+			)
+		}
+		if !hasJSONField(elemType, schema.ListMapKey) {
+			return managedfields.Schema{}, fmt.Errorf(
+				"listMapKey %q is not a field of %s",
+				schema.ListMapKey,
+				elemType,
+			)
+		}
+	}
+	return schema, nil
+}
+
+// parseListTag parses the hz struct tag of a list field, e.g.
+// "listType=map,listMapKey=name".
+func parseListTag(tag string) (managedfields.Schema, error) {
+	schema := managedfields.Schema{}
+	if tag == "" {
+		return schema, nil
+	}
+	for _, part := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return schema, fmt.Errorf("invalid hz tag %q", part)
+		}
+		switch key {
+		case "listType":
+			schema.ListType = managedfields.ListType(value)
+		case "listMapKey":
+			schema.ListMapKey = value
+		default:
+			return schema, fmt.Errorf("unknown hz tag key %q", key)
+		}
+	}
+	switch schema.ListType {
+	case managedfields.ListTypeAtomic, managedfields.ListTypeSet:
+		if schema.ListMapKey != "" {
+			return schema, fmt.Errorf(
+				"listMapKey requires listType=map, got listType=%s",
+				schema.ListType,
+			)
+		}
+	case managedfields.ListTypeMap:
+		if schema.ListMapKey == "" {
+			return schema, errors.New("listType=map requires listMapKey")
+		}
+	default:
+		return schema, fmt.Errorf("unknown listType %q", schema.ListType)
+	}
+	return schema, nil
+}
+
+// hasJSONField returns true if the struct has a field with the given JSON
+// name.
+func hasJSONField(t reflect.Type, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if isInlined(field) {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct &&
+				hasJSONField(fieldType, name) {
+				return true
+			}
+			continue
+		}
+		if jsonFieldName(field) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFieldName returns the name of the struct field in JSON.
+func jsonFieldName(field reflect.StructField) string {
+	if jTag, ok := field.Tag.Lookup("json"); ok {
+		if name := strings.Split(jTag, ",")[0]; name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// isInlined returns true if the struct field is embedded without a JSON name,
+// meaning its fields are inlined into the parent in JSON.
+func isInlined(field reflect.StructField) bool {
+	if !field.Anonymous {
+		return false
+	}
+	jTag, ok := field.Tag.Lookup("json")
+	return !ok || strings.Split(jTag, ",")[0] == ""
+}
+
+func withSchemaField(
+	schema managedfields.Schema,
+	name string,
+	field managedfields.Schema,
+) managedfields.Schema {
+	if schema.Fields == nil {
+		schema.Fields = map[string]managedfields.Schema{}
+	}
+	schema.Fields[name] = field
+	return schema
+}
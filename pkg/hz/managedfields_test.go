@@ -1,6 +1,7 @@
 package hz_test
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
@@ -8,6 +9,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/verifa/horizon/pkg/hz"
 	"github.com/verifa/horizon/pkg/internal/managedfields"
+	"github.com/verifa/horizon/pkg/server"
 	tu "github.com/verifa/horizon/pkg/testutil"
 )
 
@@ -34,7 +36,7 @@ func TestExtractObjectFields(t *testing.T) {
 
 	raw, err := json.Marshal(obj)
 	tu.AssertNoError(t, err)
-	fields, err := managedfields.ManagedFieldsV1(raw)
+	fields, err := managedfields.ManagedFieldsV1(raw, managedfields.Schema{})
 	tu.AssertNoError(t, err)
 	obj.ObjectMeta.ManagedFields = managedfields.ManagedFields{
 		{
@@ -91,3 +93,159 @@ func (o extractFieldsObject) ObjectGroup() string {
 func (o extractFieldsObject) ObjectVersion() string {
 	return "v1"
 }
+
+var _ hz.Objecter = (*listTypesObject)(nil)
+
+type listTypesObject struct {
+	hz.ObjectMeta `json:"metadata,omitempty" cue:""`
+
+	Spec *listTypesSpec `json:"spec,omitempty" cue:""`
+}
+
+type listTypesSpec struct {
+	Env  []listTypesEnv `json:"env,omitempty"  cue:",opt" hz:"listType=map,listMapKey=name"`
+	Tags []string       `json:"tags,omitempty" cue:",opt" hz:"listType=set"`
+	Args []string       `json:"args,omitempty" cue:",opt" hz:"listType=atomic"`
+}
+
+type listTypesEnv struct {
+	Name  string `json:"name"            cue:""`
+	Value string `json:"value,omitempty" cue:",opt"`
+}
+
+func (o listTypesObject) ObjectKind() string {
+	return "ListTypesObject"
+}
+
+func (o listTypesObject) ObjectGroup() string {
+	return "ListTypesGroup"
+}
+
+func (o listTypesObject) ObjectVersion() string {
+	return "v1"
+}
+
+func TestApplyListTypes(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&listTypesObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	m1 := hz.ObjectClient[listTypesObject]{
+		Client: hz.NewClient(
+			ti.Conn,
+			hz.WithClientInternal(true),
+			hz.WithClientManager("m1"),
+		),
+	}
+	m2 := hz.ObjectClient[listTypesObject]{
+		Client: hz.NewClient(
+			ti.Conn,
+			hz.WithClientInternal(true),
+			hz.WithClientManager("m2"),
+		),
+	}
+	meta := hz.ObjectMeta{
+		Namespace: "test",
+		Name:      "lists",
+	}
+	_, err = m1.Apply(ctx, listTypesObject{
+		ObjectMeta: meta,
+		Spec: &listTypesSpec{
+			Env:  []listTypesEnv{{Name: "A", Value: "1"}},
+			Tags: []string{"a"},
+		},
+	})
+	tu.AssertNoError(t, err)
+	// Both managers own elements in the map and set lists, without
+	// conflicting.
+	_, err = m2.Apply(ctx, listTypesObject{
+		ObjectMeta: meta,
+		Spec: &listTypesSpec{
+			Env:  []listTypesEnv{{Name: "B", Value: "2"}},
+			Tags: []string{"a", "b"},
+		},
+	})
+	tu.AssertNoError(t, err)
+
+	obj, err := m1.Get(ctx, hz.WithGetKey(listTypesObject{ObjectMeta: meta}))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, listTypesSpec{
+		Env: []listTypesEnv{
+			{Name: "A", Value: "1"},
+			{Name: "B", Value: "2"},
+		},
+		Tags: []string{"a", "b"},
+	}, *obj.Spec)
+
+	// Both managers own the atomic list, so the second manager conflicts.
+	_, err = m1.Apply(ctx, listTypesObject{
+		ObjectMeta: meta,
+		Spec: &listTypesSpec{
+			Args: []string{"x"},
+		},
+	})
+	tu.AssertNoError(t, err)
+	result, err := m2.Apply(ctx, listTypesObject{
+		ObjectMeta: meta,
+		Spec: &listTypesSpec{
+			Env:  []listTypesEnv{{Name: "B", Value: "2"}},
+			Tags: []string{"a", "b"},
+			Args: []string{"y"},
+		},
+	})
+	tu.AssertEqual(t, hz.ApplyOpResultConflict, result)
+	tu.AssertTrue(t, err != nil, "expected conflict error")
+
+	// The first manager no longer applies its env var and tag. The env var is
+	// removed, but the tag is kept because the second manager owns it too.
+	obj, err = m1.Get(ctx, hz.WithGetKey(listTypesObject{ObjectMeta: meta}))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, listTypesSpec{
+		Env:  []listTypesEnv{{Name: "B", Value: "2"}},
+		Tags: []string{"a", "b"},
+		Args: []string{"x"},
+	}, *obj.Spec)
+}
+
+type invalidListTypeObject struct {
+	hz.ObjectMeta `json:"metadata,omitempty" cue:""`
+
+	Spec *struct {
+		Env []listTypesEnv `json:"env,omitempty" cue:",opt" hz:"listType=map,listMapKey=id"`
+	} `json:"spec,omitempty" cue:""`
+}
+
+func (o invalidListTypeObject) ObjectKind() string {
+	return "InvalidListTypeObject"
+}
+
+func (o invalidListTypeObject) ObjectGroup() string {
+	return "ListTypesGroup"
+}
+
+func (o invalidListTypeObject) ObjectVersion() string {
+	return "v1"
+}
+
+func TestApplyListTypesInvalid(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	_, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&invalidListTypeObject{}),
+	)
+	if err == nil {
+		t.Fatal("expected error for list map key that is not a field")
+	}
+}
@@ -0,0 +1,93 @@
+package hz
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricReconciles = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "horizon",
+			Subsystem: "controller",
+			Name:      "reconciles_total",
+			Help:      "Total number of reconciles per controller kind.",
+		},
+		[]string{"kind"},
+	)
+	metricReconcileErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "horizon",
+			Subsystem: "controller",
+			Name:      "reconcile_errors_total",
+			Help:      "Total number of reconcile errors per controller kind.",
+		},
+		[]string{"kind"},
+	)
+	metricReconcileRequeues = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "horizon",
+			Subsystem: "controller",
+			Name:      "reconcile_requeues_total",
+			Help:      "Total number of requeued reconciles per controller kind.",
+		},
+		[]string{"kind"},
+	)
+	metricReconcileDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "horizon",
+			Subsystem: "controller",
+			Name:      "reconcile_duration_seconds",
+			Help:      "Duration of reconciles per controller kind.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"kind"},
+	)
+	metricActiveReconciles = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "horizon",
+			Subsystem: "controller",
+			Name:      "active_reconciles",
+			Help:      "Number of reconciles in progress per controller kind.",
+		},
+		[]string{"kind"},
+	)
+	metricFilteredEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "horizon",
+			Subsystem: "controller",
+			Name:      "filtered_events_total",
+			Help:      "Total number of changes skipped by predicates per controller kind.",
+		},
+		[]string{"kind"},
+	)
+
+	metricLeader = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "horizon",
+			Subsystem: "leader_election",
+			Name:      "is_leader",
+			Help:      "Whether this instance is the leader (1) or not (0) per election.",
+		},
+		[]string{"name"},
+	)
+
+	metricWatcherLagMessages = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "horizon",
+			Subsystem: "watcher",
+			Name:      "lag_messages",
+			Help:      "Number of messages a watcher has yet to receive.",
+		},
+		[]string{"kind"},
+	)
+	metricWatcherLagSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "horizon",
+			Subsystem: "watcher",
+			Name:      "lag_seconds",
+			Help:      "Delay between an event being stored and a watcher receiving it.",
+		},
+		[]string{"kind"},
+	)
+)
@@ -18,6 +18,7 @@ type Objecter interface {
 	ObjectOwnerReferences() []OwnerReference
 	ObjectOwnerReference(Objecter) (OwnerReference, bool)
 	ObjectManagedFields() managedfields.ManagedFields
+	ObjectFinalizers() *Finalizers
 }
 
 // ObjectKeyer is an interface that can produce a unique key for an object.
@@ -202,7 +203,10 @@ type ObjectMeta struct {
 	// Use type alias to "correctly" marshal to json.
 	// A nil Finalizers is omitted from JSON.
 	// A non-nil Finalizers is marshalled as an empty array if it is empty.
-	Finalizers *Finalizers `json:"finalizers,omitempty"        cue:",opt"`
+	//
+	// Finalizers are a set, so each finalizer can be owned by a different
+	// field manager.
+	Finalizers *Finalizers `json:"finalizers,omitempty"        cue:",opt" hz:"listType=set"`
 }
 
 func (o ObjectMeta) ObjectName() string {
@@ -249,6 +253,14 @@ func (o ObjectMeta) ObjectManagedFields() managedfields.ManagedFields {
 	return o.ManagedFields
 }
 
+func (o ObjectMeta) ObjectFinalizers() *Finalizers {
+	return o.Finalizers
+}
+
+func (o ObjectMeta) ObjectLabels() map[string]string {
+	return o.Labels
+}
+
 type TypeMeta struct {
 	APIVersion string `json:"apiVersion,omitempty"`
 	Kind       string `json:"kind,omitempty"`
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -122,12 +124,25 @@ func (e *PortalHandler) Start(ctx context.Context) error {
 					return
 				}
 			}
-			outReq := req.WithContext(ctx)
+			// The gateway propagates the trace context in the HTTP
+			// request headers.
+			reqCtx, span := Tracer().Start(
+				TraceExtractHTTP(ctx, req.Header),
+				"portal.request",
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("hz.portal", e.ext.Name),
+					attribute.String("http.method", req.Method),
+					attribute.String("http.target", req.URL.Path),
+				),
+			)
+			outReq := req.WithContext(reqCtx)
 			rr := NATSResponseWriter{
 				conn:    e.conn,
 				subject: msg.Reply,
 			}
 			go func() {
+				defer span.End()
 				defer rr.Flush()
 				e.handler.ServeHTTP(&rr, outReq)
 			}()
@@ -0,0 +1,252 @@
+package hz
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// PredicateEvent is a change to an object of a controller's kind, which
+// predicates decide whether to reconcile.
+type PredicateEvent struct {
+	// Old is the digest of the previous revision of the object that the
+	// controller saw.
+	// It is nil if the controller has not seen the object before, e.g. when
+	// the object is created or another instance of the controller consumed
+	// the previous revision.
+	Old *ObjectDigest
+	// New is the changed object.
+	New GenericObject
+}
+
+// ObjectDigest summarises a revision of an object with hashes of its parts,
+// which predicates compare with the changed object.
+//
+// Controllers keep the digest of the last revision of each object, rather
+// than the object, so that the memory they use does not grow with the size of
+// the objects.
+type ObjectDigest struct {
+	// Spec is the hash of the fields other than the metadata and status.
+	Spec uint64
+	// Status is the hash of the status.
+	Status uint64
+	// Metadata is the hash of the metadata, except the revision and managed
+	// fields.
+	Metadata uint64
+	// Labels is the hash of the labels.
+	Labels uint64
+	// Deleting is true if the object has a deletion timestamp.
+	Deleting bool
+}
+
+// DigestObject returns the digest of the object.
+func DigestObject(obj GenericObject) ObjectDigest {
+	fields := objectFields(obj)
+	metadata := fields["metadata"]
+	status := fields["status"]
+	delete(fields, "metadata")
+	delete(fields, "status")
+	return ObjectDigest{
+		Spec:     hashValue(fields),
+		Status:   hashValue(status),
+		Metadata: hashValue(metadata),
+		Labels:   hashValue(obj.Labels),
+		Deleting: obj.DeletionTimestamp != nil,
+	}
+}
+
+// hashValue returns the hash of the JSON encoding of the value, which sorts
+// the keys of maps.
+func hashValue(value interface{}) uint64 {
+	data, err := json.Marshal(value)
+	if err != nil {
+		// Values from decoding JSON always encode, but fall back to
+		// formatting the value anyway.
+		data = []byte(fmt.Sprint(value))
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+// Predicate decides whether a change to an object should be reconciled.
+//
+// Predicates are evaluated before the controller acquires the lock for the
+// object, so they should be cheap and must not call the store.
+type Predicate interface {
+	// Allow returns true if the event should be reconciled.
+	Allow(event PredicateEvent) bool
+}
+
+var (
+	_ Predicate = (PredicateFunc)(nil)
+	_ Predicate = (*GenerationChangedPredicate)(nil)
+	_ Predicate = (*LabelsChangedPredicate)(nil)
+	_ Predicate = (*IgnoreStatusOnlyPredicate)(nil)
+	_ Predicate = (*IgnoreDeletesPredicate)(nil)
+	_ Predicate = (OrPredicate)(nil)
+)
+
+// PredicateFunc is a function that implements [Predicate].
+type PredicateFunc func(event PredicateEvent) bool
+
+func (f PredicateFunc) Allow(event PredicateEvent) bool {
+	return f(event)
+}
+
+// GenerationChangedPredicate allows events that change the generation of an
+// object, i.e. anything other than its metadata or status (usually the spec).
+// Events that set or remove the deletion timestamp of an object are always
+// allowed, so that finalizers run.
+type GenerationChangedPredicate struct{}
+
+func (GenerationChangedPredicate) Allow(event PredicateEvent) bool {
+	if event.Old == nil {
+		return true
+	}
+	digest := DigestObject(event.New)
+	return event.Old.Spec != digest.Spec ||
+		event.Old.Deleting != digest.Deleting
+}
+
+// LabelsChangedPredicate allows events that change the labels of an object.
+type LabelsChangedPredicate struct{}
+
+func (LabelsChangedPredicate) Allow(event PredicateEvent) bool {
+	if event.Old == nil {
+		return true
+	}
+	return event.Old.Labels != hashValue(event.New.Labels)
+}
+
+// IgnoreStatusOnlyPredicate ignores events that only change the status of an
+// object, such as a reconciler writing the status of the object it
+// reconciles.
+type IgnoreStatusOnlyPredicate struct{}
+
+func (IgnoreStatusOnlyPredicate) Allow(event PredicateEvent) bool {
+	if event.Old == nil {
+		return true
+	}
+	digest := DigestObject(event.New)
+	return event.Old.Spec != digest.Spec ||
+		event.Old.Metadata != digest.Metadata
+}
+
+// IgnoreDeletesPredicate ignores events for objects that are being deleted,
+// i.e. that have a deletion timestamp.
+//
+// Do not use it with finalizers, as the reconciler would not be called to
+// remove them.
+type IgnoreDeletesPredicate struct{}
+
+func (IgnoreDeletesPredicate) Allow(event PredicateEvent) bool {
+	return event.New.DeletionTimestamp == nil
+}
+
+// OrPredicate allows events that any of its predicates allow.
+// For example, to reconcile when either the spec or the labels change.
+type OrPredicate []Predicate
+
+func (p OrPredicate) Allow(event PredicateEvent) bool {
+	for _, predicate := range p {
+		if predicate.Allow(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowEvent returns true if all of the predicates allow the event.
+func allowEvent(predicates []Predicate, event PredicateEvent) bool {
+	for _, predicate := range predicates {
+		if !predicate.Allow(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// objectFields returns the top-level fields of the object, for comparing two
+// revisions of an object.
+//
+// The revision and managed fields change with every write, so they are
+// always removed from the metadata.
+func objectFields(obj GenericObject) map[string]interface{} {
+	fields := make(map[string]interface{}, len(obj.Remaining))
+	for field, raw := range obj.Remaining {
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Fall back to comparing the raw JSON.
+			value = string(raw)
+		}
+		if meta, ok := value.(map[string]interface{}); ok && field == "metadata" {
+			delete(meta, "revision")
+			delete(meta, "managedFields")
+		}
+		fields[field] = value
+	}
+	return fields
+}
+
+// seenObjects records the digest of the last revision of each object that a
+// controller instance consumed, to get the old object of a [PredicateEvent]
+// and to find revisions that only set the ReconcileFailed condition.
+type seenObjects struct {
+	mu      sync.Mutex
+	entries map[string]seenObject
+}
+
+type seenObject struct {
+	// sequence is the stream sequence of the message (i.e. the revision of
+	// the object).
+	sequence uint64
+	digest   ObjectDigest
+	// withoutReconcileFailed is the hash of the object without the
+	// ReconcileFailed condition.
+	withoutReconcileFailed uint64
+}
+
+// swap records the digest of the object, and returns the previously seen
+// revision of it.
+// If the revision is not newer than the last one seen (e.g. the message was
+// redelivered), it returns false.
+func (s *seenObjects) swap(
+	key string,
+	sequence uint64,
+	obj GenericObject,
+) (*seenObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]seenObject)
+	}
+	old, ok := s.entries[key]
+	if ok && sequence <= old.sequence {
+		return nil, false
+	}
+	s.entries[key] = seenObject{
+		sequence:               sequence,
+		digest:                 DigestObject(obj),
+		withoutReconcileFailed: hashWithoutReconcileFailed(obj),
+	}
+	if !ok {
+		return nil, true
+	}
+	return &old, true
+}
+
+// get returns the last seen revision of the object.
+func (s *seenObjects) get(key string) (seenObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.entries[key]
+	return old, ok
+}
+
+func (s *seenObjects) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
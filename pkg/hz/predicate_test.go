@@ -0,0 +1,167 @@
+package hz_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+func TestPredicates(t *testing.T) {
+	// A fake stream of revisions of an object.
+	stream := []string{
+		`{"metadata":{"name":"a","revision":1},"spec":{"replicas":1}}`,
+		`{"metadata":{"name":"a","revision":2},"spec":{"replicas":1},"status":{"ready":true}}`,
+		`{"metadata":{"name":"a","revision":3,"labels":{"app":"a"}},"spec":{"replicas":1},"status":{"ready":true}}`,
+		`{"metadata":{"name":"a","revision":4,"labels":{"app":"a"}},"spec":{"replicas":2},"status":{"ready":true}}`,
+		`{"metadata":{"name":"a","revision":5,"labels":{"app":"a"},"deletionTimestamp":"2024-01-01T00:00:00Z"},"spec":{"replicas":2},"status":{"ready":true}}`,
+	}
+	events := make([]hz.PredicateEvent, len(stream))
+	var old *hz.ObjectDigest
+	for i, data := range stream {
+		var obj hz.GenericObject
+		err := json.Unmarshal([]byte(data), &obj)
+		tu.AssertNoError(t, err)
+		events[i] = hz.PredicateEvent{Old: old, New: obj}
+		digest := hz.DigestObject(obj)
+		old = &digest
+	}
+
+	tests := []struct {
+		name      string
+		predicate hz.Predicate
+		exp       []bool
+	}{
+		{
+			name:      "generation changed",
+			predicate: hz.GenerationChangedPredicate{},
+			exp:       []bool{true, false, false, true, true},
+		},
+		{
+			name:      "labels changed",
+			predicate: hz.LabelsChangedPredicate{},
+			exp:       []bool{true, false, true, false, false},
+		},
+		{
+			name:      "ignore status only",
+			predicate: hz.IgnoreStatusOnlyPredicate{},
+			exp:       []bool{true, false, true, true, true},
+		},
+		{
+			name:      "ignore deletes",
+			predicate: hz.IgnoreDeletesPredicate{},
+			exp:       []bool{true, true, true, true, false},
+		},
+		{
+			name: "or",
+			predicate: hz.OrPredicate{
+				hz.GenerationChangedPredicate{},
+				hz.LabelsChangedPredicate{},
+			},
+			exp: []bool{true, false, true, true, true},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			act := make([]bool, len(events))
+			for i, event := range events {
+				act[i] = tc.predicate.Allow(event)
+			}
+			tu.AssertEqual(t, tc.exp, act)
+		})
+	}
+}
+
+// StatusReconciler writes the number of reconciles to the status of the
+// object it reconciles.
+type StatusReconciler struct {
+	Client     hz.ObjectClient[ConditionObject]
+	reconciles atomic.Int32
+}
+
+func (r *StatusReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	n := r.reconciles.Add(1)
+	_, err := r.Client.Apply(ctx, ConditionObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: request.Key.ObjectNamespace(),
+			Name:      request.Key.ObjectName(),
+		},
+		Status: &ConditionObjectStatus{
+			Conditions: []hz.Condition{
+				{
+					Type:    "Ready",
+					Status:  hz.ConditionTrue,
+					Message: fmt.Sprintf("reconciled %d times", n),
+				},
+			},
+		},
+	}, hz.WithApplyForce(true))
+	return hz.Result{}, err
+}
+
+func TestReconcilerPredicates(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	condClient := hz.ObjectClient[ConditionObject]{Client: client}
+	sr := StatusReconciler{
+		Client: hz.ObjectClient[ConditionObject]{
+			Client: hz.NewClient(
+				ti.Conn,
+				hz.WithClientInternal(true),
+				hz.WithClientManager("reconciler"),
+			),
+		},
+	}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&sr),
+		hz.WithControllerFor(ConditionObject{}),
+		hz.WithControllerPredicates(hz.IgnoreStatusOnlyPredicate{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	obj := ConditionObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "predicates",
+		},
+	}
+	_, err = condClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+
+	// Writing the status does not reconcile the object again.
+	waitFor(t, func() bool {
+		return sr.reconciles.Load() == 1
+	})
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, int32(1), sr.reconciles.Load())
+
+	// Other changes are reconciled.
+	obj.Labels = map[string]string{"app": "predicates"}
+	_, err = condClient.Apply(ctx, obj, hz.WithApplyForce(true))
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		return sr.reconciles.Load() == 2
+	})
+	time.Sleep(time.Second)
+	tu.AssertEqual(t, int32(2), sr.reconciles.Load())
+}
@@ -0,0 +1,135 @@
+package hz
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter decides how long a controller waits before it reconciles an
+// object again, after the reconciler returned an error or asked to requeue.
+type RateLimiter interface {
+	// When returns the delay before the request is reconciled again.
+	// Attempt is the number of times the current revision of the object has
+	// been reconciled, starting at 1.
+	When(req Request, attempt int) time.Duration
+}
+
+var (
+	_ RateLimiter = (*ExponentialRateLimiter)(nil)
+	_ RateLimiter = (*FixedIntervalRateLimiter)(nil)
+	_ RateLimiter = (*JitterRateLimiter)(nil)
+	_ RateLimiter = (*TokenBucketRateLimiter)(nil)
+	_ RateLimiter = (MaxOfRateLimiter)(nil)
+)
+
+// DefaultRateLimiter returns the rate limiter that controllers use by
+// default: an exponential backoff starting at two seconds, capped at a day.
+func DefaultRateLimiter() RateLimiter {
+	return &ExponentialRateLimiter{
+		Base: time.Second,
+		Max:  time.Hour * 24,
+	}
+}
+
+// ExponentialRateLimiter waits Base * 2^attempt, up to Max.
+type ExponentialRateLimiter struct {
+	Base time.Duration
+	// Max is the maximum delay. Zero means no maximum.
+	Max time.Duration
+}
+
+func (r *ExponentialRateLimiter) When(req Request, attempt int) time.Duration {
+	delay := float64(r.Base) * math.Pow(2, float64(attempt))
+	if r.Max > 0 && delay > float64(r.Max) {
+		return r.Max
+	}
+	// Avoid overflowing time.Duration.
+	if delay > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+// FixedIntervalRateLimiter always waits the same interval.
+type FixedIntervalRateLimiter struct {
+	Interval time.Duration
+}
+
+func (r *FixedIntervalRateLimiter) When(req Request, attempt int) time.Duration {
+	return r.Interval
+}
+
+// JitterRateLimiter adds a random jitter of up to Factor times the delay of
+// RateLimiter, so that objects that fail together are not retried together.
+type JitterRateLimiter struct {
+	RateLimiter RateLimiter
+	// Factor is the maximum jitter, relative to the delay.
+	// E.g. 0.1 adds up to 10% to the delay.
+	Factor float64
+}
+
+func (r *JitterRateLimiter) When(req Request, attempt int) time.Duration {
+	delay := r.RateLimiter.When(req, attempt)
+	if r.Factor <= 0 {
+		return delay
+	}
+	return delay + time.Duration(rand.Float64()*r.Factor*float64(delay))
+}
+
+// TokenBucketRateLimiter limits the rate of reconciles for each kind with a
+// token bucket, e.g. to stay within the rate limits of an external API.
+//
+// Every requeue takes a token from the bucket of the object's kind, and the
+// delay is the time until the token is available.
+// Use it with [MaxOfRateLimiter] to combine it with a backoff.
+type TokenBucketRateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewTokenBucketRateLimiter returns a rate limiter that allows a rate of
+// limit requeues per second for each kind, with bursts of up to burst
+// requeues.
+func NewTokenBucketRateLimiter(
+	limit rate.Limit,
+	burst int,
+) *TokenBucketRateLimiter {
+	return &TokenBucketRateLimiter{
+		limit:    limit,
+		burst:    burst,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+func (r *TokenBucketRateLimiter) When(req Request, attempt int) time.Duration {
+	kind := req.Key.ObjectKind()
+	r.mu.Lock()
+	limiter, ok := r.limiters[kind]
+	if !ok {
+		limiter = rate.NewLimiter(r.limit, r.burst)
+		r.limiters[kind] = limiter
+	}
+	r.mu.Unlock()
+	return limiter.Reserve().Delay()
+}
+
+// MaxOfRateLimiter returns the longest delay of its rate limiters.
+// All the rate limiters are called, so that each can record the requeue.
+type MaxOfRateLimiter []RateLimiter
+
+func (r MaxOfRateLimiter) When(req Request, attempt int) time.Duration {
+	var delay time.Duration
+	for _, limiter := range r {
+		if d := limiter.When(req, attempt); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
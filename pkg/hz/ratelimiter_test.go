@@ -0,0 +1,145 @@
+package hz_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiters(t *testing.T) {
+	req := hz.Request{Key: hz.ObjectKey{Kind: "DummyObject"}}
+	otherReq := hz.Request{Key: hz.ObjectKey{Kind: "ChildObject"}}
+
+	t.Run("exponential", func(t *testing.T) {
+		rl := hz.ExponentialRateLimiter{
+			Base: time.Second,
+			Max:  time.Minute,
+		}
+		tu.AssertEqual(t, time.Second*2, rl.When(req, 1))
+		tu.AssertEqual(t, time.Second*8, rl.When(req, 3))
+		tu.AssertEqual(t, time.Minute, rl.When(req, 6))
+		tu.AssertEqual(t, time.Minute, rl.When(req, 10000))
+	})
+	t.Run("fixed interval", func(t *testing.T) {
+		rl := hz.FixedIntervalRateLimiter{Interval: time.Second * 5}
+		tu.AssertEqual(t, time.Second*5, rl.When(req, 1))
+		tu.AssertEqual(t, time.Second*5, rl.When(req, 100))
+	})
+	t.Run("jitter", func(t *testing.T) {
+		rl := hz.JitterRateLimiter{
+			RateLimiter: &hz.FixedIntervalRateLimiter{Interval: time.Second},
+			Factor:      0.5,
+		}
+		for i := 0; i < 100; i++ {
+			delay := rl.When(req, 1)
+			tu.AssertTrue(
+				t,
+				delay >= time.Second && delay <= time.Second*3/2,
+				"delay out of range: "+delay.String(),
+			)
+		}
+	})
+	t.Run("token bucket", func(t *testing.T) {
+		rl := hz.NewTokenBucketRateLimiter(rate.Every(time.Minute), 2)
+		// The burst allows two requeues without a delay.
+		tu.AssertEqual(t, time.Duration(0), rl.When(req, 1))
+		tu.AssertEqual(t, time.Duration(0), rl.When(req, 1))
+		delay := rl.When(req, 1)
+		tu.AssertTrue(t, delay > time.Second*59, "expected delay: "+delay.String())
+		// Other kinds have their own bucket.
+		tu.AssertEqual(t, time.Duration(0), rl.When(otherReq, 1))
+	})
+	t.Run("max of", func(t *testing.T) {
+		rl := hz.MaxOfRateLimiter{
+			&hz.FixedIntervalRateLimiter{Interval: time.Second * 5},
+			&hz.ExponentialRateLimiter{Base: time.Second},
+		}
+		tu.AssertEqual(t, time.Second*5, rl.When(req, 1))
+		tu.AssertEqual(t, time.Second*16, rl.When(req, 4))
+	})
+}
+
+// recordingRateLimiter records the attempts it is called with.
+type recordingRateLimiter struct {
+	mu       sync.Mutex
+	attempts []int
+}
+
+func (r *recordingRateLimiter) When(req hz.Request, attempt int) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, attempt)
+	return time.Millisecond * 100
+}
+
+func (r *recordingRateLimiter) Attempts() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.attempts...)
+}
+
+// requeueReconciler returns an error on the first reconcile, and then asks to
+// be requeued.
+type requeueReconciler struct {
+	mu         sync.Mutex
+	reconciles int
+}
+
+func (r *requeueReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reconciles++
+	if r.reconciles == 1 {
+		return hz.Result{}, errors.New("failed")
+	}
+	return hz.Result{Requeue: true}, nil
+}
+
+func TestReconcilerRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	rl := recordingRateLimiter{}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&requeueReconciler{}),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerRateLimiter(&rl),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	})
+	tu.AssertNoError(t, err)
+
+	// Both the error and the requeues use the rate limiter, with the number
+	// of times the object has been delivered.
+	waitFor(t, func() bool {
+		return len(rl.Attempts()) >= 3
+	})
+	tu.AssertEqual(t, []int{1, 2, 3}, rl.Attempts()[:3])
+}
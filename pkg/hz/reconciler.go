@@ -36,3 +36,51 @@ func (r *Result) IsZero() bool {
 	}
 	return *r == Result{}
 }
+
+// TypedReconciler reconciles objects of type T, which are fetched from the
+// store before Reconcile is called.
+// Use it with [FetchingReconciler].
+type TypedReconciler[T Objecter] interface {
+	// Reconcile reconciles the latest revision of the object.
+	// Objects that are being deleted are also reconciled, so check the
+	// object's deletion timestamp if needed.
+	Reconcile(ctx context.Context, object T) (Result, error)
+}
+
+// TypedReconcilerFunc is a function that implements [TypedReconciler].
+type TypedReconcilerFunc[T Objecter] func(
+	ctx context.Context,
+	object T,
+) (Result, error)
+
+func (f TypedReconcilerFunc[T]) Reconcile(
+	ctx context.Context,
+	object T,
+) (Result, error) {
+	return f(ctx, object)
+}
+
+// FetchingReconciler is a [Reconciler] that fetches the object of a request
+// and passes it to a [TypedReconciler].
+// Objects that no longer exist are not reconciled.
+// Use [WithControllerTypedReconciler] to set it as a controller's reconciler:
+//
+//	hz.WithControllerTypedReconciler(
+//		hz.ObjectClient[MyObject]{Client: client},
+//		&MyReconciler{},
+//	)
+type FetchingReconciler[T Objecter] struct {
+	Client     ObjectClient[T]
+	Reconciler TypedReconciler[T]
+}
+
+func (r *FetchingReconciler[T]) Reconcile(
+	ctx context.Context,
+	req Request,
+) (Result, error) {
+	object, err := r.Client.Get(ctx, WithGetKey(req.Key))
+	if err != nil {
+		return Result{}, IgnoreNotFound(err)
+	}
+	return r.Reconciler.Reconcile(ctx, object)
+}
@@ -0,0 +1,254 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ControllerStatus is the status of a controller instance, which the
+// controller writes to the controller registry while it is running.
+type ControllerStatus struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	// Version is the storage version of the kind.
+	Version string `json:"version"`
+	// InstanceID uniquely identifies the controller instance.
+	InstanceID string `json:"instanceID"`
+	Hostname   string `json:"hostname,omitempty"`
+	// Reconciler is true if the controller has a reconciler.
+	Reconciler bool `json:"reconciler"`
+	// Leader is true if the controller runs its reconciler, i.e. it is the
+	// leader or does not use leader election.
+	Leader bool `json:"leader"`
+	// Shard identifies the instances of a sharded controller, which share
+	// the objects of the kind between them.
+	// It is empty if the controller is not sharded.
+	Shard string `json:"shard,omitempty"`
+	// StartTime is when the controller started.
+	StartTime time.Time `json:"startTime"`
+	// HeartbeatTime is when the controller last wrote its status.
+	HeartbeatTime time.Time `json:"heartbeatTime"`
+	// Stats are the reconcile stats since the controller started.
+	Stats ReconcileStats `json:"stats"`
+	// LastError is the last reconcile error.
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorTime *time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// ReconcileStats are the reconcile stats of a controller instance.
+type ReconcileStats struct {
+	Reconciles uint64 `json:"reconciles"`
+	Errors     uint64 `json:"errors"`
+	Requeues   uint64 `json:"requeues"`
+	// Timeouts is the number of reconciles that exceeded the reconcile
+	// timeout, which are also counted as errors.
+	Timeouts uint64 `json:"timeouts"`
+	Active   int64  `json:"active"`
+}
+
+// ControllerStatusKey returns the key of the controller instance in the
+// controller registry.
+func ControllerStatusKey(status ControllerStatus) string {
+	return status.Group + "." + status.Kind + "." + status.InstanceID
+}
+
+// ListControllers returns the status of the controller instances that are
+// running, sorted by group, kind and instance ID.
+//
+// A controller instance is removed from the registry when it stops, or when
+// it has not written its status for the TTL of the registry bucket.
+func ListControllers(
+	ctx context.Context,
+	conn *nats.Conn,
+) ([]ControllerStatus, error) {
+	js, err := jetstream.New(conn)
+	if err != nil {
+		return nil, fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := js.KeyValue(ctx, BucketControllers)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"get controllers bucket %q: %w",
+			BucketControllers,
+			err,
+		)
+	}
+	keys, err := kv.Keys(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return []ControllerStatus{}, nil
+		}
+		return nil, fmt.Errorf("listing controllers: %w", err)
+	}
+	statuses := make([]ControllerStatus, 0, len(keys))
+	for _, key := range keys {
+		kve, err := kv.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, jetstream.ErrKeyNotFound) {
+				// The controller stopped since listing the keys.
+				continue
+			}
+			return nil, fmt.Errorf("getting controller %q: %w", key, err)
+		}
+		var status ControllerStatus
+		if err := json.Unmarshal(kve.Value(), &status); err != nil {
+			return nil, fmt.Errorf(
+				"unmarshalling controller %q: %w",
+				key,
+				err,
+			)
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return ControllerStatusKey(statuses[i]) <
+			ControllerStatusKey(statuses[j])
+	})
+	return statuses, nil
+}
+
+// controllerStats records the reconcile stats of a controller.
+type controllerStats struct {
+	reconciles atomic.Uint64
+	errors     atomic.Uint64
+	requeues   atomic.Uint64
+	timeouts   atomic.Uint64
+	active     atomic.Int64
+
+	mu            sync.Mutex
+	lastError     string
+	lastErrorTime *time.Time
+}
+
+func (s *controllerStats) recordError(err error) {
+	s.errors.Add(1)
+	now := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err.Error()
+	s.lastErrorTime = &now
+}
+
+// status writes the stats to the controller status.
+func (s *controllerStats) status(status *ControllerStatus) {
+	status.Stats = ReconcileStats{
+		Reconciles: s.reconciles.Load(),
+		Errors:     s.errors.Load(),
+		Requeues:   s.requeues.Load(),
+		Timeouts:   s.timeouts.Load(),
+		Active:     s.active.Load(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.LastError = s.lastError
+	status.LastErrorTime = s.lastErrorTime
+}
+
+// startHeartbeat writes the status of the controller to the controller
+// registry at a third of the TTL of the registry bucket, until the controller
+// is stopped.
+//
+// The registry is informational, so the controller runs without it if the
+// bucket does not exist.
+func (c *Controller) startHeartbeat(
+	ctx context.Context,
+	opt controllerOption,
+) error {
+	js, err := jetstream.New(c.Conn)
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := js.KeyValue(ctx, BucketControllers)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrBucketNotFound) {
+			slog.Warn(
+				"controller registry not found: not registering controller",
+				"bucket", BucketControllers,
+			)
+			return nil
+		}
+		return fmt.Errorf(
+			"get controllers bucket %q: %w",
+			BucketControllers,
+			err,
+		)
+	}
+	kvStatus, err := kv.Status(ctx)
+	if err != nil {
+		return fmt.Errorf(
+			"get controllers bucket %q status: %w",
+			BucketControllers,
+			err,
+		)
+	}
+	interval := time.Second * 10
+	if ttl := kvStatus.TTL(); ttl > 0 {
+		interval = ttl / 3
+	}
+	hostname, _ := os.Hostname()
+	status := ControllerStatus{
+		Group:      opt.forObject.ObjectGroup(),
+		Kind:       opt.forObject.ObjectKind(),
+		Version:    opt.forObject.ObjectVersion(),
+		InstanceID: c.instanceID,
+		Hostname:   hostname,
+		Reconciler: opt.reconciler != nil,
+		StartTime:  time.Now().UTC(),
+	}
+	if opt.sharding && opt.reconciler != nil {
+		status.Shard = opt.consumerPrefix()
+	}
+	key := ControllerStatusKey(status)
+	heartbeat := func() error {
+		status.HeartbeatTime = time.Now().UTC()
+		status.Leader = status.Reconciler && c.IsLeader()
+		c.stats.status(&status)
+		data, err := json.Marshal(status)
+		if err != nil {
+			return fmt.Errorf("marshalling controller status: %w", err)
+		}
+		if _, err := kv.Put(ctx, key, data); err != nil {
+			return fmt.Errorf("writing controller status: %w", err)
+		}
+		return nil
+	}
+	if err := heartbeat(); err != nil {
+		return err
+	}
+
+	c.heartbeatDone = make(chan struct{})
+	go func() {
+		defer close(c.heartbeatDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.stopped:
+				// Remove the controller from the registry, so that it does
+				// not show as running until the TTL expires.
+				if err := kv.Delete(context.Background(), key); err != nil {
+					slog.Error("removing controller status", "error", err)
+				}
+				return
+			case <-ticker.C:
+				if err := heartbeat(); err != nil {
+					slog.Error("controller heartbeat", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,90 @@
+package hz_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	"github.com/verifa/horizon/pkg/store"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+// ErrorReconciler always fails.
+type ErrorReconciler struct{}
+
+func (r *ErrorReconciler) Reconcile(
+	ctx context.Context,
+	request hz.Request,
+) (hz.Result, error) {
+	return hz.Result{}, errors.New("always fails")
+}
+
+func TestControllerRegistry(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(
+		t,
+		ctx,
+		server.WithStoreOptions(store.WithControllerTTL(time.Second*3)),
+	)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&ErrorReconciler{}),
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerRateLimiter(&hz.FixedIntervalRateLimiter{
+			Interval: time.Hour,
+		}),
+	)
+	tu.AssertNoError(t, err)
+
+	dummyStatus := func() (hz.ControllerStatus, bool) {
+		statuses, err := hz.ListControllers(ctx, ti.Conn)
+		tu.AssertNoError(t, err)
+		for _, status := range statuses {
+			if status.Kind == "DummyObject" {
+				return status, true
+			}
+		}
+		return hz.ControllerStatus{}, false
+	}
+	status, ok := dummyStatus()
+	tu.AssertTrue(t, ok, "expected controller to be registered")
+	tu.AssertEqual(t, "v1", status.Version)
+	tu.AssertTrue(t, status.InstanceID != "", "expected instance ID")
+	tu.AssertTrue(t, status.Reconciler, "expected reconciler")
+	tu.AssertTrue(t, status.Leader, "expected leader")
+
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+		},
+	})
+	tu.AssertNoError(t, err)
+
+	// The stats are written with the next heartbeat.
+	waitFor(t, func() bool {
+		status, _ := dummyStatus()
+		return status.Stats.Errors > 0
+	})
+	status, _ = dummyStatus()
+	tu.AssertEqual(t, uint64(1), status.Stats.Reconciles)
+	tu.AssertEqual(t, "always fails", status.LastError)
+	tu.AssertTrue(t, status.LastErrorTime != nil, "expected last error time")
+
+	// Stopping the controller removes it from the registry.
+	err = ctlr.Stop()
+	tu.AssertNoError(t, err)
+	_, ok = dummyStatus()
+	tu.AssertTrue(t, !ok, "expected controller to be removed")
+}
@@ -0,0 +1,177 @@
+package hz
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// requeueFunc reconciles the object with the key again after the delay,
+// without a message from the consumer.
+type requeueFunc func(key string, delay time.Duration)
+
+// requeuer returns the function that requeues objects in the running
+// reconciler.
+//
+// The consumer has no message to deliver for an object that was already
+// acked or terminated (e.g. a dead-lettered object), so the object is
+// reconciled with a [requeueMsg] for its latest revision instead.
+func (c *Controller) requeuer(
+	ctx context.Context,
+	reconciler Reconciler,
+	kv jetstream.KeyValue,
+	mutex mutex,
+	ttl time.Duration,
+) requeueFunc {
+	// stopped returns true once the reconciler stopped.
+	stopped := func() bool {
+		select {
+		case <-ctx.Done():
+			return true
+		case <-c.stopped:
+			return true
+		default:
+			return false
+		}
+	}
+	var requeue requeueFunc
+	requeue = func(key string, delay time.Duration) {
+		requeueAfter(delay, func() {
+			if stopped() {
+				return
+			}
+			kve, err := kv.Get(ctx, key)
+			if err != nil {
+				if errors.Is(err, jetstream.ErrKeyNotFound) {
+					// The object was purged, so there is nothing to
+					// reconcile.
+					return
+				}
+				slog.Error("requeue: getting object", "key", key, "error", err)
+				requeue(key, time.Second)
+				return
+			}
+			// Deliveries of an earlier message of the object are not
+			// retries of this one.
+			c.forgetResyncs(ctx, key)
+			var reconcile func(delivered uint64)
+			reconcile = func(delivered uint64) {
+				msg := &requeueMsg{
+					subject:   "$KV." + kv.Bucket() + "." + key,
+					entry:     kve,
+					delivered: delivered,
+					redeliver: func(delay time.Duration) {
+						requeueAfter(delay, func() {
+							if stopped() {
+								return
+							}
+							reconcile(delivered + 1)
+						})
+					},
+				}
+				c.startControlLoop(ctx, reconciler, kv, mutex, key, msg, ttl)
+			}
+			reconcile(1)
+		})
+	}
+	return requeue
+}
+
+// requeueAfter calls fn after the delay, or right away if there is no delay.
+func requeueAfter(delay time.Duration, fn func()) {
+	if delay <= 0 {
+		go fn()
+		return
+	}
+	time.AfterFunc(delay, fn)
+}
+
+// setRequeue sets the function that requeues objects in the running
+// reconciler, or nil when the reconciler stops.
+func (c *Controller) setRequeue(requeue requeueFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requeue = requeue
+}
+
+// getRequeue returns the function that requeues objects in the running
+// reconciler, or nil if the reconciler is not running in this instance.
+func (c *Controller) getRequeue() requeueFunc {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requeue
+}
+
+var _ jetstream.Msg = (*requeueMsg)(nil)
+
+// requeueMsg is the message of a requeued object, for the latest revision of
+// the object.
+//
+// It is not delivered by a consumer, so acking it does nothing, and
+// redelivering it reconciles the object again in the same instance.
+type requeueMsg struct {
+	subject   string
+	entry     jetstream.KeyValueEntry
+	delivered uint64
+	redeliver func(delay time.Duration)
+}
+
+func (m *requeueMsg) Metadata() (*jetstream.MsgMetadata, error) {
+	return &jetstream.MsgMetadata{
+		Sequence: jetstream.SequencePair{
+			Stream: m.entry.Revision(),
+		},
+		NumDelivered: m.delivered,
+		Timestamp:    m.entry.Created(),
+	}, nil
+}
+
+func (m *requeueMsg) Data() []byte {
+	return m.entry.Value()
+}
+
+func (m *requeueMsg) Headers() nats.Header {
+	return nats.Header{}
+}
+
+func (m *requeueMsg) Subject() string {
+	return m.subject
+}
+
+func (m *requeueMsg) Reply() string {
+	return ""
+}
+
+func (m *requeueMsg) Ack() error {
+	return nil
+}
+
+func (m *requeueMsg) DoubleAck(context.Context) error {
+	return nil
+}
+
+func (m *requeueMsg) Nak() error {
+	m.redeliver(0)
+	return nil
+}
+
+func (m *requeueMsg) NakWithDelay(delay time.Duration) error {
+	m.redeliver(delay)
+	return nil
+}
+
+func (m *requeueMsg) InProgress() error {
+	return nil
+}
+
+func (m *requeueMsg) Term() error {
+	return nil
+}
+
+func (m *requeueMsg) TermWithReason(string) error {
+	return nil
+}
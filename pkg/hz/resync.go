@@ -0,0 +1,106 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// resyncDeliveries records how many deliveries of the last message of an
+// object were resyncs, in the resyncs bucket.
+//
+// A resync redelivers the message of the object (using NakWithDelay), which
+// increases the number of deliveries of the message.
+// Only the deliveries after the last resync are retries.
+type resyncDeliveries struct {
+	// Revision is the revision of the object, i.e. the stream sequence of
+	// the message.
+	Revision uint64 `json:"revision"`
+	// Delivered is the number of deliveries of the message when it was
+	// last resynced.
+	Delivered uint64 `json:"delivered"`
+}
+
+// resyncMsg redelivers the message after the resync period, so that the
+// object is reconciled again.
+//
+// The message stays with the consumer, so only the instance that reconciled
+// it resyncs the object, and the resync survives restarts.
+func (c *Controller) resyncMsg(
+	ctx context.Context,
+	req Request,
+	key string,
+	msg jetstream.Msg,
+) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		slog.Error("resync: getting message metadata", "error", err)
+		_ = msg.Ack()
+		return
+	}
+	data, err := json.Marshal(resyncDeliveries{
+		Revision:  meta.Sequence.Stream,
+		Delivered: meta.NumDelivered,
+	})
+	if err != nil {
+		slog.Error("resync: marshalling deliveries", "error", err)
+		_ = msg.Ack()
+		return
+	}
+	if _, err := c.resyncs.Put(ctx, key, data); err != nil {
+		// Resync anyway, as missing drift is worse than retrying an object
+		// fewer times than the max retries.
+		slog.Error("resync: recording deliveries", "key", key, "error", err)
+	}
+	if err := msg.NakWithDelay(c.resync.When(req, 1)); err != nil {
+		slog.Error("resync: nak with delay", "error", err)
+	}
+}
+
+// resyncedDeliveries returns the number of deliveries of the message that
+// were resyncs.
+func (c *Controller) resyncedDeliveries(
+	ctx context.Context,
+	key string,
+	meta *jetstream.MsgMetadata,
+) (uint64, error) {
+	if c.resyncs == nil {
+		return 0, nil
+	}
+	kve, err := c.resyncs.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("getting resync deliveries: %w", err)
+	}
+	var deliveries resyncDeliveries
+	if err := json.Unmarshal(kve.Value(), &deliveries); err != nil {
+		return 0, fmt.Errorf("unmarshalling resync deliveries: %w", err)
+	}
+	// The deliveries of an older revision are for another message.
+	if deliveries.Revision != meta.Sequence.Stream ||
+		deliveries.Delivered > meta.NumDelivered {
+		return 0, nil
+	}
+	return deliveries.Delivered, nil
+}
+
+// forgetResyncs removes the resync deliveries of an object that no longer
+// exists.
+func (c *Controller) forgetResyncs(ctx context.Context, key string) {
+	if c.resyncs == nil {
+		return
+	}
+	// Avoid writing a delete marker for objects that were never resynced.
+	if _, err := c.resyncs.Get(ctx, key); err != nil {
+		return
+	}
+	if err := c.resyncs.Purge(ctx, key); err != nil {
+		slog.Error("purging resync deliveries", "key", key, "error", err)
+	}
+}
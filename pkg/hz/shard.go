@@ -0,0 +1,303 @@
+package hz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// shardVirtualNodes is the number of points that each instance has on the
+// hash ring, which spreads the keys evenly across the instances.
+const shardVirtualNodes = 100
+
+// shardConsumerInactiveThreshold is how long the consumers of a sharded
+// instance live after the instance stops consuming, e.g. if it crashed.
+const shardConsumerInactiveThreshold = time.Minute
+
+// hashRing assigns keys to members using consistent hashing, so that only
+// the keys of a member move when it joins or leaves.
+type hashRing struct {
+	points []uint32
+	owners map[uint32]string
+}
+
+func newHashRing(members []string) *hashRing {
+	r := &hashRing{
+		points: make([]uint32, 0, len(members)*shardVirtualNodes),
+		owners: make(map[uint32]string, len(members)*shardVirtualNodes),
+	}
+	for _, member := range members {
+		for i := 0; i < shardVirtualNodes; i++ {
+			point := ringHash(member + "#" + strconv.Itoa(i))
+			if owner, ok := r.owners[point]; ok {
+				// Resolve collisions the same way in every instance.
+				if member < owner {
+					r.owners[point] = member
+				}
+				continue
+			}
+			r.points = append(r.points, point)
+			r.owners[point] = member
+		}
+	}
+	slices.Sort(r.points)
+	return r
+}
+
+// owner returns the member that owns the key, or an empty string if the ring
+// has no members.
+func (r *hashRing) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	hash := ringHash(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i] >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func ringHash(s string) uint32 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// shards keeps track of the instances of a sharded controller, and which
+// keys the instance owns.
+type shards struct {
+	instanceID string
+
+	mu      sync.RWMutex
+	members []string
+	ring    *hashRing
+}
+
+// owns returns true if the instance owns the key.
+func (s *shards) owns(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ring.owner(key) == s.instanceID
+}
+
+// setMembers sets the instances of the controller.
+// If they changed, it returns the previous hash ring and true.
+func (s *shards) setMembers(members []string) (*hashRing, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ring != nil && slices.Equal(s.members, members) {
+		return nil, false
+	}
+	old := s.ring
+	s.members = members
+	s.ring = newHashRing(members)
+	return old, true
+}
+
+// gained returns true if the instance owns the key, and did not own it in
+// the old hash ring.
+func (s *shards) gained(old *hashRing, key string) bool {
+	return s.owns(key) && old.owner(key) != s.instanceID
+}
+
+// startSharding starts the reconciler of a sharded controller instance, and
+// rebalances the keys when instances join or leave.
+//
+// The instances of the controller are the instances in the controller
+// registry with the same shard, which is checked at the interval of the
+// heartbeat.
+// Each instance has its own consumers, which receive all the messages for the
+// controller and acknowledge the messages for keys the instance does not own.
+// When the instances change, the consumers are kept and the instance requeues
+// only the keys that it gained, as their messages were acknowledged.
+func (c *Controller) startSharding(
+	ctx context.Context,
+	ro controllerOption,
+) error {
+	js, err := jetstream.New(c.Conn)
+	if err != nil {
+		return fmt.Errorf("jetstream: %w", err)
+	}
+	kv, err := js.KeyValue(ctx, BucketControllers)
+	if err != nil {
+		return fmt.Errorf(
+			"get controllers bucket %q: %w",
+			BucketControllers,
+			err,
+		)
+	}
+	kvStatus, err := kv.Status(ctx)
+	if err != nil {
+		return fmt.Errorf(
+			"get controllers bucket %q status: %w",
+			BucketControllers,
+			err,
+		)
+	}
+	interval := time.Second * 10
+	if ttl := kvStatus.TTL(); ttl > 0 {
+		interval = ttl / 3
+	}
+	c.shards = &shards{instanceID: c.instanceID}
+	members, err := c.shardMembers(ctx, ro)
+	if err != nil {
+		return err
+	}
+	c.shards.setMembers(members)
+	ro.shardSuffix = shardSuffix(c.instanceID)
+	if err := c.startReconciler(ctx, ro); err != nil {
+		return fmt.Errorf("start reconciler: %w", err)
+	}
+	objects, err := js.KeyValue(ctx, ro.bucketObjects)
+	if err != nil {
+		return fmt.Errorf(
+			"get objects bucket %q: %w",
+			ro.bucketObjects,
+			err,
+		)
+	}
+
+	c.shardingDone = make(chan struct{})
+	go func() {
+		defer close(c.shardingDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.stopped:
+				return
+			case <-ticker.C:
+			}
+			members, err := c.shardMembers(ctx, ro)
+			if err != nil {
+				slog.Error("listing controller shards", "error", err)
+				continue
+			}
+			old, ok := c.shards.setMembers(members)
+			if !ok {
+				continue
+			}
+			slog.Info(
+				"rebalancing controller shards",
+				"kind", ro.forObject.ObjectKind(),
+				"instances", len(members),
+			)
+			if err := c.requeueGained(ctx, ro, objects, old); err != nil {
+				slog.Error(
+					"requeueing gained keys",
+					"kind", ro.forObject.ObjectKind(),
+					"error", err,
+				)
+			}
+		}
+	}()
+	return nil
+}
+
+// requeueGained reconciles the objects whose keys the instance gained from
+// the old hash ring.
+// The messages for these keys were acknowledged by the instance when another
+// instance owned them, so the consumers do not deliver them again.
+func (c *Controller) requeueGained(
+	ctx context.Context,
+	ro controllerOption,
+	kv jetstream.KeyValue,
+	old *hashRing,
+) error {
+	requeue := c.getRequeue()
+	if requeue == nil {
+		return nil
+	}
+	keys, err := objectKeys(ctx, kv, ro)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if c.shards.gained(old, key) {
+			requeue(key, 0)
+		}
+	}
+	return nil
+}
+
+// objectKeys returns the keys of the objects of the controller's kind.
+func objectKeys(
+	ctx context.Context,
+	kv jetstream.KeyValue,
+	ro controllerOption,
+) ([]string, error) {
+	prefix := "$KV." + kv.Bucket() + "."
+	var keys []string
+	for _, subject := range ro.forSubjects(kv.Bucket()) {
+		watcher, err := kv.Watch(
+			ctx,
+			strings.TrimPrefix(subject, prefix),
+			jetstream.IgnoreDeletes(),
+			jetstream.MetaOnly(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("watching objects: %w", err)
+		}
+		for kve := range watcher.Updates() {
+			// A nil entry marks the end of the existing objects.
+			if kve == nil {
+				break
+			}
+			keys = append(keys, kve.Key())
+		}
+		if err := watcher.Stop(); err != nil {
+			return nil, fmt.Errorf("stopping watcher: %w", err)
+		}
+	}
+	return keys, nil
+}
+
+// shardMembers returns the sorted instance IDs of the controller's shard in
+// the controller registry.
+// The instance is always a member, even if its status has expired.
+func (c *Controller) shardMembers(
+	ctx context.Context,
+	ro controllerOption,
+) ([]string, error) {
+	statuses, err := ListControllers(ctx, c.Conn)
+	if err != nil {
+		return nil, err
+	}
+	members := []string{c.instanceID}
+	for _, status := range statuses {
+		if status.Group != ro.forObject.ObjectGroup() ||
+			status.Kind != ro.forObject.ObjectKind() ||
+			status.Shard != ro.consumerPrefix() {
+			continue
+		}
+		if !slices.Contains(members, status.InstanceID) {
+			members = append(members, status.InstanceID)
+		}
+	}
+	slices.Sort(members)
+	return members, nil
+}
+
+// shardSuffix returns the suffix of the consumer names of a sharded
+// instance.
+func shardSuffix(instanceID string) string {
+	id := instanceID
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return "_shard_" + id
+}
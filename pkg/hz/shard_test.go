@@ -0,0 +1,55 @@
+package hz
+
+import (
+	"strconv"
+	"testing"
+
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+func TestHashRing(t *testing.T) {
+	keys := make([]string, 3000)
+	for i := range keys {
+		keys[i] = "group.v1.Kind.test.object-" + strconv.Itoa(i)
+	}
+	owners := func(members ...string) map[string]string {
+		ring := newHashRing(members)
+		owners := make(map[string]string, len(keys))
+		for _, key := range keys {
+			owners[key] = ring.owner(key)
+		}
+		return owners
+	}
+
+	tu.AssertEqual(t, "", newHashRing(nil).owner(keys[0]))
+
+	// Keys are spread across the members.
+	three := owners("a", "b", "c")
+	counts := make(map[string]int)
+	for _, owner := range three {
+		counts[owner]++
+	}
+	for _, member := range []string{"a", "b", "c"} {
+		tu.AssertTrue(
+			t,
+			counts[member] > len(keys)/5,
+			"too few keys for member "+member,
+		)
+	}
+
+	// When a member leaves, only its keys move.
+	two := owners("a", "c")
+	for _, key := range keys {
+		if three[key] != "b" {
+			tu.AssertEqual(t, three[key], two[key])
+		}
+	}
+
+	// When a member joins, keys only move to it.
+	four := owners("a", "b", "c", "d")
+	for _, key := range keys {
+		if four[key] != "d" {
+			tu.AssertEqual(t, three[key], four[key])
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package hz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/verifa/horizon"
+
+// propagator propagates the W3C trace context, regardless of the global
+// propagator, so that traces continue across NATS hops by default.
+var propagator = propagation.TraceContext{}
+
+// Tracer returns the tracer used by horizon to create spans.
+// It uses the global tracer provider, which is a no-op unless one has been
+// set with [otel.SetTracerProvider].
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// TraceInject adds the trace context from ctx to the NATS message header, so
+// that the receiver of the message can continue the trace.
+func TraceInject(ctx context.Context, header nats.Header) {
+	propagator.Inject(ctx, natsHeaderCarrier(header))
+}
+
+// TraceExtract returns a copy of ctx with the trace context from the NATS
+// message header, if there is one.
+func TraceExtract(ctx context.Context, header nats.Header) context.Context {
+	return propagator.Extract(ctx, natsHeaderCarrier(header))
+}
+
+// TraceInjectHTTP adds the trace context from ctx to the HTTP header.
+// It is used for HTTP requests that are transported over NATS, such as
+// requests to portals.
+func TraceInjectHTTP(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceExtractHTTP returns a copy of ctx with the trace context from the HTTP
+// header, if there is one.
+func TraceExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceAttributes returns the span attributes describing the object key.
+func TraceAttributes(key ObjectKeyer) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("hz.group", key.ObjectGroup()),
+		attribute.String("hz.version", key.ObjectVersion()),
+		attribute.String("hz.kind", key.ObjectKind()),
+		attribute.String("hz.namespace", key.ObjectNamespace()),
+		attribute.String("hz.name", key.ObjectName()),
+	}
+}
+
+// TraceError records err on the span and marks the span as failed.
+// If err is nil, it does nothing.
+func TraceError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	var hErr *Error
+	if errors.As(err, &hErr) {
+		span.SetAttributes(attribute.Int("hz.status", hErr.Status))
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+var _ propagation.TextMapCarrier = (*natsHeaderCarrier)(nil)
+
+// natsHeaderCarrier adapts NATS message headers to a carrier for propagating
+// the trace context.
+type natsHeaderCarrier nats.Header
+
+func (c natsHeaderCarrier) Get(key string) string {
+	return nats.Header(c).Get(key)
+}
+
+func (c natsHeaderCarrier) Set(key string, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+func (c natsHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,133 @@
+package hz_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpCollector is a stand-in for an OTLP collector, which records the spans
+// exported to it over OTLP/HTTP.
+type otlpCollector struct {
+	mu    sync.Mutex
+	spans map[string][]string
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				traceID := string(span.GetTraceId())
+				c.spans[traceID] = append(c.spans[traceID], span.GetName())
+			}
+		}
+	}
+	resp, _ := proto.Marshal(&coltracepb.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+// traceSpans returns the names of the spans recorded for the trace.
+func (c *otlpCollector) traceSpans(traceID string) map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := map[string]bool{}
+	for _, name := range c.spans[traceID] {
+		names[name] = true
+	}
+	return names
+}
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+	collector := &otlpCollector{spans: map[string][]string{}}
+	collectorServer := httptest.NewServer(collector)
+	t.Cleanup(collectorServer.Close)
+	collectorURL, err := url.Parse(collectorServer.URL)
+	tu.AssertNoError(t, err)
+	exporter, err := otlptracehttp.New(
+		ctx,
+		otlptracehttp.WithEndpoint(collectorURL.Host),
+		otlptracehttp.WithInsecure(),
+	)
+	tu.AssertNoError(t, err)
+
+	ti := server.Test(t, ctx, server.WithTraceExporter(exporter))
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerReconciler(&DummyReconciler{}),
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	defer ctlr.Stop()
+
+	ctx, span := hz.Tracer().Start(ctx, "test")
+	_, err = dummyClient.Apply(ctx, DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "traced",
+		},
+	})
+	tu.AssertNoError(t, err)
+	span.End()
+	traceID := span.SpanContext().TraceID()
+
+	// The reconcile happens asynchronously, so wait for all the spans of the
+	// trace to be exported.
+	want := []string{
+		"client.apply",
+		"store.apply",
+		"controller.validate_create",
+		"controller.reconcile",
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		err := ti.TracerProvider.ForceFlush(ctx)
+		tu.AssertNoError(t, err)
+		got := collector.traceSpans(string(traceID[:]))
+		missing := []string{}
+		for _, name := range want {
+			if !got[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("missing spans for trace %s: %v", traceID, missing)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
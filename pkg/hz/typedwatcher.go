@@ -0,0 +1,97 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TypedEvent is an [Event] with the object decoded.
+type TypedEvent[T Objecter] struct {
+	Operation EventOperation
+	Key       ObjectKeyer
+	// Object is the changed object.
+	// It is the zero value for purge events, as the object no longer exists.
+	Object T
+}
+
+// TypedWatcher is a [Watcher] for objects of type T, which passes the decoded
+// objects to its callback.
+type TypedWatcher[T Objecter] struct {
+	Conn *nats.Conn
+
+	watcher *Watcher
+}
+
+// StartTypedWatcher starts a watcher for objects of type T.
+// The watcher options must not set the object or callback.
+func StartTypedWatcher[T Objecter](
+	ctx context.Context,
+	conn *nats.Conn,
+	fn func(event TypedEvent[T]) (Result, error),
+	opts ...WatcherOption,
+) (*TypedWatcher[T], error) {
+	w := &TypedWatcher[T]{Conn: conn}
+	if err := w.Start(ctx, fn, opts...); err != nil {
+		return nil, fmt.Errorf("starting typed watcher: %w", err)
+	}
+	return w, nil
+}
+
+func (w *TypedWatcher[T]) Start(
+	ctx context.Context,
+	fn func(event TypedEvent[T]) (Result, error),
+	opts ...WatcherOption,
+) error {
+	if fn == nil {
+		return fmt.Errorf("fn (callback) is required")
+	}
+	var t T
+	handle := func(event Event) (Result, error) {
+		typedEvent := TypedEvent[T]{
+			Operation: event.Operation,
+			Key:       event.Key,
+		}
+		if event.Operation != EventOperationPurge {
+			if err := json.Unmarshal(event.Data, &typedEvent.Object); err != nil {
+				// The object will never decode, so skip it instead of
+				// retrying.
+				slog.Error(
+					"typed watcher: unmarshalling object",
+					"key", KeyFromObject(event.Key),
+					"error", err,
+				)
+				return Result{}, nil
+			}
+		}
+		return fn(typedEvent)
+	}
+	w.watcher = &Watcher{Conn: w.Conn}
+	// Clip the options, so that appending does not overwrite the caller's
+	// slice.
+	return w.watcher.Start(
+		ctx,
+		append(slices.Clip(opts), WithWatcherFor(t), WithWatcherFn(handle))...,
+	)
+}
+
+func (w *TypedWatcher[T]) Close() {
+	if w.watcher != nil {
+		w.watcher.Close()
+	}
+}
+
+// Init is closed once the watcher has received the objects that existed when
+// it was started, or when it is not the leader (see
+// [WithWatcherLeaderElection]).
+func (w *TypedWatcher[T]) Init() <-chan struct{} {
+	return w.watcher.Init
+}
+
+func (w *TypedWatcher[T]) WaitUntilInit() {
+	w.watcher.WaitUntilInit()
+}
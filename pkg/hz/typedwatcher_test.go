@@ -0,0 +1,139 @@
+package hz_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/server"
+	tu "github.com/verifa/horizon/pkg/testutil"
+)
+
+func TestTypedWatcher(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&DummyObject{}),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	var (
+		mu     sync.Mutex
+		events []hz.TypedEvent[DummyObject]
+	)
+	watcher, err := hz.StartTypedWatcher[DummyObject](
+		ctx,
+		ti.Conn,
+		func(event hz.TypedEvent[DummyObject]) (hz.Result, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+			return hz.Result{}, nil
+		},
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(watcher.Close)
+	watcher.WaitUntilInit()
+	lastEvent := func() (hz.TypedEvent[DummyObject], bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(events) == 0 {
+			return hz.TypedEvent[DummyObject]{}, false
+		}
+		return events[len(events)-1], true
+	}
+
+	obj := DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+			Labels:    map[string]string{"env": "dev"},
+		},
+	}
+	_, err = dummyClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		event, ok := lastEvent()
+		return ok && event.Operation == hz.EventOperationPut
+	})
+	event, _ := lastEvent()
+	tu.AssertEqual(t, "dummy", event.Object.Name)
+	tu.AssertEqual(t, "dev", event.Object.Labels["env"])
+	tu.AssertEqual(t, "dummy", event.Key.ObjectName())
+
+	// Once purged, the event has the key but not the object.
+	err = dummyClient.Delete(ctx, obj)
+	tu.AssertNoError(t, err)
+	waitFor(t, func() bool {
+		event, ok := lastEvent()
+		return ok && event.Operation == hz.EventOperationPurge
+	})
+	event, _ = lastEvent()
+	tu.AssertEqual(t, "dummy", event.Key.ObjectName())
+	tu.AssertEqual(t, "", event.Object.Name)
+}
+
+func TestFetchingReconciler(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	dummyClient := hz.ObjectClient[DummyObject]{Client: client}
+	reconciled := make(chan DummyObject, 10)
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(&DummyObject{}),
+		hz.WithControllerTypedReconciler(
+			dummyClient,
+			hz.TypedReconcilerFunc[DummyObject](
+				func(ctx context.Context, obj DummyObject) (hz.Result, error) {
+					reconciled <- obj
+					return hz.Result{}, nil
+				},
+			),
+		),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	obj := DummyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "dummy",
+			Labels:    map[string]string{"env": "dev"},
+		},
+	}
+	_, err = dummyClient.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+	var got DummyObject
+	waitFor(t, func() bool {
+		select {
+		case got = <-reconciled:
+			return true
+		default:
+			return false
+		}
+	})
+	tu.AssertEqual(t, "dummy", got.Name)
+	tu.AssertEqual(t, "dev", got.Labels["env"])
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/cuecontext"
@@ -16,6 +17,35 @@ type Validator interface {
 	ValidateDelete(ctx context.Context, data []byte) error
 }
 
+// ValidatorFailurePolicy defines what the store does when no validator for a
+// kind responds.
+type ValidatorFailurePolicy string
+
+const (
+	// ValidatorFailClosed rejects the request, as it cannot be validated.
+	ValidatorFailClosed ValidatorFailurePolicy = "closed"
+	// ValidatorFailOpen accepts the request without validating it.
+	ValidatorFailOpen ValidatorFailurePolicy = "open"
+)
+
+// ValidatorPolicy tells the store how to call the validators of a kind.
+// A controller registers the policy for its kind when it starts, and the
+// policy is persisted so that it also applies while the controller is down.
+type ValidatorPolicy struct {
+	// Timeout is how long the store waits for a validator to respond.
+	// If zero, the store uses a default timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// FailurePolicy defines what happens if no validator responds.
+	// The default is [ValidatorFailClosed].
+	FailurePolicy ValidatorFailurePolicy `json:"failurePolicy,omitempty"`
+}
+
+// ValidatorPolicyKey returns the key of the validator policy for the kind of
+// the given object key.
+func ValidatorPolicyKey(key ObjectKeyer) string {
+	return key.ObjectGroup() + "." + key.ObjectKind()
+}
+
 var _ Validator = (*ZeroValidator)(nil)
 
 type ZeroValidator struct{}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -55,6 +56,23 @@ func WithWatcherFromNow() WatcherOption {
 	}
 }
 
+// WithWatcherLeaderElection only runs the watcher in the instance that is the
+// leader of the election with the given name.
+//
+// The Init channel of a watcher that is not the leader after its first
+// attempt to acquire the lease is closed, as it does not receive the existing
+// objects, so that [Watcher.WaitUntilInit] does not block forever.
+//
+// Use the options to add callbacks for when leadership is gained or lost.
+func WithWatcherLeaderElection(
+	name string,
+	opts ...LeaderElectorOption,
+) WatcherOption {
+	return func(o *watcherOptions) {
+		o.leaderElection = &leaderElection{name: name, opts: opts}
+	}
+}
+
 type watcherOptions struct {
 	forObject ObjectKeyer
 	durable   string
@@ -63,6 +81,8 @@ type watcherOptions struct {
 	ch        chan Event
 	backoff   time.Duration
 	startTime *time.Time
+
+	leaderElection *leaderElection
 }
 
 var defaultWatcherOptions = watcherOptions{
@@ -73,9 +93,15 @@ var defaultWatcherOptions = watcherOptions{
 type Watcher struct {
 	Conn *nats.Conn
 
+	mu             sync.Mutex
 	consumeContext jetstream.ConsumeContext
-	isInit         bool
-	Init           chan struct{}
+	// elector is the leader elector, if the watcher uses leader election.
+	elector  *LeaderElector
+	initOnce sync.Once
+	// Init is closed once the watcher has received the objects that existed
+	// when it was started, or when it is not the leader (see
+	// [WithWatcherLeaderElection]).
+	Init chan struct{}
 }
 
 func StartWatcher(
@@ -91,12 +117,17 @@ func StartWatcher(
 }
 
 func (w *Watcher) Close() {
-	w.consumeContext.Stop()
+	if w.elector != nil {
+		if err := w.elector.Stop(); err != nil {
+			slog.Error("stopping leader election", "error", err)
+		}
+	}
+	w.stopConsume()
 }
 
 func (w *Watcher) Start(ctx context.Context, opts ...WatcherOption) error {
 	w.Init = make(chan struct{})
-	if w.consumeContext != nil {
+	if w.consumeContext != nil || w.elector != nil {
 		return fmt.Errorf("watcher already started")
 	}
 	opt := defaultWatcherOptions
@@ -138,156 +169,219 @@ func (w *Watcher) Start(ctx context.Context, opts ...WatcherOption) error {
 		if !errors.Is(err, jetstream.ErrMsgNotFound) {
 			return fmt.Errorf("get last msg for subject: %w", err)
 		}
-		w.isInit = true
-		close(w.Init)
+		w.markInit()
 	}
 
 	deliverPolicy := jetstream.DeliverLastPerSubjectPolicy
 	if opt.startTime != nil {
 		deliverPolicy = jetstream.DeliverByStartTimePolicy
 	}
-	con, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
-		Description:    "Watcher for " + KeyFromObject(opt.forObject),
-		AckPolicy:      jetstream.AckExplicitPolicy,
-		DeliverPolicy:  deliverPolicy,
-		OptStartTime:   opt.startTime,
-		FilterSubjects: []string{subject},
-		MaxAckPending:  -1,
+	// consume creates the consumer and consumes its messages.
+	// With leader election, the consumer is created each time the watcher
+	// becomes the leader, as an ephemeral consumer is removed when it is not
+	// consumed from.
+	consume := func() error {
+		con, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+			Description:    "Watcher for " + KeyFromObject(opt.forObject),
+			AckPolicy:      jetstream.AckExplicitPolicy,
+			DeliverPolicy:  deliverPolicy,
+			OptStartTime:   opt.startTime,
+			FilterSubjects: []string{subject},
+			MaxAckPending:  -1,
 
-		// AckWait specifies how long a consumer waits before considering a
-		// message delivered to a consumer as lost.
-		// Hence, the consumer needs to ack/nak or mark the msg as in progress
-		// before this time expires.
-		AckWait: opt.ackWait,
-	})
-	if err != nil {
-		return fmt.Errorf("create for consumer: %w", err)
-	}
-	cc, err := con.Consume(func(msg jetstream.Msg) {
-		msgMeta, err := msg.Metadata()
+			// AckWait specifies how long a consumer waits before considering a
+			// message delivered to a consumer as lost.
+			// Hence, the consumer needs to ack/nak or mark the msg as in progress
+			// before this time expires.
+			AckWait: opt.ackWait,
+		})
 		if err != nil {
-			slog.Error(
-				"getting msg metadata",
-				"subject",
-				msg.Subject(),
-				"error",
-				err,
-			)
-			_ = msg.Term()
+			return fmt.Errorf("create for consumer: %w", err)
 		}
-		kvop := opFromMsg(msg)
-		handleEvent := func(msg jetstream.Msg, event Event) {
-			var result Result
-			var err error
-			if opt.ch != nil {
-				event.Reply = make(chan EventResult)
-				opt.ch <- event
-				select {
-				case eventResult := <-event.Reply:
-					result = eventResult.Result
-					err = eventResult.Err
-				case <-time.After(time.Second * 5):
+		cc, err := con.Consume(func(msg jetstream.Msg) {
+			msgMeta, err := msg.Metadata()
+			if err != nil {
+				slog.Error(
+					"getting msg metadata",
+					"subject",
+					msg.Subject(),
+					"error",
+					err,
+				)
+				_ = msg.Term()
+				return
+			}
+			lagLabel := opt.forObject.ObjectKind()
+			metricWatcherLagMessages.WithLabelValues(lagLabel).
+				Set(float64(msgMeta.NumPending))
+			metricWatcherLagSeconds.WithLabelValues(lagLabel).
+				Set(time.Since(msgMeta.Timestamp).Seconds())
+			kvop := opFromMsg(msg)
+			handleEvent := func(msg jetstream.Msg, event Event) {
+				var result Result
+				var err error
+				if opt.ch != nil {
+					event.Reply = make(chan EventResult)
+					opt.ch <- event
+					select {
+					case eventResult := <-event.Reply:
+						result = eventResult.Result
+						err = eventResult.Err
+					case <-time.After(time.Second * 5):
+						slog.Error(
+							"waiting for event reply",
+							"event_operation",
+							event.Operation,
+							"key",
+							event.Key,
+						)
+						_ = msg.NakWithDelay(opt.backoff)
+						return
+					}
+				}
+				if opt.fn != nil {
+					result, err = opt.fn(event)
+				}
+				if err != nil {
 					slog.Error(
-						"waiting for event reply",
+						"handling event",
+						"error",
+						err,
+						"backoff",
+						opt.backoff,
 						"event_operation",
 						event.Operation,
-						"key",
-						event.Key,
 					)
 					_ = msg.NakWithDelay(opt.backoff)
 					return
 				}
+				switch {
+				case result.IsZero():
+					if lastMsg != nil &&
+						msgMeta.Sequence.Stream == lastMsg.Sequence {
+						w.markInit()
+					}
+					_ = msg.Ack()
+				case result.Requeue:
+					_ = msg.Nak()
+				case result.RequeueAfter > 0:
+					_ = msg.NakWithDelay(result.RequeueAfter)
+				}
 			}
-			if opt.fn != nil {
-				result, err = opt.fn(event)
-			}
+			rawKey := keyFromMsgSubject(kv, msg)
+			key, err := ObjectKeyFromString(rawKey)
 			if err != nil {
 				slog.Error(
-					"handling event",
+					"parsing key from subject",
 					"error",
 					err,
-					"backoff",
-					opt.backoff,
-					"event_operation",
-					event.Operation,
+					"subject",
+					msg.Subject(),
 				)
-				_ = msg.NakWithDelay(opt.backoff)
+				_ = msg.Term()
 				return
 			}
-			switch {
-			case result.IsZero():
-				if !w.isInit &&
-					msgMeta.Sequence.Stream == lastMsg.Sequence {
-					close(w.Init)
+			if kvop == jetstream.KeyValueDelete {
+				// If the operation is a KV delete, then the value has been
+				// deleted from the key value store.
+				// For watcher, this is the purge operation.
+				event := Event{
+					Operation: EventOperationPurge,
+					Key:       key,
+					Data:      nil,
 				}
-				_ = msg.Ack()
-			case result.Requeue:
-				_ = msg.Nak()
-			case result.RequeueAfter > 0:
-				_ = msg.NakWithDelay(result.RequeueAfter)
+				handleEvent(msg, event)
+				return
 			}
-		}
-		rawKey := keyFromMsgSubject(kv, msg)
-		key, err := ObjectKeyFromString(rawKey)
-		if err != nil {
-			slog.Error(
-				"parsing key from subject",
-				"error",
-				err,
-				"subject",
-				msg.Subject(),
-			)
-			_ = msg.Term()
-			return
-		}
-		if kvop == jetstream.KeyValueDelete {
-			// If the operation is a KV delete, then the value has been
-			// deleted from the key value store.
-			// For watcher, this is the purge operation.
-			event := Event{
-				Operation: EventOperationPurge,
-				Key:       key,
-				Data:      nil,
+			var gObj GenericObject
+			if err := json.Unmarshal(msg.Data(), &gObj); err != nil {
+				slog.Error(
+					"unmarshalling object",
+					"error",
+					err,
+					"data",
+					string(msg.Data()),
+				)
+				_ = msg.Term()
+				return
+			}
+			// Check if the object is marked for deletion.
+			if gObj.DeletionTimestamp != nil {
+				event := Event{
+					Operation: EventOperationDelete,
+					Key:       key,
+					Data:      msg.Data(),
+				}
+				handleEvent(msg, event)
+				return
 			}
-			handleEvent(msg, event)
-			return
-		}
-		var gObj GenericObject
-		if err := json.Unmarshal(msg.Data(), &gObj); err != nil {
-			slog.Error(
-				"unmarshalling object",
-				"error",
-				err,
-				"data",
-				string(msg.Data()),
-			)
-			_ = msg.Term()
-			return
-		}
-		// Check if the object is marked for deletion.
-		if gObj.DeletionTimestamp != nil {
 			event := Event{
-				Operation: EventOperationDelete,
+				Operation: EventOperationPut,
 				Key:       key,
 				Data:      msg.Data(),
 			}
 			handleEvent(msg, event)
-			return
+		})
+		if err != nil {
+			return fmt.Errorf("consume: %w", err)
 		}
-		event := Event{
-			Operation: EventOperationPut,
-			Key:       key,
-			Data:      msg.Data(),
+		w.mu.Lock()
+		w.consumeContext = cc
+		w.mu.Unlock()
+		return nil
+	}
+	if opt.leaderElection != nil {
+		le := opt.leaderElection
+		elector, err := StartLeaderElector(
+			ctx,
+			w.Conn,
+			le.name,
+			append([]LeaderElectorOption{
+				WithLeaderElectorOnGained(func(_ context.Context) {
+					if err := consume(); err != nil {
+						slog.Error("starting watcher", "error", err)
+					}
+				}),
+				WithLeaderElectorOnLost(w.stopConsume),
+			}, le.opts...)...,
+		)
+		if err != nil {
+			return fmt.Errorf("start leader election: %w", err)
 		}
-		handleEvent(msg, event)
+		w.elector = elector
+		go func() {
+			select {
+			case <-elector.campaigned:
+			case <-elector.done:
+			}
+			if !elector.IsLeader() {
+				w.markInit()
+			}
+		}()
+		return nil
+	}
+	return consume()
+}
+
+// markInit closes the Init channel, once.
+func (w *Watcher) markInit() {
+	w.initOnce.Do(func() {
+		close(w.Init)
 	})
-	if err != nil {
-		return fmt.Errorf("consume: %w", err)
+}
+
+func (w *Watcher) stopConsume() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.consumeContext != nil {
+		w.consumeContext.Stop()
+		w.consumeContext = nil
 	}
-	w.consumeContext = cc
-	return nil
 }
 
+// WaitUntilInit blocks until the Init channel is closed.
+// With leader election, it returns right away in watchers that are not the
+// leader.
 func (w *Watcher) WaitUntilInit() {
 	<-w.Init
 }
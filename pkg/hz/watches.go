@@ -0,0 +1,205 @@
+package hz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// MapFunc maps a changed object to the requests that should be reconciled
+// because of the change.
+//
+// The requests are for objects of the controller's kind (in its storage
+// version), so only the namespace and name of a request's key are required.
+type MapFunc func(ctx context.Context, obj GenericObject) []Request
+
+type watch struct {
+	object Objecter
+	mapFn  MapFunc
+}
+
+// validateWatches checks that the watches can each have their own consumer.
+func validateWatches(watches []watch) error {
+	kinds := make(map[string]struct{}, len(watches))
+	for _, w := range watches {
+		if w.object == nil || w.mapFn == nil {
+			return fmt.Errorf("watch requires an object and a map function")
+		}
+		kind := w.object.ObjectKind()
+		if _, ok := kinds[kind]; ok {
+			return fmt.Errorf("kind %q is watched more than once", kind)
+		}
+		kinds[kind] = struct{}{}
+	}
+	return nil
+}
+
+// startWatch creates the consumer for a watched kind, which starts a control
+// loop for each request that the map function returns.
+func (c *Controller) startWatch(
+	ctx context.Context,
+	opt controllerOption,
+	stream jetstream.Stream,
+	kv jetstream.KeyValue,
+	mutex mutex,
+	w watch,
+) error {
+	forObj := opt.forObject
+	ttl := mutex.ttl
+	subject := "$KV." + kv.Bucket() + "." + KeyFromObject(w.object)
+	con, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Name:           opt.consumerName("_w_" + w.object.ObjectKind()),
+		Description:    "Reconciler for " + forObj.ObjectKind() + " watches " + w.object.ObjectKind(),
+		DeliverPolicy:  jetstream.DeliverLastPerSubjectPolicy,
+		FilterSubjects: []string{subject},
+		MaxAckPending:  -1,
+		// AckWait specifies how long a consumer waits before considering a
+		// message delivered to a consumer as lost.
+		// Hence, the consumer needs to ack/nak or mark the msg as in
+		// progress before this time expires.
+		AckWait:           ttl,
+		InactiveThreshold: opt.inactiveThreshold(),
+	})
+	if err != nil {
+		return fmt.Errorf("create watches consumer: %w", err)
+	}
+	cc, err := con.Consume(func(msg jetstream.Msg) {
+		kvop := opFromMsg(msg)
+		if kvop == jetstream.KeyValueDelete {
+			// If the operation is a KV delete, then the value has been
+			// deleted, so ack it.
+			_ = msg.Ack()
+			return
+		}
+		var obj GenericObject
+		if err := json.Unmarshal(msg.Data(), &obj); err != nil {
+			slog.Error("unmarshal msg to generic object", "error", err)
+			_ = msg.Term()
+			return
+		}
+		keys := watchKeys(forObj, w.mapFn(ctx, obj))
+		if len(keys) == 0 {
+			_ = msg.Ack()
+			return
+		}
+		// Each request has its own control loop, which acks or naks the
+		// message. Only settle the message once all of them are done.
+		var loopMsg jetstream.Msg = msg
+		if len(keys) > 1 {
+			loopMsg = &fanOutMsg{Msg: msg, pending: len(keys)}
+		}
+		for _, key := range keys {
+			c.startControlLoop(
+				ctx,
+				opt.reconciler,
+				kv,
+				mutex,
+				key,
+				loopMsg,
+				ttl,
+			)
+		}
+	}, opt.consumeOpts()...)
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+	c.addConsumeContext(cc)
+	return nil
+}
+
+// watchKeys returns the unique keys of the requests, for objects of the
+// controller's kind.
+func watchKeys(forObj Objecter, reqs []Request) []string {
+	var keys []string
+	seen := make(map[string]struct{}, len(reqs))
+	for _, req := range reqs {
+		if req.Key == nil {
+			continue
+		}
+		if kind := req.Key.ObjectKind(); kind != "" && kind != "*" &&
+			kind != forObj.ObjectKind() {
+			slog.Error(
+				"watch: ignoring request for another kind",
+				"kind", kind,
+				"controller", forObj.ObjectKind(),
+			)
+			continue
+		}
+		key := KeyFromObject(ObjectKey{
+			Group:     forObj.ObjectGroup(),
+			Version:   forObj.ObjectVersion(),
+			Kind:      forObj.ObjectKind(),
+			Namespace: req.Key.ObjectNamespace(),
+			Name:      req.Key.ObjectName(),
+		})
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// fanOutMsg is a message that is shared by multiple control loops.
+//
+// The underlying message is acked once all the control loops have acked it.
+// If any of them naks it, it is redelivered after the shortest delay, which
+// reconciles all of the requests again.
+type fanOutMsg struct {
+	jetstream.Msg
+
+	mu      sync.Mutex
+	pending int
+	nak     bool
+	delay   time.Duration
+}
+
+func (m *fanOutMsg) Ack() error {
+	return m.done(false, 0)
+}
+
+func (m *fanOutMsg) DoubleAck(ctx context.Context) error {
+	return m.done(false, 0)
+}
+
+func (m *fanOutMsg) Nak() error {
+	return m.done(true, 0)
+}
+
+func (m *fanOutMsg) NakWithDelay(delay time.Duration) error {
+	return m.done(true, delay)
+}
+
+func (m *fanOutMsg) Term() error {
+	return m.done(false, 0)
+}
+
+func (m *fanOutMsg) TermWithReason(reason string) error {
+	return m.done(false, 0)
+}
+
+func (m *fanOutMsg) done(nak bool, delay time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == 0 {
+		return nil
+	}
+	m.pending--
+	if nak && (!m.nak || delay < m.delay) {
+		m.nak = true
+		m.delay = delay
+	}
+	if m.pending > 0 {
+		return nil
+	}
+	if m.nak {
+		return m.Msg.NakWithDelay(m.delay)
+	}
+	return m.Msg.Ack()
+}
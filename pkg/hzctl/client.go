@@ -8,7 +8,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
+	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/hz"
 )
 
@@ -118,9 +121,18 @@ func WithApplyData(data []byte) ApplyOption {
 	}
 }
 
+// WithApplyForce forces the apply, taking ownership of any fields that
+// conflict with other managers.
+func WithApplyForce(force bool) ApplyOption {
+	return func(opt *applyOptions) {
+		opt.force = force
+	}
+}
+
 type applyOptions struct {
 	object hz.Objecter
 	data   []byte
+	force  bool
 }
 
 func (c *Client) Apply(
@@ -152,6 +164,7 @@ func (c *Client) Apply(
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add(hz.HeaderAuthorization, c.Session)
 	req.Header.Add(hz.HeaderApplyFieldManager, c.Manager)
+	req.Header.Add(hz.HeaderApplyForceConflicts, strconv.FormatBool(ao.force))
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -253,3 +266,204 @@ func (c *Client) Delete(
 	}
 	return nil
 }
+
+// Migrate rewrites the object stored under key to the storage version of its
+// kind.
+func (c *Client) Migrate(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+) (hz.MigrateOpResult, error) {
+	if _, err := hz.KeyFromObjectStrict(key); err != nil {
+		return hz.MigrateOpResultError, fmt.Errorf(
+			"migrate: invalid key: %w",
+			err,
+		)
+	}
+	reqURL, err := url.JoinPath(
+		c.Server,
+		"v1",
+		"objects",
+		key.ObjectGroup(),
+		key.ObjectVersion(),
+		key.ObjectKind(),
+		key.ObjectNamespace(),
+		key.ObjectName(),
+		"migrate",
+	)
+	if err != nil {
+		return hz.MigrateOpResultError, fmt.Errorf(
+			"creating request url: %w",
+			err,
+		)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		reqURL,
+		nil,
+	)
+	if err != nil {
+		return hz.MigrateOpResultError, fmt.Errorf(
+			"creating request: %w",
+			err,
+		)
+	}
+	req.Header.Add(hz.HeaderAuthorization, c.Session)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return hz.MigrateOpResultError, fmt.Errorf(
+			"executing request: %w",
+			err,
+		)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return hz.MigrateOpResultMigrated, nil
+	case http.StatusNotModified:
+		return hz.MigrateOpResultNoop, nil
+	case http.StatusConflict:
+		return hz.MigrateOpResultConflict, hz.ErrorFromHTTP(resp)
+	default:
+		return hz.MigrateOpResultError, hz.ErrorFromHTTP(resp)
+	}
+}
+
+// Audit queries the audit log.
+// Only entries for objects the user can read are returned.
+func (c *Client) Audit(
+	ctx context.Context,
+	query auth.AuditQuery,
+) ([]auth.AuditEntry, error) {
+	reqURL, err := url.JoinPath(c.Server, "v1", "audit")
+	if err != nil {
+		return nil, fmt.Errorf("creating request url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set(hz.HeaderAuthorization, c.Session)
+
+	q := req.URL.Query()
+	if query.User != "" {
+		q.Add("user", query.User)
+	}
+	if query.Namespace != "" {
+		q.Add("namespace", query.Namespace)
+	}
+	if !query.Since.IsZero() {
+		q.Add("since", query.Since.Format(time.RFC3339))
+	}
+	if !query.Until.IsZero() {
+		q.Add("until", query.Until.Format(time.RFC3339))
+	}
+	if query.Limit > 0 {
+		q.Add("limit", strconv.Itoa(query.Limit))
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := hz.ErrorFromHTTP(resp); err != nil {
+		return nil, err
+	}
+	var entries []auth.AuditEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return entries, nil
+}
+
+// Controllers returns the status of the running controllers from the
+// controller registry.
+// Only admins can list the controllers.
+func (c *Client) Controllers(
+	ctx context.Context,
+) ([]hz.ControllerStatus, error) {
+	reqURL, err := url.JoinPath(c.Server, "v1", "controllers")
+	if err != nil {
+		return nil, fmt.Errorf("creating request url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set(hz.HeaderAuthorization, c.Session)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := hz.ErrorFromHTTP(resp); err != nil {
+		return nil, err
+	}
+	var statuses []hz.ControllerStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return statuses, nil
+}
+
+// DeadLetters returns the objects that controllers stopped retrying, because
+// they exceeded their max retries.
+// Only admins can list the dead letters.
+func (c *Client) DeadLetters(
+	ctx context.Context,
+) ([]hz.DeadLetter, error) {
+	reqURL, err := url.JoinPath(c.Server, "v1", "deadletters")
+	if err != nil {
+		return nil, fmt.Errorf("creating request url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set(hz.HeaderAuthorization, c.Session)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := hz.ErrorFromHTTP(resp); err != nil {
+		return nil, err
+	}
+	var deadLetters []hz.DeadLetter
+	if err := json.NewDecoder(resp.Body).Decode(&deadLetters); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return deadLetters, nil
+}
+
+// RedriveDeadLetter makes the controller reconcile the dead-lettered object
+// with the key again.
+// Only admins can re-drive dead letters.
+func (c *Client) RedriveDeadLetter(ctx context.Context, key string) error {
+	reqURL, err := url.JoinPath(c.Server, "v1", "deadletters", key, "redrive")
+	if err != nil {
+		return fmt.Errorf("creating request url: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set(hz.HeaderAuthorization, c.Session)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return hz.ErrorFromHTTP(resp)
+}
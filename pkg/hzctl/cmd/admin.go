@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Administer a Horizon server",
+	Long:  `Admin commands perform maintenance tasks on a Horizon server.`,
+}
+
+func init() {
+	rootCmd.AddCommand(adminCmd)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/hzctl"
+)
+
+type adminMigrateCmdOptions struct {
+	namespace string
+	retries   int
+}
+
+var adminMigrateOpts adminMigrateCmdOptions
+
+var adminMigrateCmd = &cobra.Command{
+	Use:   "migrate <group>/<kind>",
+	Short: "Migrate stored objects to the storage version of their kind.",
+	Long: `Migrate rewrites every stored object of a kind to the current storage version of the kind.
+
+Objects that are already stored using the storage version are skipped, so if
+a migration is interrupted it can be resumed by running it again.`,
+	Args:          cobra.ExactArgs(1),
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hCtx, err := config.Context(
+			hzctl.WithContextCurrent(true),
+			hzctl.WithContextValidate(hzctl.WithValidateSession(true)),
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"obtaining current context: %w",
+				err,
+			)
+		}
+		group, kind, ok := strings.Cut(args[0], "/")
+		if !ok || group == "" || kind == "" {
+			return fmt.Errorf(
+				"invalid kind %q: expected <group>/<kind>",
+				args[0],
+			)
+		}
+
+		client := hzctl.Client{
+			Server:  hCtx.URL,
+			Session: *hCtx.Session,
+		}
+		ctx := context.Background()
+		// List all objects of the kind, regardless of the version they are
+		// stored under.
+		resp := hz.GenericObjectList{}
+		if err := client.List(
+			ctx,
+			hzctl.WithListKey(hz.ObjectKey{
+				Group:     group,
+				Kind:      kind,
+				Namespace: adminMigrateOpts.namespace,
+			}),
+			hzctl.WithListResponseGenericObject(&resp),
+		); err != nil {
+			return fmt.Errorf("list: %w", err)
+		}
+		keys := make([]hz.ObjectKey, len(resp.Items))
+		for i, item := range resp.Items {
+			keys[i] = hz.ObjectKeyFromObject(item)
+		}
+		// Migrate in a stable order so that progress is easy to follow.
+		slices.SortFunc(keys, func(a, b hz.ObjectKey) int {
+			return strings.Compare(a.String(), b.String())
+		})
+
+		var migrated, skipped, failed int
+		for i, key := range keys {
+			result, err := migrateObject(
+				ctx,
+				&client,
+				key,
+				adminMigrateOpts.retries,
+			)
+			fmt.Printf("[%d/%d] %s: %s\n", i+1, len(keys), key, result)
+			switch result {
+			case hz.MigrateOpResultMigrated:
+				migrated++
+			case hz.MigrateOpResultNoop:
+				skipped++
+			default:
+				failed++
+				fmt.Printf("\terror: %s\n", err)
+			}
+		}
+		fmt.Printf(
+			"migrated: %d, skipped: %d, failed: %d\n",
+			migrated,
+			skipped,
+			failed,
+		)
+		if failed > 0 {
+			return fmt.Errorf(
+				"failed to migrate %d objects: run the migration again to retry",
+				failed,
+			)
+		}
+		return nil
+	},
+}
+
+// migrateObject migrates a single object, retrying if the object was modified
+// during the migration.
+func migrateObject(
+	ctx context.Context,
+	client *hzctl.Client,
+	key hz.ObjectKey,
+	retries int,
+) (hz.MigrateOpResult, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := client.Migrate(ctx, key)
+		if result != hz.MigrateOpResultConflict || attempt >= retries {
+			return result, err
+		}
+	}
+}
+
+func init() {
+	adminCmd.AddCommand(adminMigrateCmd)
+
+	flags := adminMigrateCmd.Flags()
+	flags.StringVarP(
+		&adminMigrateOpts.namespace,
+		"namespace",
+		"n",
+		"",
+		"Only migrate objects in this namespace",
+	)
+	flags.IntVar(
+		&adminMigrateOpts.retries,
+		"retries",
+		5,
+		"Number of times to retry an object that is modified during migration",
+	)
+}
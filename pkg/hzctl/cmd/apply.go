@@ -2,16 +2,19 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/verifa/horizon/pkg/hz"
 	"github.com/verifa/horizon/pkg/hzctl"
 	"sigs.k8s.io/yaml"
 )
 
 type applyCmdOptions struct {
-	filename string
+	filename       string
+	forceConflicts bool
 }
 
 var applyOpts applyCmdOptions
@@ -53,8 +56,23 @@ var applyCmd = &cobra.Command{
 		}
 
 		ctx := context.Background()
-		result, err := client.Apply(ctx, hzctl.WithApplyData(jData))
+		result, err := client.Apply(
+			ctx,
+			hzctl.WithApplyData(jData),
+			hzctl.WithApplyForce(applyOpts.forceConflicts),
+		)
 		if err != nil {
+			var hErr *hz.Error
+			if result == hz.ApplyOpResultConflict &&
+				errors.As(err, &hErr) &&
+				hErr.Details != nil &&
+				len(hErr.Details.Conflicts) > 0 {
+				printApplyConflicts(hErr.Details.Conflicts)
+				fmt.Println(
+					"To take ownership of these fields, apply again with --force-conflicts.",
+				)
+				return errors.New("apply: conflicts with other managers")
+			}
 			return fmt.Errorf("apply: %w", err)
 		}
 
@@ -75,4 +93,10 @@ func init() {
 		"",
 		"Filename to apply",
 	)
+	flags.BoolVar(
+		&applyOpts.forceConflicts,
+		"force-conflicts",
+		false,
+		"Take ownership of fields that conflict with other managers",
+	)
 }
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/verifa/horizon/pkg/auth"
+	"github.com/verifa/horizon/pkg/hzctl"
+)
+
+type auditCmdOptions struct {
+	user      string
+	namespace string
+	since     string
+	until     string
+	limit     int
+}
+
+var auditOpts auditCmdOptions
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the audit log of API requests.",
+	Long: `Query the audit log of requests that created, updated, deleted or ran
+actions on Horizon objects.
+
+Only entries for objects you can read are shown.
+
+The --since and --until flags accept either a duration relative to now (e.g.
+"1h") or an RFC3339 timestamp.`,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hCtx, err := config.Context(
+			hzctl.WithContextCurrent(true),
+			hzctl.WithContextValidate(hzctl.WithValidateSession(true)),
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"obtaining current context: %w",
+				err,
+			)
+		}
+		query := auth.AuditQuery{
+			User:      auditOpts.user,
+			Namespace: auditOpts.namespace,
+			Limit:     auditOpts.limit,
+		}
+		if query.Since, err = parseAuditTime(auditOpts.since); err != nil {
+			return fmt.Errorf("invalid since: %w", err)
+		}
+		if query.Until, err = parseAuditTime(auditOpts.until); err != nil {
+			return fmt.Errorf("invalid until: %w", err)
+		}
+
+		client := hzctl.Client{
+			Server:  hCtx.URL,
+			Session: *hCtx.Session,
+		}
+		ctx := context.Background()
+		entries, err := client.Audit(ctx, query)
+		if err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		if len(entries) == 0 {
+			fmt.Println("No audit entries found")
+			return nil
+		}
+		printAuditEntries(entries)
+		return nil
+	},
+}
+
+// parseAuditTime parses either a duration (relative to now) or an RFC3339
+// timestamp.
+// An empty string returns the zero time.
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	flags := auditCmd.Flags()
+	flags.StringVarP(
+		&auditOpts.user,
+		"user",
+		"u",
+		"",
+		"Only show entries for this user",
+	)
+	flags.StringVarP(
+		&auditOpts.namespace,
+		"namespace",
+		"n",
+		"",
+		"Only show entries for objects in this namespace",
+	)
+	flags.StringVar(
+		&auditOpts.since,
+		"since",
+		"",
+		"Only show entries after this time",
+	)
+	flags.StringVar(
+		&auditOpts.until,
+		"until",
+		"",
+		"Only show entries before this time",
+	)
+	flags.IntVar(
+		&auditOpts.limit,
+		"limit",
+		100,
+		"Maximum number of (most recent) entries to show",
+	)
+}
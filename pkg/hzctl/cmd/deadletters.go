@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var deadLettersCmd = &cobra.Command{
+	Use:   "deadletters",
+	Short: "Manage objects that controllers stopped retrying",
+	Long: `Dead letters are objects that a controller stopped retrying, because the
+reconciler failed more times than the controller's max retries.
+
+Only admins can manage dead letters.`,
+}
+
+func init() {
+	rootCmd.AddCommand(deadLettersCmd)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/verifa/horizon/pkg/hzctl"
+	"sigs.k8s.io/yaml"
+)
+
+type deadLettersListCmdOptions struct {
+	output string
+}
+
+var deadLettersListOpts deadLettersListCmdOptions
+
+var deadLettersListCmd = &cobra.Command{
+	Use:           "list",
+	Short:         "List the dead letters.",
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hCtx, err := config.Context(
+			hzctl.WithContextCurrent(true),
+			hzctl.WithContextValidate(hzctl.WithValidateSession(true)),
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"obtaining current context: %w",
+				err,
+			)
+		}
+		client := hzctl.Client{
+			Server:  hCtx.URL,
+			Session: *hCtx.Session,
+		}
+		deadLetters, err := client.DeadLetters(context.Background())
+		if err != nil {
+			return fmt.Errorf("list dead letters: %w", err)
+		}
+		if len(deadLetters) == 0 {
+			fmt.Println("No dead letters found")
+			return nil
+		}
+		switch deadLettersListOpts.output {
+		case "", outputTable:
+			printDeadLetters(deadLetters)
+		case outputYAML:
+			jb, err := json.Marshal(deadLetters)
+			if err != nil {
+				return fmt.Errorf("marshalling dead letters: %w", err)
+			}
+			yb, err := yaml.JSONToYAML(jb)
+			if err != nil {
+				return fmt.Errorf("converting to yaml: %w", err)
+			}
+			fmt.Println(string(yb))
+		default:
+			return fmt.Errorf(
+				"invalid output format: %q",
+				deadLettersListOpts.output,
+			)
+		}
+		return nil
+	},
+}
+
+func init() {
+	deadLettersCmd.AddCommand(deadLettersListCmd)
+
+	deadLettersListCmd.Flags().StringVarP(
+		&deadLettersListOpts.output,
+		"output",
+		"o",
+		"",
+		"Output format: table or yaml",
+	)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/verifa/horizon/pkg/hzctl"
+)
+
+type deadLettersRedriveCmdOptions struct {
+	all bool
+}
+
+var deadLettersRedriveOpts deadLettersRedriveCmdOptions
+
+var deadLettersRedriveCmd = &cobra.Command{
+	Use:   "redrive [key...]",
+	Short: "Reconcile dead-lettered objects again.",
+	Long: `Reconcile dead-lettered objects again, e.g. once the cause of the failure is
+fixed.
+
+The keys are shown by "hzctl deadletters list". A dead letter is removed once
+its object reconciles successfully.`,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !deadLettersRedriveOpts.all {
+			return fmt.Errorf("either a key or --all is required")
+		}
+		hCtx, err := config.Context(
+			hzctl.WithContextCurrent(true),
+			hzctl.WithContextValidate(hzctl.WithValidateSession(true)),
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"obtaining current context: %w",
+				err,
+			)
+		}
+		client := hzctl.Client{
+			Server:  hCtx.URL,
+			Session: *hCtx.Session,
+		}
+		ctx := context.Background()
+		keys := args
+		if deadLettersRedriveOpts.all {
+			deadLetters, err := client.DeadLetters(ctx)
+			if err != nil {
+				return fmt.Errorf("list dead letters: %w", err)
+			}
+			keys = nil
+			for _, deadLetter := range deadLetters {
+				keys = append(keys, deadLetter.Key)
+			}
+		}
+		for _, key := range keys {
+			if err := client.RedriveDeadLetter(ctx, key); err != nil {
+				return fmt.Errorf("redrive %q: %w", key, err)
+			}
+			fmt.Println("Re-driven", key)
+		}
+		return nil
+	},
+}
+
+func init() {
+	deadLettersCmd.AddCommand(deadLettersRedriveCmd)
+
+	deadLettersRedriveCmd.Flags().BoolVar(
+		&deadLettersRedriveOpts.all,
+		"all",
+		false,
+		"Re-drive all dead letters",
+	)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/verifa/horizon/pkg/extensions/core"
+	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/hzctl"
+)
+
+type eventsCmdOptions struct {
+	namespace string
+}
+
+var eventsOpts eventsCmdOptions
+
+var eventsCmd = &cobra.Command{
+	Use:   "events <kind> <name>",
+	Short: "Show the events about an object.",
+	Long: `Show the events that controllers recorded about an object, oldest first.
+
+Events with the same type, reason and message are shown once, with the number
+of times they occurred. Events expire after a TTL (one hour by default).`,
+	Args:          cobra.ExactArgs(2),
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hCtx, err := config.Context(
+			hzctl.WithContextCurrent(true),
+			hzctl.WithContextValidate(hzctl.WithValidateSession(true)),
+		)
+		if err != nil {
+			return fmt.Errorf(
+				"obtaining current context: %w",
+				err,
+			)
+		}
+		kind, name := args[0], args[1]
+
+		client := hzctl.Client{
+			Server:  hCtx.URL,
+			Session: *hCtx.Session,
+		}
+		ctx := context.Background()
+		var buf bytes.Buffer
+		if err := client.List(
+			ctx,
+			hzctl.WithListKey(hz.ObjectKey{
+				Group:     core.ObjectGroup,
+				Kind:      core.ObjectKindEvent,
+				Namespace: eventsOpts.namespace,
+			}),
+			hzctl.WithListResponseWriter(&buf),
+		); err != nil {
+			return fmt.Errorf("list: %w", err)
+		}
+		var resp hz.TypedObjectList[core.Event]
+		if err := json.Unmarshal(buf.Bytes(), &resp); err != nil {
+			return fmt.Errorf("decoding events: %w", err)
+		}
+		events := slices.DeleteFunc(resp.Items, func(e core.Event) bool {
+			return e.InvolvedObject.Kind != kind ||
+				e.InvolvedObject.Name != name
+		})
+		if len(events) == 0 {
+			fmt.Println("No events found")
+			return nil
+		}
+		slices.SortStableFunc(events, func(a, b core.Event) int {
+			return a.LastSeen().Compare(b.LastSeen())
+		})
+		printEvents(events)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd)
+
+	flags := eventsCmd.Flags()
+	flags.StringVarP(
+		&eventsOpts.namespace,
+		"namespace",
+		"n",
+		"",
+		"Only show events for objects in this namespace",
+	)
+}
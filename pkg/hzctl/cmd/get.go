@@ -2,17 +2,42 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/verifa/horizon/pkg/hz"
 	"github.com/verifa/horizon/pkg/hzctl"
+	"sigs.k8s.io/yaml"
 )
 
+const (
+	outputTable = "table"
+	outputYAML  = "yaml"
+)
+
+type getCmdOptions struct {
+	output            string
+	showManagedFields bool
+}
+
+var getOpts getCmdOptions
+
 var getCmd = &cobra.Command{
-	Use:           "get",
-	Short:         "Get Horizon objects.",
+	Use:   "get",
+	Short: "Get Horizon objects.",
+	Long: `Get Horizon objects.
+
+A list of objects is printed as a table, and a single object as YAML, unless
+the --output flag is set.
+
+The managed fields of objects are hidden, unless the --show-managed-fields flag
+is set. They show which managers own which fields, the operation and API
+version each manager last used, and when each manager last changed the object.
+
+"hzctl get controllers" lists the running controllers, with their reconcile
+stats and last error. Only admins can list the controllers.`,
 	SilenceErrors: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hCtx, err := config.Context(
@@ -33,6 +58,10 @@ var getCmd = &cobra.Command{
 		case 0:
 			return fmt.Errorf("at least one argument is required")
 		case 1:
+			if args[0] == "controllers" {
+				return getControllers(hCtx.URL, *hCtx.Session)
+			}
+
 			objectType = &args[0]
 		case 2:
 			objectType = &args[0]
@@ -73,10 +102,32 @@ var getCmd = &cobra.Command{
 			return nil
 		}
 
-		if key.Name == "" {
+		output := getOpts.output
+		if output == "" {
+			output = outputTable
+			if key.Name != "" {
+				output = outputYAML
+			}
+		}
+		if !getOpts.showManagedFields {
+			for i := range resp.Items {
+				resp.Items[i].ManagedFields = nil
+			}
+		}
+		switch output {
+		case outputTable:
 			printObjects(resp.Items)
-		} else {
-			_ = printObject(resp.Items[0])
+		case outputYAML:
+			for i, obj := range resp.Items {
+				if i > 0 {
+					fmt.Println("---")
+				}
+				if err := printObject(obj); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("invalid output format: %q", output)
 		}
 
 		return nil
@@ -85,4 +136,51 @@ var getCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(getCmd)
+
+	flags := getCmd.Flags()
+	flags.StringVarP(
+		&getOpts.output,
+		"output",
+		"o",
+		"",
+		"Output format: table or yaml",
+	)
+	flags.BoolVar(
+		&getOpts.showManagedFields,
+		"show-managed-fields",
+		false,
+		"Show the managed fields of objects in yaml output",
+	)
+}
+
+func getControllers(server string, session string) error {
+	client := hzctl.Client{
+		Server:  server,
+		Session: session,
+	}
+	statuses, err := client.Controllers(context.Background())
+	if err != nil {
+		return fmt.Errorf("list controllers: %w", err)
+	}
+	if len(statuses) == 0 {
+		fmt.Println("No controllers found")
+		return nil
+	}
+	switch getOpts.output {
+	case "", outputTable:
+		printControllers(statuses)
+	case outputYAML:
+		jb, err := json.Marshal(statuses)
+		if err != nil {
+			return fmt.Errorf("marshalling controllers: %w", err)
+		}
+		yb, err := yaml.JSONToYAML(jb)
+		if err != nil {
+			return fmt.Errorf("converting to yaml: %w", err)
+		}
+		fmt.Println(string(yb))
+	default:
+		return fmt.Errorf("invalid output format: %q", getOpts.output)
+	}
+	return nil
 }
@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
+	"github.com/verifa/horizon/pkg/auth"
+	"github.com/verifa/horizon/pkg/extensions/core"
 	"github.com/verifa/horizon/pkg/hz"
 	"sigs.k8s.io/yaml"
 )
@@ -32,6 +37,144 @@ func printObject(object hz.GenericObject) error {
 }
 
 func printObjects(objects []hz.GenericObject) {
+	rows := make([][]string, len(objects))
+	for i, obj := range objects {
+		rows[i] = []string{
+			obj.Kind,
+			obj.Namespace,
+			obj.Name,
+		}
+	}
+	printTable([]string{"Kind", "Namespace", "Name"}, rows)
+}
+
+func printAuditEntries(entries []auth.AuditEntry) {
+	rows := make([][]string, len(entries))
+	for i, entry := range entries {
+		verb := string(entry.Verb)
+		if entry.Action != "" {
+			verb += " " + entry.Action
+		}
+		rows[i] = []string{
+			entry.Time.Local().Format(time.DateTime),
+			entry.User,
+			verb,
+			entry.Kind,
+			entry.Namespace,
+			entry.Name,
+			strconv.Itoa(entry.Status),
+			strings.Join(entry.Changes, "\n"),
+		}
+	}
+	printTable(
+		[]string{
+			"Time",
+			"User",
+			"Verb",
+			"Kind",
+			"Namespace",
+			"Name",
+			"Status",
+			"Changes",
+		},
+		rows,
+	)
+}
+
+func printControllers(statuses []hz.ControllerStatus) {
+	rows := make([][]string, len(statuses))
+	for i, status := range statuses {
+		leader := ""
+		if status.Reconciler {
+			leader = strconv.FormatBool(status.Leader)
+		}
+		rows[i] = []string{
+			status.Group,
+			status.Kind,
+			status.Version,
+			status.InstanceID,
+			leader,
+			strconv.FormatUint(status.Stats.Reconciles, 10),
+			strconv.FormatUint(status.Stats.Errors, 10),
+			strconv.FormatInt(status.Stats.Active, 10),
+			status.HeartbeatTime.Local().Format(time.DateTime),
+			status.LastError,
+		}
+	}
+	printTable(
+		[]string{
+			"Group",
+			"Kind",
+			"Version",
+			"Instance",
+			"Leader",
+			"Reconciles",
+			"Errors",
+			"Active",
+			"Heartbeat",
+			"Last Error",
+		},
+		rows,
+	)
+}
+
+func printDeadLetters(deadLetters []hz.DeadLetter) {
+	rows := make([][]string, len(deadLetters))
+	for i, deadLetter := range deadLetters {
+		rows[i] = []string{
+			deadLetter.Key,
+			strconv.Itoa(deadLetter.Attempts),
+			deadLetter.Time.Local().Format(time.DateTime),
+			deadLetter.Error,
+		}
+	}
+	printTable(
+		[]string{
+			"Key",
+			"Attempts",
+			"Time",
+			"Error",
+		},
+		rows,
+	)
+}
+
+func printEvents(events []core.Event) {
+	rows := make([][]string, len(events))
+	for i, event := range events {
+		rows[i] = []string{
+			event.LastSeen().Local().Format(time.DateTime),
+			event.Namespace,
+			event.Type,
+			event.Reason,
+			strconv.Itoa(event.Count),
+			event.Source,
+			event.Message,
+		}
+	}
+	printTable(
+		[]string{
+			"Last Seen",
+			"Namespace",
+			"Type",
+			"Reason",
+			"Count",
+			"Source",
+			"Message",
+		},
+		rows,
+	)
+}
+
+func printApplyConflicts(conflicts []hz.ApplyConflict) {
+	rows := make([][]string, len(conflicts))
+	for i, conflict := range conflicts {
+		rows[i] = []string{conflict.Field, conflict.Manager}
+	}
+	printTable([]string{"Field", "Manager"}, rows)
+}
+
+func printTable(headers []string, rows [][]string) {
 	re := lipgloss.NewRenderer(os.Stdout)
 	var (
 		// HeaderStyle is the lipgloss style used for the table headers.
@@ -49,15 +192,6 @@ func printObjects(objects []hz.GenericObject) {
 		BorderStyle = lipgloss.NewStyle().Foreground(purple)
 	)
 
-	rows := make([][]string, len(objects))
-	for i, obj := range objects {
-		rows[i] = []string{
-			obj.Kind,
-			obj.Namespace,
-			obj.Name,
-		}
-	}
-
 	t := table.New().
 		Border(lipgloss.NormalBorder()).
 		BorderStyle(BorderStyle).
@@ -71,11 +205,7 @@ func printObjects(objects []hz.GenericObject) {
 				return OddRowStyle
 			}
 		}).
-		Headers(
-			"Kind",
-			"Namespace",
-			"Name",
-		).
+		Headers(headers...).
 		Rows(rows...)
 
 	fmt.Println(t)
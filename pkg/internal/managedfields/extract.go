@@ -47,11 +47,10 @@ func ExtractFieldsV1Object(
 	return dst, nil
 }
 
-// extractFieldsV1Array takes a src slice of objects and returns a dst slice
-// with the elements that are owned according tot he FieldsV1.
-// key of the FieldsV1Step matches the object.
-// An array (slice) can only be owned on an element level if it is an array of
-// objects. Otherwise the field containing the array is considered a leaf and
+// extractFieldsV1Array takes a src slice and returns a dst slice with the
+// elements that are owned according to the FieldsV1.
+// An array (slice) can only be owned on an element level if it is a set or a
+// map list. Otherwise the field containing the array is considered a leaf and
 // owned entirely by the field manager.
 func extractFieldsV1Array(
 	src []interface{},
@@ -62,13 +61,17 @@ func extractFieldsV1Array(
 		return src, nil
 	}
 	dst := make([]interface{}, 0)
-	for key, elem := range fields.Elements {
-		index := FindIndexArrayByKey(src, key)
-		if index == -1 {
+	for _, srcElem := range src {
+		key, elem, ok := fields.element(srcElem)
+		if !ok {
+			continue
+		}
+		if key.Type == FieldsV1KeySet {
+			dst = append(dst, srcElem)
 			continue
 		}
 		subDst, err := ExtractFieldsV1Object(
-			src[index].(map[string]interface{}),
+			srcElem.(map[string]interface{}),
 			elem,
 		)
 		if err != nil {
@@ -80,17 +83,16 @@ func extractFieldsV1Array(
 	return dst, nil
 }
 
-// FindIndexArrayByKey takes an array and a FieldsV1Step.
-// It iterates over the array, and if the elements are objects, if checks if the
-// key of the FieldsV1Step matches the object.
-// If it finds the key-object pairing, it returns the index.
-// If it doesn't find the key-object pairing, it returns -1.
+// FindIndexArrayByKey takes an array and a FieldsV1Key.
+// It iterates over the array and checks if the element is identified by the
+// key, i.e. the element of a set has the key's value, or the object of a map
+// list has the key's key-value pair.
+// If it finds the element, it returns the index.
+// If it doesn't find the element, it returns -1.
 func FindIndexArrayByKey(obj []interface{}, key FieldsV1Key) int {
 	for i, e := range obj {
-		if v, ok := e.(map[string]interface{}); ok {
-			if value, ok := v[key.Key]; ok && value == key.Value {
-				return i
-			}
+		if matchesKey(e, key) {
+			return i
 		}
 	}
 	return -1
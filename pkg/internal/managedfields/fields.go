@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type ManagedFields []FieldManager
@@ -26,6 +28,16 @@ const (
 	FieldsTypeV1 FieldsType = "FieldsV1"
 )
 
+// Operation is the type of operation that a field manager performed.
+type Operation string
+
+const (
+	// OperationApply is a server-side apply of an object.
+	OperationApply Operation = "Apply"
+	// OperationUpdate is a write that replaces the object, such as a create.
+	OperationUpdate Operation = "Update"
+)
+
 // FieldManager is a manager of fields for a given object.
 // An object can have multiple field managers, and those field managers make up
 // the managed fields for the object.
@@ -37,6 +49,14 @@ type FieldManager struct {
 	FieldsType FieldsType `json:"fieldsType" cue:"=~\"^FieldsV1$\""`
 	// FieldsV1 is the actual fields that are managed.
 	FieldsV1 FieldsV1 `json:"fieldsV1" cue:""`
+	// Operation is the type of operation that last changed the fields.
+	Operation Operation `json:"operation,omitempty" cue:",opt"`
+	// APIVersion is the API version of the object that the manager used in
+	// its last operation.
+	APIVersion string `json:"apiVersion,omitempty" cue:",opt"`
+	// Time is when the manager last changed the object.
+	// An operation that does not change the object does not update the time.
+	Time *time.Time `json:"time,omitempty" cue:",opt"`
 }
 
 // FieldsV1 is the actual fields that are managed.
@@ -61,6 +81,60 @@ func (f FieldsV1) IsLeaf() bool {
 	return len(f.Fields) == 0 && len(f.Elements) == 0
 }
 
+// Lookup returns the field at the path, relative to f.
+func (f FieldsV1) Lookup(path FieldsV1Path) (FieldsV1, bool) {
+	field := f
+	for _, step := range path {
+		var ok bool
+		if step.Key.Type == FieldsV1KeyObject {
+			field, ok = field.Fields[step.Key]
+		} else {
+			field, ok = field.Elements[step.Key]
+		}
+		if !ok {
+			return FieldsV1{}, false
+		}
+	}
+	return field, true
+}
+
+// Paths returns the paths from f to each of its leaf nodes, sorted by their
+// string representation.
+func (f FieldsV1) Paths() []FieldsV1Path {
+	var paths []FieldsV1Path
+	var walk func(field FieldsV1, path FieldsV1Path)
+	walk = func(field FieldsV1, path FieldsV1Path) {
+		if field.IsLeaf() {
+			if len(path) > 0 {
+				paths = append(paths, slices.Clone(path))
+			}
+			return
+		}
+		for key, subField := range field.Fields {
+			walk(subField, append(path, FieldsV1Step{Key: key}))
+		}
+		for key, subField := range field.Elements {
+			walk(subField, append(path, FieldsV1Step{Key: key}))
+		}
+	}
+	walk(f, FieldsV1Path{})
+	slices.SortFunc(paths, func(a, b FieldsV1Path) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return paths
+}
+
+// element returns the key and fields of the list element elem, if the fields
+// contain it.
+func (f FieldsV1) element(elem interface{}) (FieldsV1Key, FieldsV1, bool) {
+	for key, subFields := range f.Elements {
+		if matchesKey(elem, key) {
+			return key, subFields, true
+		}
+	}
+	return FieldsV1Key{}, FieldsV1{}, false
+}
+
 // Path constructs a path from this node to the root.
 // It only works if the parent is set, which is only the case when creating a
 // [FieldsV1].
@@ -74,12 +148,25 @@ func (f FieldsV1) Path() FieldsV1Path {
 // FieldsV1Path is a series of steps from a node (root) to a leaf node.
 type FieldsV1Path []FieldsV1Step
 
+// String returns the path as a string, e.g. `spec.env[name="A"].value` for a
+// field in an element of a map list, or "spec.tags[\"a\"]" for an element of a
+// set list.
 func (p FieldsV1Path) String() string {
-	steps := []string{}
+	var b strings.Builder
 	for _, step := range p {
-		steps = append(steps, step.Key.Key)
+		switch step.Key.Type {
+		case FieldsV1KeyArray:
+			fmt.Fprintf(&b, "[%s=%s]", step.Key.Key, step.Key.Value)
+		case FieldsV1KeySet:
+			fmt.Fprintf(&b, "[%s]", step.Key.Value)
+		default:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(step.Key.Key)
+		}
 	}
-	return strings.Join(steps, ".")
+	return b.String()
 }
 
 type FieldsV1Step struct {
@@ -99,11 +186,14 @@ func (s FieldsV1Step) String() string {
 }
 
 // FieldsV1Key represents a key in a FieldsV1 object.
-// It can be either an object key (string) or an array (key-value).
+// It can be either an object key (string), an element of a map list
+// (key-value) or an element of a set list (value).
 type FieldsV1Key struct {
-	Type  FieldsV1KeyType `json:"-"`
-	Key   string          `json:"-"`
-	Value string          `json:"-"`
+	Type FieldsV1KeyType `json:"-"`
+	Key  string          `json:"-"`
+	// Value is the JSON encoded value of the element (set) or of its key
+	// field (map), so that values of different types are not equal.
+	Value string `json:"-"`
 }
 
 type FieldsV1KeyType int
@@ -111,6 +201,9 @@ type FieldsV1KeyType int
 const (
 	FieldsV1KeyObject FieldsV1KeyType = iota
 	FieldsV1KeyArray
+	// FieldsV1KeySet is the key of an element in a set list.
+	// The value is the JSON encoded element.
+	FieldsV1KeySet
 )
 
 func (f FieldsV1) MarshalJSON() ([]byte, error) {
@@ -148,11 +241,16 @@ func (f FieldsV1) MarshalJSON() ([]byte, error) {
 			if index > 0 {
 				buf.WriteString(",")
 			}
-			// Could json unmarshal key otherwise, but this seems easier
-			// somehow.
-			strKey := strconv.Quote(
-				fmt.Sprintf("k:{\"%s\":\"%s\"}", key.Key, key.Value),
-			)
+			var strKey string
+			if key.Type == FieldsV1KeySet {
+				strKey = strconv.Quote("v:" + key.Value)
+			} else {
+				bKey, err := json.Marshal(key)
+				if err != nil {
+					return nil, err
+				}
+				strKey = strconv.Quote("k:" + string(bKey))
+			}
 			buf.WriteString(fmt.Sprintf("%s:%s", strKey, bSub))
 			index++
 		}
@@ -203,6 +301,20 @@ func (f *FieldsV1) UnmarshalJSON(data []byte) error {
 				Field: f,
 			}
 			f.Elements[subKey] = subField
+		case "v:":
+			if len(f.Elements) == 0 {
+				f.Elements = make(map[FieldsV1Key]FieldsV1, len(raw))
+			}
+			subKey := FieldsV1Key{
+				Type:  FieldsV1KeySet,
+				Value: key[2:],
+			}
+			subField := FieldsV1{}
+			subField.Parent = &FieldsV1Step{
+				Key:   subKey,
+				Field: f,
+			}
+			f.Elements[subKey] = subField
 		default:
 			return fmt.Errorf("invalid key: %s", key)
 		}
@@ -211,33 +323,41 @@ func (f *FieldsV1) UnmarshalJSON(data []byte) error {
 }
 
 func (f FieldsV1Key) MarshalJSON() ([]byte, error) {
-	if f.Type == FieldsV1KeyObject {
+	if f.Type != FieldsV1KeyArray {
 		return nil, errors.New(
-			"cannot marshal key of type object (must be array)",
+			"cannot marshal key of type object or set (must be array)",
 		)
 	}
-	return json.Marshal(map[string]string{
-		f.Key: f.Value,
+	return json.Marshal(map[string]json.RawMessage{
+		f.Key: json.RawMessage(f.Value),
 	})
 }
 
 func (f *FieldsV1Key) UnmarshalJSON(data []byte) error {
-	var raw map[string]string
+	var raw map[string]json.RawMessage
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
 	for key, value := range raw {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, value); err != nil {
+			return err
+		}
 		f.Type = FieldsV1KeyArray
 		f.Key = key
-		f.Value = value
+		f.Value = buf.String()
 		return nil
 	}
 	return errors.New("empty fields key \"k:\"")
 }
 
 func (f FieldsV1Key) String() string {
-	if f.Type == FieldsV1KeyObject {
+	switch f.Type {
+	case FieldsV1KeyObject:
 		return f.Key
+	case FieldsV1KeySet:
+		return fmt.Sprintf("[%s]", f.Value)
+	default:
+		return fmt.Sprintf("{%s:%s}", f.Key, f.Value)
 	}
-	return fmt.Sprintf("{%s:%s}", f.Key, f.Value)
 }
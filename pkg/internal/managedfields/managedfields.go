@@ -5,7 +5,11 @@ import (
 	"fmt"
 )
 
-func ManagedFieldsV1(data []byte) (FieldsV1, error) {
+// ManagedFieldsV1 returns the fields in data, which are the fields a manager
+// owns when it applies data.
+// The schema defines how lists are merged, and therefore which elements of a
+// list the manager owns.
+func ManagedFieldsV1(data []byte, schema Schema) (FieldsV1, error) {
 	var raw map[string]interface{}
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return FieldsV1{}, fmt.Errorf("decoding request data: %w", err)
@@ -24,13 +28,14 @@ func ManagedFieldsV1(data []byte) (FieldsV1, error) {
 			delete(raw, "metadata")
 		}
 	}
-	return ManagedFieldsV1Object(nil, raw), nil
+	return ManagedFieldsV1Object(nil, raw, schema)
 }
 
 func ManagedFieldsV1Object(
 	parent *FieldsV1Step,
 	raw map[string]interface{},
-) FieldsV1 {
+	schema Schema,
+) (FieldsV1, error) {
 	fields := FieldsV1{
 		Parent: parent,
 		Fields: make(map[FieldsV1Key]FieldsV1),
@@ -43,9 +48,34 @@ func ManagedFieldsV1Object(
 		}
 		switch value := value.(type) {
 		case map[string]interface{}:
-			fields.Fields[key] = ManagedFieldsV1Object(&step, value)
+			subFields, err := ManagedFieldsV1Object(
+				&step,
+				value,
+				schema.Field(k),
+			)
+			if err != nil {
+				return FieldsV1{}, err
+			}
+			// If the object only has lists that the manager owns no elements
+			// of (e.g. empty sets), the manager does not own the object.
+			// Only an empty object is owned as a whole.
+			if len(value) > 0 && subFields.IsLeaf() {
+				continue
+			}
+			fields.Fields[key] = subFields
 		case []interface{}:
-			fields.Fields[key] = managedFieldsV1Array(&step, value)
+			subFields, ok, err := managedFieldsV1Array(
+				&step,
+				value,
+				schema.Field(k),
+			)
+			if err != nil {
+				return FieldsV1{}, err
+			}
+			if !ok {
+				continue
+			}
+			fields.Fields[key] = subFields
 		default:
 			fields.Fields[key] = FieldsV1{
 				Parent: &step,
@@ -53,61 +83,141 @@ func ManagedFieldsV1Object(
 		}
 	}
 
-	return fields
+	return fields, nil
 }
 
+// managedFieldsV1Array returns the fields of a list, according to the list
+// type in the schema.
+// If the list is a set or a map and is empty, the manager does not own any
+// elements of it and ok is false.
 func managedFieldsV1Array(
 	parent *FieldsV1Step,
 	raw []interface{},
-) FieldsV1 {
-	defaultFields := FieldsV1{
+	schema Schema,
+) (fields FieldsV1, ok bool, err error) {
+	atomicFields := FieldsV1{
 		Parent: parent,
 	}
+	switch schema.ListType {
+	case ListTypeAtomic:
+		return atomicFields, true, nil
+	case ListTypeSet:
+		if len(raw) == 0 {
+			return FieldsV1{}, false, nil
+		}
+		return managedFieldsV1Set(parent, raw)
+	case ListTypeMap:
+		if len(raw) == 0 {
+			return FieldsV1{}, false, nil
+		}
+		return managedFieldsV1Map(parent, raw, schema.ListMapKey, schema)
+	}
 	// If the list is empty, we *should* use the schema to know the element
 	// type. For now we can say that the manager owns the field entirely, which
 	// is bad and wrong.
 	if len(raw) == 0 {
-		return defaultFields
+		return atomicFields, true, nil
+	}
+	// Without a list type, a list of objects which all have the default key
+	// is merged as a map. Any other list is atomic.
+	for _, elem := range raw {
+		obj, ok := elem.(map[string]interface{})
+		if !ok {
+			return atomicFields, true, nil
+		}
+		if _, ok := keyValue(obj[DefaultListMapKey]); !ok {
+			return atomicFields, true, nil
+		}
 	}
+	return managedFieldsV1Map(parent, raw, DefaultListMapKey, schema)
+}
+
+func managedFieldsV1Set(
+	parent *FieldsV1Step,
+	raw []interface{},
+) (FieldsV1, bool, error) {
 	fields := FieldsV1{
 		Parent:   parent,
-		Elements: make(map[FieldsV1Key]FieldsV1),
+		Elements: make(map[FieldsV1Key]FieldsV1, len(raw)),
 	}
 	for _, elem := range raw {
-		switch elem := elem.(type) {
-		case map[string]interface{}:
-			// HACK: for now we hardcode that an object within an array must
-			// have an
-			// id field. This is the merge key.
-			idv, ok := elem["id"]
-			if !ok {
-				// If the merge key does not exist, we must say that this
-				// manager
-				// owns this field. This is bad and wrong.
-				return defaultFields
-			}
-			idStr, ok := idv.(string)
-			if !ok {
-				// If the merge key is not a string, we must say that this
-				// manager
-				// owns this field. This is bad and wrong.
-				return defaultFields
-			}
-			key := FieldsV1Key{
-				Type:  FieldsV1KeyArray,
-				Key:   "id",
-				Value: idStr,
-			}
-			step := FieldsV1Step{
+		value, ok := setValue(elem)
+		if !ok {
+			return FieldsV1{}, false, fmt.Errorf(
+				"%s: set element must be a string, number or boolean, got %T",
+				parent,
+				elem,
+			)
+		}
+		key := FieldsV1Key{
+			Type:  FieldsV1KeySet,
+			Value: value,
+		}
+		if _, ok := fields.Elements[key]; ok {
+			return FieldsV1{}, false, fmt.Errorf(
+				"%s: duplicate set element %s",
+				parent,
+				value,
+			)
+		}
+		fields.Elements[key] = FieldsV1{
+			Parent: &FieldsV1Step{
 				Key:   key,
 				Field: &fields,
-			}
-			fields.Elements[key] = ManagedFieldsV1Object(&step, elem)
-		default:
-			// For any type other than object, this manager owns the entire
-			// field.
-			return defaultFields
+			},
+		}
+	}
+	return fields, true, nil
+}
+
+func managedFieldsV1Map(
+	parent *FieldsV1Step,
+	raw []interface{},
+	mapKey string,
+	schema Schema,
+) (FieldsV1, bool, error) {
+	fields := FieldsV1{
+		Parent:   parent,
+		Elements: make(map[FieldsV1Key]FieldsV1, len(raw)),
+	}
+	for _, elem := range raw {
+		obj, ok := elem.(map[string]interface{})
+		if !ok {
+			return FieldsV1{}, false, fmt.Errorf(
+				"%s: map element must be an object, got %T",
+				parent,
+				elem,
+			)
+		}
+		value, ok := keyValue(obj[mapKey])
+		if !ok {
+			return FieldsV1{}, false, fmt.Errorf(
+				"%s: map element must have a string, number or boolean key %q",
+				parent,
+				mapKey,
+			)
+		}
+		key := FieldsV1Key{
+			Type:  FieldsV1KeyArray,
+			Key:   mapKey,
+			Value: value,
+		}
+		if _, ok := fields.Elements[key]; ok {
+			return FieldsV1{}, false, fmt.Errorf(
+				"%s: duplicate map element with key %s",
+				parent,
+				key,
+			)
+		}
+		step := FieldsV1Step{
+			Key:   key,
+			Field: &fields,
+		}
+		elemFields, err := ManagedFieldsV1Object(&step, obj, schema)
+		if err != nil {
+			return FieldsV1{}, false, err
 		}
+		fields.Elements[key] = elemFields
 	}
-	return fields
+	return fields, true, nil
 }
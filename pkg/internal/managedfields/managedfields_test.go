@@ -22,9 +22,10 @@ func fkey(k string) FieldsV1Key {
 
 func TestManagedFieldsV1(t *testing.T) {
 	type test struct {
-		name string
-		json string
-		exp  FieldsV1
+		name   string
+		json   string
+		schema Schema
+		exp    FieldsV1
 	}
 	tests := []test{
 		{
@@ -60,7 +61,7 @@ func TestManagedFieldsV1(t *testing.T) {
 							{
 								Type:  FieldsV1KeyArray,
 								Key:   "id",
-								Value: "1",
+								Value: `"1"`,
 							}: {
 								Fields: map[FieldsV1Key]FieldsV1{
 									fkey("id"):    {},
@@ -148,7 +149,7 @@ func TestManagedFieldsV1(t *testing.T) {
 									{
 										Type:  FieldsV1KeyArray,
 										Key:   "id",
-										Value: "1",
+										Value: `"1"`,
 									}: {
 										Fields: map[FieldsV1Key]FieldsV1{
 											fkey("id"):    {},
@@ -158,7 +159,7 @@ func TestManagedFieldsV1(t *testing.T) {
 									{
 										Type:  FieldsV1KeyArray,
 										Key:   "id",
-										Value: "2",
+										Value: `"2"`,
 									}: {
 										Fields: map[FieldsV1Key]FieldsV1{
 											fkey("id"):    {},
@@ -185,12 +186,167 @@ func TestManagedFieldsV1(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "atomic list",
+			json: `
+			{
+				"slice": [
+					{"id": "1", "field": "value"}
+				]
+			}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"slice": {ListType: ListTypeAtomic},
+				},
+			},
+			exp: FieldsV1{
+				Fields: map[FieldsV1Key]FieldsV1{
+					fkey("slice"): {},
+				},
+			},
+		},
+		{
+			name: "set list",
+			json: `
+			{
+				"tags": ["a", 1, true]
+			}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"tags": {ListType: ListTypeSet},
+				},
+			},
+			exp: FieldsV1{
+				Fields: map[FieldsV1Key]FieldsV1{
+					fkey("tags"): {
+						Elements: map[FieldsV1Key]FieldsV1{
+							{Type: FieldsV1KeySet, Value: `"a"`}:  {},
+							{Type: FieldsV1KeySet, Value: `1`}:    {},
+							{Type: FieldsV1KeySet, Value: `true`}: {},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "map list",
+			json: `
+			{
+				"ports": [
+					{"port": 80, "protocol": "tcp", "env": [{"name": "A"}]}
+				]
+			}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"ports": {
+						ListType:   ListTypeMap,
+						ListMapKey: "port",
+						Fields: map[string]Schema{
+							"env": {
+								ListType:   ListTypeMap,
+								ListMapKey: "name",
+							},
+						},
+					},
+				},
+			},
+			exp: FieldsV1{
+				Fields: map[FieldsV1Key]FieldsV1{
+					fkey("ports"): {
+						Elements: map[FieldsV1Key]FieldsV1{
+							{
+								Type:  FieldsV1KeyArray,
+								Key:   "port",
+								Value: `80`,
+							}: {
+								Fields: map[FieldsV1Key]FieldsV1{
+									fkey("port"):     {},
+									fkey("protocol"): {},
+									fkey("env"): {
+										Elements: map[FieldsV1Key]FieldsV1{
+											{
+												Type:  FieldsV1KeyArray,
+												Key:   "name",
+												Value: `"A"`,
+											}: {
+												Fields: map[FieldsV1Key]FieldsV1{
+													fkey("name"): {},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "empty map list",
+			json: `
+			{
+				"ports": [],
+				"name": "test"
+			}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"ports": {ListType: ListTypeMap, ListMapKey: "port"},
+				},
+			},
+			exp: FieldsV1{
+				Fields: map[FieldsV1Key]FieldsV1{
+					fkey("name"): {},
+				},
+			},
+		},
+		{
+			name: "map list keys of different types",
+			json: `
+			{
+				"slice": [
+					{"id": "1"},
+					{"id": 1}
+				]
+			}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"slice": {ListType: ListTypeMap, ListMapKey: "id"},
+				},
+			},
+			exp: FieldsV1{
+				Fields: map[FieldsV1Key]FieldsV1{
+					fkey("slice"): {
+						Elements: map[FieldsV1Key]FieldsV1{
+							{
+								Type:  FieldsV1KeyArray,
+								Key:   "id",
+								Value: `"1"`,
+							}: {
+								Fields: map[FieldsV1Key]FieldsV1{
+									fkey("id"): {},
+								},
+							},
+							{
+								Type:  FieldsV1KeyArray,
+								Key:   "id",
+								Value: `1`,
+							}: {
+								Fields: map[FieldsV1Key]FieldsV1{
+									fkey("id"): {},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			// Traverse raw and calculate managed fields.
-			fields, err := ManagedFieldsV1([]byte(tc.json))
+			fields, err := ManagedFieldsV1([]byte(tc.json), tc.schema)
 			tu.AssertNoError(t, err)
 			tu.AssertEqual(t, tc.exp, fields, cmpOptIgnoreParent)
 
@@ -222,3 +378,88 @@ func checkParent(t *testing.T, fields FieldsV1) {
 		checkParent(t, field)
 	}
 }
+
+func TestManagedFieldsV1Invalid(t *testing.T) {
+	type test struct {
+		name   string
+		json   string
+		schema Schema
+	}
+	tests := []test{
+		{
+			name: "missing map key",
+			json: `{"env": [{"value": "a"}]}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"env": {ListType: ListTypeMap, ListMapKey: "name"},
+				},
+			},
+		},
+		{
+			name: "duplicate map key",
+			json: `{"env": [{"name": "a"}, {"name": "a"}]}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"env": {ListType: ListTypeMap, ListMapKey: "name"},
+				},
+			},
+		},
+		{
+			name: "object in set",
+			json: `{"tags": [{"name": "a"}]}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"tags": {ListType: ListTypeSet},
+				},
+			},
+		},
+		{
+			name: "duplicate set element",
+			json: `{"tags": ["a", "a"]}`,
+			schema: Schema{
+				Fields: map[string]Schema{
+					"tags": {ListType: ListTypeSet},
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ManagedFieldsV1([]byte(tc.json), tc.schema)
+			tu.AssertTrue(t, err != nil, "expected error")
+		})
+	}
+}
+
+func TestFieldsV1Paths(t *testing.T) {
+	data := `{
+		"spec": {
+			"text": "a",
+			"env": [{"name": "A", "value": "a"}],
+			"tags": ["x"]
+		}
+	}`
+	schema := Schema{
+		Fields: map[string]Schema{
+			"spec": {
+				Fields: map[string]Schema{
+					"env":  {ListType: ListTypeMap, ListMapKey: "name"},
+					"tags": {ListType: ListTypeSet},
+				},
+			},
+		},
+	}
+	fields, err := ManagedFieldsV1([]byte(data), schema)
+	tu.AssertNoError(t, err)
+	paths := fields.Paths()
+	act := make([]string, len(paths))
+	for i, path := range paths {
+		act[i] = path.String()
+	}
+	tu.AssertEqual(t, []string{
+		"spec.env[name=\"A\"].name",
+		"spec.env[name=\"A\"].value",
+		"spec.tags[\"x\"]",
+		"spec.text",
+	}, act)
+}
@@ -10,13 +10,20 @@ var _ (error) = (*Conflict)(nil)
 
 type Conflict struct {
 	// Fields is a list of fields that are in conflict.
-	Fields []FieldsV1
+	Fields []ConflictField
+}
+
+// ConflictField is a field that is in conflict, and the manager that
+// currently owns it.
+type ConflictField struct {
+	Manager string
+	Field   FieldsV1
 }
 
 func (c *Conflict) Error() string {
 	conflicts := make([]string, len(c.Fields))
 	for i, f := range c.Fields {
-		conflicts[i] = f.Path().String()
+		conflicts[i] = f.Field.Path().String()
 	}
 	return fmt.Sprintf(
 		"conflicting fields: [%s]",
@@ -39,6 +46,7 @@ func MergeManagedFields(
 		}
 		newFields := conflictOrForceOverrideFields(
 			&conflicts,
+			mgrs.Manager,
 			mgrs.FieldsV1,
 			reqFM.FieldsV1,
 			force,
@@ -69,6 +77,7 @@ func MergeManagedFields(
 	index := fieldsIndex(managedFields, reqFM)
 	if index >= 0 {
 		fieldsDiff(&mr, managedFields[index].FieldsV1, reqFM.FieldsV1)
+		mr.Removed = unsharedFields(mr.Removed, managedFields, reqFM.Manager)
 		// Overwrite the existing field manager.
 		managedFields[index] = reqFM
 	} else {
@@ -88,6 +97,7 @@ type MergeResult struct {
 
 func conflictOrForceOverrideFields(
 	conflicts *Conflict,
+	manager string,
 	old FieldsV1,
 	req FieldsV1,
 	force bool,
@@ -101,15 +111,19 @@ func conflictOrForceOverrideFields(
 		if subField, ok := old.Fields[key]; ok {
 			if value.IsLeaf() {
 				if !force {
-					conflicts.Fields = append(conflicts.Fields, value)
-					break
+					conflicts.Fields = append(
+						conflicts.Fields,
+						ConflictField{Manager: manager, Field: value},
+					)
+					continue
 				}
 				// If force is true, remove ownership of the field from old.
 				delete(old.Fields, key)
-				break
+				continue
 			}
 			subField = conflictOrForceOverrideFields(
 				conflicts,
+				manager,
 				subField,
 				value,
 				force,
@@ -124,41 +138,113 @@ func conflictOrForceOverrideFields(
 		}
 	}
 	// Same as for above but with elements (arrays).
+	// Elements are identified by their value (sets) or by the value of their
+	// key field (maps). Hence, managers that apply the same element share
+	// ownership of the element and its key field, instead of conflicting.
 	for key, value := range req.Elements {
-		if subField, ok := old.Elements[key]; ok {
-			if value.IsLeaf() {
-				if !force {
-					conflicts.Fields = append(conflicts.Fields, value)
-					break
-				}
-				// If force is true, remove ownership of the field from old.
-				delete(old.Elements, key)
-				break
-			}
-			subField = conflictOrForceOverrideFields(
-				conflicts,
-				subField,
-				value,
-				force,
-			)
-			// After traversing subField, if it is not a leaf (i.e. it has no
-			// fields or elements) we want to remove it altogether.
-			if subField.IsLeaf() {
-				delete(old.Fields, key)
+		subField, ok := old.Elements[key]
+		if !ok || key.Type == FieldsV1KeySet {
+			continue
+		}
+		if value.IsLeaf() {
+			if !force {
+				conflicts.Fields = append(
+					conflicts.Fields,
+					ConflictField{Manager: manager, Field: value},
+				)
 				continue
 			}
-			old.Elements[key] = subField
+			// If force is true, remove ownership of the field from old.
+			delete(old.Elements, key)
+			continue
 		}
+		value = withoutField(value, key.Key)
+		if value.IsLeaf() {
+			continue
+		}
+		subField = conflictOrForceOverrideFields(
+			conflicts,
+			manager,
+			subField,
+			value,
+			force,
+		)
+		// After traversing subField, if it is not a leaf (i.e. it has no
+		// fields or elements) we want to remove it altogether.
+		if subField.IsLeaf() {
+			delete(old.Elements, key)
+			continue
+		}
+		old.Elements[key] = subField
 	}
 	return old
 }
 
+// withoutField returns a copy of the fields without the field with the given
+// key.
+func withoutField(fields FieldsV1, key string) FieldsV1 {
+	objKey := FieldsV1Key{Key: key}
+	if _, ok := fields.Fields[objKey]; !ok {
+		return fields
+	}
+	newFields := make(map[FieldsV1Key]FieldsV1, len(fields.Fields))
+	for k, v := range fields.Fields {
+		if k != objKey {
+			newFields[k] = v
+		}
+	}
+	fields.Fields = newFields
+	return fields
+}
+
+// unsharedFields returns the removed fields, excluding those that are still
+// owned by a manager other than the given manager.
+// Fields can be owned by multiple managers (e.g. elements of a set, or the key
+// of an element in a map list), and must not be purged from the object whilst
+// another manager owns them.
+func unsharedFields(
+	removed []FieldsV1,
+	managedFields []FieldManager,
+	manager string,
+) []FieldsV1 {
+	var unshared []FieldsV1
+	var appendUnshared func(field FieldsV1)
+	appendUnshared = func(field FieldsV1) {
+		path := field.Path()
+		shared := false
+		for _, mgr := range managedFields {
+			if mgr.Manager == manager {
+				continue
+			}
+			if _, ok := mgr.FieldsV1.Lookup(path); ok {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			unshared = append(unshared, field)
+			return
+		}
+		// Another manager owns this field, but maybe not all of its children.
+		for _, subField := range field.Fields {
+			appendUnshared(subField)
+		}
+		for _, subField := range field.Elements {
+			appendUnshared(subField)
+		}
+	}
+	for _, field := range removed {
+		appendUnshared(field)
+	}
+	return unshared
+}
+
 func fieldsDiff(mr *MergeResult, oldFields, newFields FieldsV1) {
 	// Check diff in fields (objects).
 	for oldKey, oldValue := range oldFields.Fields {
 		newValue, ok := newFields.Fields[oldKey]
 		if !ok {
-			mr.Removed = append(mr.Removed, oldValue)
+			mr.Removed = appendLeaves(mr.Removed, oldValue)
 			continue
 		}
 		fieldsDiff(mr, oldValue, newValue)
@@ -174,6 +260,25 @@ func fieldsDiff(mr *MergeResult, oldFields, newFields FieldsV1) {
 	}
 }
 
+// appendLeaves appends the fields owned by the removed field to removed:
+// its leaf fields, and the list elements it contains (which are removed as a
+// whole).
+// The objects and lists containing them are not owned by the manager, so
+// removing them must not remove their other fields (e.g. removing the last
+// finalizer owned by a manager must not remove the metadata).
+func appendLeaves(removed []FieldsV1, field FieldsV1) []FieldsV1 {
+	if field.IsLeaf() {
+		return append(removed, field)
+	}
+	for _, subField := range field.Fields {
+		removed = appendLeaves(removed, subField)
+	}
+	for _, subField := range field.Elements {
+		removed = append(removed, subField)
+	}
+	return removed
+}
+
 func PurgeRemovedFields(
 	obj map[string]interface{},
 	removed []FieldsV1,
@@ -334,17 +439,26 @@ func mergeArray(
 	src []interface{},
 	fields FieldsV1,
 ) []interface{} {
-	for key, subFields := range fields.Elements {
+	// Iterate over src (instead of the fields) so that new elements are
+	// appended in the order they were applied.
+	for _, srcElem := range src {
+		key, subFields, ok := fields.element(srcElem)
+		if !ok {
+			continue
+		}
 		dstIndex := FindIndexArrayByKey(dst, key)
-		srcIndex := FindIndexArrayByKey(src, key)
 		// If the field is not found in dst, we can just append the value from
 		// src to dst.
 		if dstIndex == -1 {
-			dst = append(dst, src[srcIndex])
+			dst = append(dst, srcElem)
+			continue
+		}
+		// Set elements are equal if they are found.
+		if key.Type == FieldsV1KeySet {
 			continue
 		}
 		dstObj := dst[dstIndex].(map[string]interface{})
-		srcObj := src[srcIndex].(map[string]interface{})
+		srcObj := srcElem.(map[string]interface{})
 		mergeObjects(dstObj, srcObj, subFields)
 		dst[dstIndex] = dstObj
 	}
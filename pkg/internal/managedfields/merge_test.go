@@ -14,7 +14,7 @@ func TestMergeManagedFields(t *testing.T) {
 		merge            string
 		force            bool
 		expManagedFields string
-		expConflict      func(FieldsV1) []FieldsV1
+		expConflict      func(FieldsV1) []ConflictField
 		expRemoved       func([]FieldManager) []FieldsV1
 	}
 	tests := []test{
@@ -66,7 +66,7 @@ func TestMergeManagedFields(t *testing.T) {
 					}
 				}
 			]`,
-			expConflict: func(fields FieldsV1) []FieldsV1 { return nil },
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
 			expRemoved:  func(fms []FieldManager) []FieldsV1 { return nil },
 		},
 		{
@@ -107,7 +107,7 @@ func TestMergeManagedFields(t *testing.T) {
 					}
 				}
 			]`,
-			expConflict: func(fields FieldsV1) []FieldsV1 { return nil },
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
 			expRemoved:  func(fms []FieldManager) []FieldsV1 { return nil },
 		},
 		{
@@ -143,10 +143,12 @@ func TestMergeManagedFields(t *testing.T) {
 					}
 				}
 			]`,
-			expConflict: func(fields FieldsV1) []FieldsV1 { return nil },
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
 			expRemoved: func(fms []FieldManager) []FieldsV1 {
 				return []FieldsV1{
-					fms[0].FieldsV1.Fields[fkey("metadata")].Fields[fkey("labels")],
+					fms[0].FieldsV1.Fields[fkey("metadata")].
+						Fields[fkey("labels")].
+						Fields[fkey("app")],
 				}
 			},
 		},
@@ -181,13 +183,13 @@ func TestMergeManagedFields(t *testing.T) {
 					}
 				}
 			]`,
-			expConflict: func(fields FieldsV1) []FieldsV1 { return nil },
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
 			expRemoved: func(fms []FieldManager) []FieldsV1 {
 				return []FieldsV1{
 					fms[0].FieldsV1.Fields[fkey("slice")].Elements[FieldsV1Key{
 						Type:  FieldsV1KeyArray,
 						Key:   "id",
-						Value: "2",
+						Value: `"2"`,
 					}],
 				}
 			},
@@ -212,9 +214,12 @@ func TestMergeManagedFields(t *testing.T) {
 					}
 				}
 			}`,
-			expConflict: func(fields FieldsV1) []FieldsV1 {
-				return []FieldsV1{
-					fields.Fields[fkey("metadata")].Fields[fkey("name")],
+			expConflict: func(fields FieldsV1) []ConflictField {
+				return []ConflictField{
+					{
+						Manager: "m1",
+						Field:   fields.Fields[fkey("metadata")].Fields[fkey("name")],
+					},
 				}
 			},
 			expRemoved: func(fms []FieldManager) []FieldsV1 { return nil },
@@ -250,7 +255,7 @@ func TestMergeManagedFields(t *testing.T) {
 				}
 			]`,
 			force:       true,
-			expConflict: func(fields FieldsV1) []FieldsV1 { return nil },
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
 			expRemoved:  func(fms []FieldManager) []FieldsV1 { return nil },
 		},
 		{
@@ -273,16 +278,178 @@ func TestMergeManagedFields(t *testing.T) {
 					}
 				}
 			}`,
-			expConflict: func(fields FieldsV1) []FieldsV1 {
+			expConflict: func(fields FieldsV1) []ConflictField {
+				return []ConflictField{
+					{
+						Manager: "m1",
+						Field: fields.Fields[fkey("slice")].Elements[FieldsV1Key{
+							Type:  FieldsV1KeyArray,
+							Key:   "id",
+							Value: `"1"`,
+						}],
+					},
+				}
+			},
+			expRemoved: func(fms []FieldManager) []FieldsV1 { return nil },
+		},
+		{
+			name: "shared set element",
+			managedFields: `[
+				{
+					"manager": "m1",
+					"fieldsV1": {
+						"f:tags": {
+							"v:\"a\"": {}
+						}
+					}
+				}
+			]`,
+			merge: `{
+				"manager": "m2",
+				"fieldsV1": {
+					"f:tags": {
+						"v:\"a\"": {},
+						"v:\"b\"": {}
+					}
+				}
+			}`,
+			expManagedFields: `[
+				{
+					"manager": "m1",
+					"fieldsV1": {
+						"f:tags": {
+							"v:\"a\"": {}
+						}
+					}
+				},
+				{
+					"manager": "m2",
+					"fieldsV1": {
+						"f:tags": {
+							"v:\"a\"": {},
+							"v:\"b\"": {}
+						}
+					}
+				}
+			]`,
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
+			expRemoved:  func(fms []FieldManager) []FieldsV1 { return nil },
+		},
+		{
+			name: "shared map element",
+			managedFields: `[
+				{
+					"manager": "m1",
+					"fieldsV1": {
+						"f:env": {
+							"k:{\"name\":\"A\"}": {
+								"f:name": {},
+								"f:value": {}
+							}
+						}
+					}
+				}
+			]`,
+			merge: `{
+				"manager": "m2",
+				"fieldsV1": {
+					"f:env": {
+						"k:{\"name\":\"A\"}": {
+							"f:name": {},
+							"f:description": {}
+						}
+					}
+				}
+			}`,
+			expManagedFields: `[
+				{
+					"manager": "m1",
+					"fieldsV1": {
+						"f:env": {
+							"k:{\"name\":\"A\"}": {
+								"f:name": {},
+								"f:value": {}
+							}
+						}
+					}
+				},
+				{
+					"manager": "m2",
+					"fieldsV1": {
+						"f:env": {
+							"k:{\"name\":\"A\"}": {
+								"f:name": {},
+								"f:description": {}
+							}
+						}
+					}
+				}
+			]`,
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
+			expRemoved:  func(fms []FieldManager) []FieldsV1 { return nil },
+		},
+		{
+			name: "removed shared map element",
+			managedFields: `[
+				{
+					"manager": "m1",
+					"fieldsV1": {
+						"f:env": {
+							"k:{\"name\":\"A\"}": {
+								"f:name": {},
+								"f:value": {}
+							}
+						}
+					}
+				},
+				{
+					"manager": "m2",
+					"fieldsV1": {
+						"f:env": {
+							"k:{\"name\":\"A\"}": {
+								"f:name": {},
+								"f:description": {}
+							}
+						}
+					}
+				}
+			]`,
+			merge: `{
+				"manager": "m2",
+				"fieldsV1": {
+					"f:other": {}
+				}
+			}`,
+			expManagedFields: `[
+				{
+					"manager": "m1",
+					"fieldsV1": {
+						"f:env": {
+							"k:{\"name\":\"A\"}": {
+								"f:name": {},
+								"f:value": {}
+							}
+						}
+					}
+				},
+				{
+					"manager": "m2",
+					"fieldsV1": {
+						"f:other": {}
+					}
+				}
+			]`,
+			expConflict: func(fields FieldsV1) []ConflictField { return nil },
+			expRemoved: func(fms []FieldManager) []FieldsV1 {
+				// Only the field that m1 does not also own is removed.
 				return []FieldsV1{
-					fields.Fields[fkey("slice")].Elements[FieldsV1Key{
+					fms[1].FieldsV1.Fields[fkey("env")].Elements[FieldsV1Key{
 						Type:  FieldsV1KeyArray,
-						Key:   "id",
-						Value: "1",
-					}],
+						Key:   "name",
+						Value: `"A"`,
+					}].Fields[fkey("description")],
 				}
 			},
-			expRemoved: func(fms []FieldManager) []FieldsV1 { return nil },
 		},
 	}
 	for _, tc := range tests {
@@ -523,6 +690,52 @@ func TestMergeObjects(t *testing.T) {
 				}
 			}`,
 		},
+		{
+			name: "set",
+			dst: `{
+				"tags": ["a", "b"]
+			}`,
+			src: `{
+				"tags": ["c", "b", "d"]
+			}`,
+			fields: `{
+				"f:tags": {
+					"v:\"b\"": {},
+					"v:\"c\"": {},
+					"v:\"d\"": {}
+				}
+			}`,
+			exp: `{
+				"tags": ["a", "b", "c", "d"]
+			}`,
+		},
+		{
+			name: "map order",
+			dst: `{
+				"env": [{"name": "A", "value": "1"}]
+			}`,
+			src: `{
+				"env": [
+					{"name": "C", "value": "3"},
+					{"name": "A", "value": "2"},
+					{"name": "B", "value": "4"}
+				]
+			}`,
+			fields: `{
+				"f:env": {
+					"k:{\"name\":\"A\"}": {"f:name": {}, "f:value": {}},
+					"k:{\"name\":\"B\"}": {"f:name": {}, "f:value": {}},
+					"k:{\"name\":\"C\"}": {"f:name": {}, "f:value": {}}
+				}
+			}`,
+			exp: `{
+				"env": [
+					{"name": "A", "value": "2"},
+					{"name": "C", "value": "3"},
+					{"name": "B", "value": "4"}
+				]
+			}`,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -546,6 +759,7 @@ func TestPurgeRemoveFields(t *testing.T) {
 	type test struct {
 		name    string
 		obj     map[string]interface{}
+		schema  Schema
 		exp     map[string]interface{}
 		removed func(FieldsV1) []FieldsV1
 	}
@@ -584,7 +798,7 @@ func TestPurgeRemoveFields(t *testing.T) {
 					fields.Fields[fkey("slice")].Elements[FieldsV1Key{
 						Type:  FieldsV1KeyArray,
 						Key:   "id",
-						Value: "1",
+						Value: `"1"`,
 					}],
 				}
 			},
@@ -635,7 +849,29 @@ func TestPurgeRemoveFields(t *testing.T) {
 					fields.Fields[fkey("spec")].Fields[fkey("objslice")].Elements[FieldsV1Key{
 						Type:  FieldsV1KeyArray,
 						Key:   "id",
-						Value: "1",
+						Value: `"1"`,
+					}],
+				}
+			},
+		},
+		{
+			name: "set",
+			obj: map[string]interface{}{
+				"tags": []interface{}{"a", "b", "c"},
+			},
+			schema: Schema{
+				Fields: map[string]Schema{
+					"tags": {ListType: ListTypeSet},
+				},
+			},
+			exp: map[string]interface{}{
+				"tags": []interface{}{"a", "c"},
+			},
+			removed: func(fields FieldsV1) []FieldsV1 {
+				return []FieldsV1{
+					fields.Fields[fkey("tags")].Elements[FieldsV1Key{
+						Type:  FieldsV1KeySet,
+						Value: `"b"`,
 					}],
 				}
 			},
@@ -644,9 +880,10 @@ func TestPurgeRemoveFields(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			fields := ManagedFieldsV1Object(nil, tc.obj)
+			fields, err := ManagedFieldsV1Object(nil, tc.obj, tc.schema)
+			tu.AssertNoError(t, err)
 			removed := tc.removed(fields)
-			err := PurgeRemovedFields(tc.obj, removed)
+			err = PurgeRemovedFields(tc.obj, removed)
 			tu.AssertNoError(t, err)
 			tu.AssertEqual(t, tc.exp, tc.obj)
 		})
@@ -0,0 +1,92 @@
+package managedfields
+
+import "encoding/json"
+
+// ListType defines how a list is merged when it is applied by multiple field
+// managers.
+type ListType string
+
+const (
+	// ListTypeAtomic lists are owned entirely by a single manager, and are
+	// replaced as a whole when applied.
+	ListTypeAtomic ListType = "atomic"
+	// ListTypeSet lists contain unique scalar values.
+	// Each value can be owned by different managers.
+	ListTypeSet ListType = "set"
+	// ListTypeMap lists contain objects that are identified by the value of
+	// a key field (the list map key).
+	// The fields of each object can be owned by different managers.
+	ListTypeMap ListType = "map"
+)
+
+// DefaultListMapKey is the key used to identify objects in lists that do not
+// have a list type.
+// If all the objects in such a list have this key, the list is merged as a
+// map, otherwise it is atomic.
+const DefaultListMapKey = "id"
+
+// Schema describes how the lists in an object are merged.
+// The zero value is valid, and uses the default behaviour for all lists.
+type Schema struct {
+	// ListType is the type of the list, if the field is a list.
+	ListType ListType `json:"listType,omitempty"`
+	// ListMapKey is the key that identifies the objects in a list of type
+	// [ListTypeMap].
+	ListMapKey string `json:"listMapKey,omitempty"`
+	// Fields contains the schema of the fields of an object, or of the
+	// objects in a list.
+	Fields map[string]Schema `json:"fields,omitempty"`
+}
+
+// Field returns the schema for the field with the given name.
+func (s Schema) Field(name string) Schema {
+	return s.Fields[name]
+}
+
+// IsZero returns true if the schema uses the default behaviour for all lists.
+func (s Schema) IsZero() bool {
+	return s.ListType == "" && s.ListMapKey == "" && len(s.Fields) == 0
+}
+
+// keyValue returns the value of a list map key, which is its JSON encoding
+// (like the elements of a set), so that keys of different types (e.g. "1"
+// and 1) are not equal.
+// Only scalar values can be used as keys.
+func keyValue(value interface{}) (string, bool) {
+	return setValue(value)
+}
+
+// setValue returns the value of an element in a list of type [ListTypeSet]
+// as a string.
+// It is the JSON encoding of the value, so that values of different types
+// (e.g. "1" and 1) are not equal.
+func setValue(value interface{}) (string, bool) {
+	switch value.(type) {
+	case string, float64, bool:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	default:
+		return "", false
+	}
+}
+
+// matchesKey returns true if the list element is identified by the key.
+func matchesKey(elem interface{}, key FieldsV1Key) bool {
+	switch key.Type {
+	case FieldsV1KeySet:
+		value, ok := setValue(elem)
+		return ok && value == key.Value
+	case FieldsV1KeyArray:
+		obj, ok := elem.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		value, ok := keyValue(obj[key.Key])
+		return ok && value == key.Value
+	default:
+		return false
+	}
+}
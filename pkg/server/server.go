@@ -5,9 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/broker"
 	"github.com/verifa/horizon/pkg/extensions/core"
@@ -15,6 +19,10 @@ import (
 	"github.com/verifa/horizon/pkg/hz"
 	"github.com/verifa/horizon/pkg/natsutil"
 	"github.com/verifa/horizon/pkg/store"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 )
 
 func WithDevMode() ServerOption {
@@ -30,6 +38,7 @@ func WithDevMode() ServerOption {
 		o.runSecretsController = true
 		o.runNamespaceController = true
 		o.runPortalController = true
+		o.runEventController = true
 	}
 }
 
@@ -97,6 +106,25 @@ func WithGatewayOptions(opts ...gateway.ServerOption) ServerOption {
 	}
 }
 
+// WithMetricsAddr starts an HTTP listener on addr that serves Prometheus
+// metrics on "/metrics".
+// This is useful when the gateway is not running, or to avoid exposing metrics
+// publicly.
+func WithMetricsAddr(addr string) ServerOption {
+	return func(o *serverOptions) {
+		o.metricsAddr = addr
+	}
+}
+
+// WithTraceExporter enables tracing, exporting spans with the given exporter.
+// The tracer provider is registered globally, so that spans from controllers
+// and actors running in the same process are exported too.
+func WithTraceExporter(exporter sdktrace.SpanExporter) ServerOption {
+	return func(o *serverOptions) {
+		o.traceExporter = exporter
+	}
+}
+
 type ServerOption func(*serverOptions)
 
 type serverOptions struct {
@@ -113,6 +141,10 @@ type serverOptions struct {
 	runSecretsController   bool
 	runNamespaceController bool
 	runPortalController    bool
+	runEventController     bool
+
+	metricsAddr   string
+	traceExporter sdktrace.SpanExporter
 
 	natsOptions                []natsutil.ServerOption
 	authOptions                []auth.Option
@@ -133,6 +165,10 @@ type Server struct {
 	CtlrSecrets    *hz.Controller
 	CtlrNamespaces *hz.Controller
 	CltrPortals    *hz.Controller
+	CtlrEvents     *hz.Controller
+
+	Metrics        *http.Server
+	TracerProvider *sdktrace.TracerProvider
 }
 
 func Start(
@@ -152,6 +188,15 @@ func (s *Server) Start(ctx context.Context, opts ...ServerOption) error {
 	for _, o := range opts {
 		o(&opt)
 	}
+	if opt.traceExporter != nil {
+		s.TracerProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(opt.traceExporter),
+			sdktrace.WithResource(resource.NewSchemaless(
+				semconv.ServiceName("horizon"),
+			)),
+		)
+		otel.SetTracerProvider(s.TracerProvider)
+	}
 	if opt.conn != nil {
 		s.Conn = opt.conn
 	}
@@ -256,6 +301,24 @@ func (s *Server) Start(ctx context.Context, opts ...ServerOption) error {
 		}
 		s.CltrPortals = ctlr
 	}
+	if opt.runEventController {
+		ctlr, err := hz.StartController(
+			ctx,
+			s.Conn,
+			hz.WithControllerFor(core.Event{}),
+			hz.WithControllerReconciler(&core.EventReconciler{
+				Client: hz.NewClient(
+					s.Conn,
+					hz.WithClientInternal(true),
+					hz.WithClientManager("ctlr-events"),
+				),
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("starting events controller: %w", err)
+		}
+		s.CtlrEvents = ctlr
+	}
 
 	// Check that the root namespace exists as an object.
 	// This is a little bit fidgety, because the root account *will* exist in
@@ -269,6 +332,12 @@ func (s *Server) Start(ctx context.Context, opts ...ServerOption) error {
 		return fmt.Errorf("checking root namespace object: %w", err)
 	}
 
+	if opt.metricsAddr != "" {
+		if err := s.startMetrics(ctx, opt.metricsAddr); err != nil {
+			return fmt.Errorf("starting metrics: %w", err)
+		}
+	}
+
 	if opt.devMode {
 		userConfig, err := jwt.FormatUserConfig(
 			s.NS.Auth.RootUser.JWT,
@@ -302,8 +371,45 @@ func (s *Server) Start(ctx context.Context, opts ...ServerOption) error {
 	return nil
 }
 
+// startMetrics serves Prometheus metrics on addr.
+func (s *Server) startMetrics(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := http.Server{
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+		ReadHeaderTimeout: 2 * time.Second,
+		Handler:           mux,
+	}
+	s.Metrics = &srv
+	go func() {
+		if err := srv.Serve(l); err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("metrics server", "error", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
 func (s *Server) Close() error {
 	var errs error
+	if s.Metrics != nil {
+		if err := s.Metrics.Shutdown(context.TODO()); err != nil {
+			errs = errors.Join(
+				errs,
+				fmt.Errorf("shutting down metrics server: %w", err),
+			)
+		}
+	}
+	if s.CtlrEvents != nil {
+		if err := s.CtlrEvents.Stop(); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
 	if s.CltrPortals != nil {
 		if err := s.CltrPortals.Stop(); err != nil {
 			errs = errors.Join(errs, err)
@@ -339,6 +445,15 @@ func (s *Server) Close() error {
 			errs = errors.Join(errs, err)
 		}
 	}
+	if s.TracerProvider != nil {
+		// Shutdown flushes any spans that have not been exported yet.
+		if err := s.TracerProvider.Shutdown(context.TODO()); err != nil {
+			errs = errors.Join(
+				errs,
+				fmt.Errorf("shutting down tracer provider: %w", err),
+			)
+		}
+	}
 	return errs
 }
 
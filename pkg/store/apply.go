@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/verifa/horizon/pkg/hz"
 	"github.com/verifa/horizon/pkg/internal/managedfields"
 )
@@ -34,8 +38,23 @@ func (s *Store) Apply(ctx context.Context, req ApplyRequest) (int, error) {
 	// If apply is a create, it will get validated.
 	// If apply is a patch, validate the merged result.
 
-	// Create managed fields for the request data.
-	fieldsV1, err := managedfields.ManagedFieldsV1(req.Data)
+	// Record the API version that the manager used, before the request is
+	// converted to the stored version.
+	apiVersion := req.Key.ObjectGroup() + "/" + req.Key.ObjectVersion()
+	// Get the existing object (if it exists) and make sure the request is for
+	// the version of the kind that the object is stored under.
+	req, rawObj, err := s.resolveApplyVersion(ctx, req)
+	if err != nil {
+		return -1, err
+	}
+
+	// Create managed fields for the request data, merging lists according to
+	// the schema of the kind.
+	schema, err := s.mergeSchema(ctx, req.Key)
+	if err != nil {
+		return -1, err
+	}
+	fieldsV1, err := managedfields.ManagedFieldsV1(req.Data, schema)
 	if err != nil {
 		return -1, &hz.Error{
 			Status: http.StatusBadRequest,
@@ -45,19 +64,23 @@ func (s *Store) Apply(ctx context.Context, req ApplyRequest) (int, error) {
 			),
 		}
 	}
+	operation := managedfields.OperationApply
+	if req.IsCreate {
+		operation = managedfields.OperationUpdate
+	}
+	now := time.Now().UTC().Truncate(time.Second)
 	fieldManager := managedfields.FieldManager{
 		Manager:    req.Manager,
 		FieldsV1:   fieldsV1,
 		FieldsType: managedfields.FieldsTypeV1,
+		Operation:  operation,
+		APIVersion: apiVersion,
+		Time:       &now,
 	}
 
-	// Get the existing object (if it exists).
-	// If not, add the managed fields to the request and create the object.
-	rawObj, err := s.get(ctx, req.Key)
-	if err != nil {
-		if !errors.Is(err, hz.ErrNotFound) {
-			return -1, err
-		}
+	// If the object does not exist, add the managed fields to the request and
+	// create the object.
+	if rawObj == nil {
 		var generic hz.GenericObject
 		if err := json.Unmarshal(req.Data, &generic); err != nil {
 			return -1, &hz.Error{
@@ -113,6 +136,14 @@ func (s *Store) Apply(ctx context.Context, req ApplyRequest) (int, error) {
 			),
 		}
 	}
+	// Keep the time, operation and API version of the manager's last change
+	// until we know whether this apply changes the object, so that a no-op
+	// apply remains a no-op (e.g. when applied using another version).
+	if existing, ok := generic.ManagedFields.FieldManager(req.Manager); ok {
+		fieldManager.Time = existing.Time
+		fieldManager.Operation = existing.Operation
+		fieldManager.APIVersion = existing.APIVersion
+	}
 	// Merge managed fields and detect any conflicts.
 	result, err := managedfields.MergeManagedFields(
 		generic.ManagedFields,
@@ -136,6 +167,9 @@ func (s *Store) Apply(ctx context.Context, req ApplyRequest) (int, error) {
 				"conflict: %s",
 				err.Error(),
 			),
+			Details: &hz.ErrorDetails{
+				Conflicts: applyConflicts(conflictErr),
+			},
 		}
 	}
 
@@ -199,6 +233,33 @@ func (s *Store) Apply(ctx context.Context, req ApplyRequest) (int, error) {
 	if isJSONEqual(rawObj, bDst) {
 		return http.StatusNotModified, nil
 	}
+	// The object has changed, so record the time, operation and API version
+	// of the change for the manager.
+	if err := setManagerChange(
+		dst,
+		req.Manager,
+		operation,
+		apiVersion,
+		now,
+	); err != nil {
+		return -1, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"setting field manager change: %s",
+				err.Error(),
+			),
+		}
+	}
+	bDst, err = json.Marshal(dst)
+	if err != nil {
+		return -1, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"encoding merged object: %s",
+				err.Error(),
+			),
+		}
+	}
 	if err := s.Update(ctx, UpdateRequest{
 		Data:     bDst,
 		Key:      req.Key,
@@ -224,6 +285,127 @@ func (s *Store) Apply(ctx context.Context, req ApplyRequest) (int, error) {
 	return http.StatusOK, nil
 }
 
+// applyConflicts returns the conflicting fields and the managers that own
+// them, sorted by field.
+func applyConflicts(conflict *managedfields.Conflict) []hz.ApplyConflict {
+	conflicts := make([]hz.ApplyConflict, len(conflict.Fields))
+	for i, field := range conflict.Fields {
+		conflicts[i] = hz.ApplyConflict{
+			Field:   field.Field.Path().String(),
+			Manager: field.Manager,
+		}
+	}
+	slices.SortFunc(conflicts, func(a, b hz.ApplyConflict) int {
+		if c := strings.Compare(a.Field, b.Field); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Manager, b.Manager)
+	})
+	return conflicts
+}
+
+// setManagerChange sets the operation, API version and time of the manager's
+// change in the managed fields of the decoded object.
+func setManagerChange(
+	obj map[string]interface{},
+	manager string,
+	operation managedfields.Operation,
+	apiVersion string,
+	t time.Time,
+) error {
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		return errors.New("missing metadata")
+	}
+	managedFields, ok := metadata["managedFields"].([]interface{})
+	if !ok {
+		return errors.New("missing managed fields")
+	}
+	for _, mf := range managedFields {
+		fm, ok := mf.(map[string]interface{})
+		if !ok {
+			return errors.New("invalid field manager")
+		}
+		if fm["manager"] == manager {
+			fm["operation"] = string(operation)
+			fm["apiVersion"] = apiVersion
+			fm["time"] = t.Format(time.RFC3339)
+			return nil
+		}
+	}
+	return fmt.Errorf("field manager %q not found", manager)
+}
+
+// mergeSchema returns the schema for merging the lists of the key's kind and
+// version.
+// If the kind has no schema (e.g. no controller has been started for it), the
+// default schema is returned.
+func (s *Store) mergeSchema(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+) (managedfields.Schema, error) {
+	entry, err := s.schemas.Get(ctx, hz.MergeSchemaKey(key))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return managedfields.Schema{}, nil
+		}
+		return managedfields.Schema{}, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"getting merge schema: %s",
+				err.Error(),
+			),
+		}
+	}
+	var schema managedfields.Schema
+	if err := json.Unmarshal(entry.Value(), &schema); err != nil {
+		return managedfields.Schema{}, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"unmarshalling merge schema: %s",
+				err.Error(),
+			),
+		}
+	}
+	return schema, nil
+}
+
+// resolveApplyVersion returns the existing object for the apply request, or nil
+// if it does not exist.
+//
+// Kinds can be served in multiple versions, and the request is converted to
+// the version that the existing object is stored under.
+// If the object does not exist, the request is converted to the storage
+// version of the kind.
+func (s *Store) resolveApplyVersion(
+	ctx context.Context,
+	req ApplyRequest,
+) (ApplyRequest, []byte, error) {
+	storedKey, rawObj, err := s.locate(ctx, req.Key)
+	if err != nil {
+		if !errors.Is(err, hz.ErrNotFound) {
+			return req, nil, err
+		}
+		storedKey, err = s.storageKey(ctx, req.Key)
+		if err != nil {
+			return req, nil, err
+		}
+	}
+	if storedKey.Version != req.Key.ObjectVersion() {
+		data, err := s.convert(ctx, storedKey, req.Data, storedKey.Version)
+		if err != nil {
+			return req, nil, hz.ErrorWrap(
+				err,
+				http.StatusInternalServerError,
+				fmt.Sprintf("converting to version %q", storedKey.Version),
+			)
+		}
+		req.Data = data
+		req.Key = storedKey
+	}
+	return req, rawObj, nil
+}
+
 // isJSONEqual returns true if the JSON objects are "equal".
 // Equal means a field by field comparison, not a byte-by-byte comparison.
 func isJSONEqual(a, b []byte) bool {
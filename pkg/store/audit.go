@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/verifa/horizon/pkg/auth"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+// audit records a mutating API request in the audit log.
+// Read requests are not recorded.
+//
+// existing is the object before the request (if it existed), which is
+// compared with the object after the request to summarise the changes.
+func (s *Store) audit(
+	ctx context.Context,
+	user auth.UserInfo,
+	verb auth.Verb,
+	key hz.ObjectKey,
+	existing []byte,
+	status int,
+	err error,
+) {
+	if verb == auth.VerbRead || s.Auth.Audit == nil {
+		return
+	}
+	entry := auth.NewAuditEntry(user, verb, key).WithResult(status, err)
+	if err == nil {
+		// Locate the object, as it may have moved to another version (e.g.
+		// by a migration).
+		// If it no longer exists (e.g. deleted by the garbage collector)
+		// there is nothing to compare.
+		if _, current, lErr := s.locate(ctx, key); lErr == nil {
+			changes, cErr := auth.ChangedFields(existing, current)
+			if cErr != nil {
+				slog.Error("audit changed fields", "key", key, "error", cErr)
+			}
+			entry.Changes = changes
+		}
+	}
+	s.Auth.Audit.Record(ctx, entry)
+}
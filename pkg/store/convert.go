@@ -0,0 +1,344 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+// kindVersions returns the versions of the key's kind, which the controller
+// for the kind registers in the versions bucket.
+// If no versions are registered for the kind, ok is false and the kind is
+// treated as having a single version.
+func (s *Store) kindVersions(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+) (hz.KindVersions, bool, error) {
+	return s.versions.get(ctx, hz.KindVersionsKey(key))
+}
+
+// versionsCache keeps the versions bucket in memory, so that reading the
+// versions of a kind (e.g. on every list) does not need a request.
+type versionsCache struct {
+	kv      jetstream.KeyValue
+	watcher jetstream.KeyWatcher
+
+	mu      sync.RWMutex
+	entries map[string]versionsEntry
+}
+
+type versionsEntry struct {
+	versions hz.KindVersions
+	// ok is false if the versions were deleted.
+	ok       bool
+	revision uint64
+}
+
+// start loads the versions bucket and keeps watching it for updates.
+func (vc *versionsCache) start(ctx context.Context) error {
+	vc.entries = make(map[string]versionsEntry)
+	watcher, err := vc.kv.WatchAll(ctx)
+	if err != nil {
+		return fmt.Errorf("watching versions: %w", err)
+	}
+	vc.watcher = watcher
+	for entry := range watcher.Updates() {
+		// Nil entry is sent once all initial values have been received.
+		if entry == nil {
+			break
+		}
+		vc.set(entry)
+	}
+	go func() {
+		for entry := range watcher.Updates() {
+			if entry != nil {
+				vc.set(entry)
+			}
+		}
+	}()
+	return nil
+}
+
+func (vc *versionsCache) stop() {
+	if vc.watcher != nil {
+		_ = vc.watcher.Stop()
+	}
+}
+
+// set caches the entry, unless a newer revision is already cached.
+func (vc *versionsCache) set(entry jetstream.KeyValueEntry) {
+	ve := versionsEntry{revision: entry.Revision()}
+	if entry.Operation() == jetstream.KeyValuePut {
+		if err := json.Unmarshal(entry.Value(), &ve.versions); err != nil {
+			slog.Error(
+				"unmarshalling versions",
+				"key", entry.Key(),
+				"error", err,
+			)
+			return
+		}
+		ve.ok = true
+	}
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if cur, ok := vc.entries[entry.Key()]; ok && cur.revision >= ve.revision {
+		return
+	}
+	vc.entries[entry.Key()] = ve
+}
+
+// get returns the versions for the key.
+// If the key is not cached, e.g. because the controller registered its
+// versions moments ago, it is read from the bucket.
+func (vc *versionsCache) get(
+	ctx context.Context,
+	key string,
+) (hz.KindVersions, bool, error) {
+	vc.mu.RLock()
+	ve, ok := vc.entries[key]
+	vc.mu.RUnlock()
+	if ok {
+		return ve.versions, ve.ok, nil
+	}
+	entry, err := vc.kv.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return hz.KindVersions{}, false, nil
+		}
+		return hz.KindVersions{}, false, &hz.Error{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("getting versions: %s", err.Error()),
+		}
+	}
+	vc.set(entry)
+	var versions hz.KindVersions
+	if err := json.Unmarshal(entry.Value(), &versions); err != nil {
+		return hz.KindVersions{}, false, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"unmarshalling versions: %s",
+				err.Error(),
+			),
+		}
+	}
+	return versions, true, nil
+}
+
+// convert requests the controller to convert the object in data to the given
+// version of the key's kind.
+func (s *Store) convert(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+	data []byte,
+	version string,
+) ([]byte, error) {
+	msg := nats.NewMsg(
+		fmt.Sprintf(
+			hz.SubjectCtlrConvert,
+			key.ObjectGroup(),
+			key.ObjectKind(),
+		),
+	)
+	msg.Header.Set(hz.HeaderConvertVersion, version)
+	msg.Data = data
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	reply, err := s.Conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		return nil, hz.ErrorFromNATSErr(err)
+	}
+	if err := hz.ErrorFromNATS(reply); err != nil {
+		return nil, err
+	}
+	return reply.Data, nil
+}
+
+// convertList converts the objects in items to the given version of the key's
+// kind in place, using as few requests to the controller as the size of the
+// objects allows.
+// Controllers that do not serve list conversions (i.e. older ones) convert the
+// objects one by one.
+func (s *Store) convertList(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+	items []json.RawMessage,
+	version string,
+) error {
+	// Leave room in the payload for the objects growing during conversion.
+	maxBatchSize := int(s.Conn.MaxPayload() / 2)
+	for start := 0; start < len(items); {
+		end, size := start, 0
+		for end < len(items) && (end == start ||
+			size+len(items[end])+1 <= maxBatchSize) {
+			size += len(items[end]) + 1
+			end++
+		}
+		batch := items[start:end]
+		converted, err := s.convertBatch(ctx, key, batch, version)
+		if err != nil {
+			if !errors.Is(err, nats.ErrNoResponders) {
+				return err
+			}
+			for i, item := range batch {
+				data, err := s.convert(ctx, key, item, version)
+				if err != nil {
+					return err
+				}
+				batch[i] = data
+			}
+			start = end
+			continue
+		}
+		copy(batch, converted)
+		start = end
+	}
+	return nil
+}
+
+// convertBatch requests the controller to convert a batch of objects.
+// If no controller serves list conversions, it returns
+// [nats.ErrNoResponders].
+func (s *Store) convertBatch(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+	batch []json.RawMessage,
+	version string,
+) ([]json.RawMessage, error) {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return nil, &hz.Error{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("marshalling objects: %s", err.Error()),
+		}
+	}
+	msg := nats.NewMsg(
+		fmt.Sprintf(
+			hz.SubjectCtlrConvertList,
+			key.ObjectGroup(),
+			key.ObjectKind(),
+		),
+	)
+	msg.Header.Set(hz.HeaderConvertVersion, version)
+	msg.Data = data
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	reply, err := s.Conn.RequestMsgWithContext(ctx, msg)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoResponders) {
+			return nil, nats.ErrNoResponders
+		}
+		return nil, hz.ErrorFromNATSErr(err)
+	}
+	if err := hz.ErrorFromNATS(reply); err != nil {
+		return nil, err
+	}
+	var converted []json.RawMessage
+	if err := json.Unmarshal(reply.Data, &converted); err != nil {
+		return nil, &hz.Error{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("unmarshalling objects: %s", err.Error()),
+		}
+	}
+	if len(converted) != len(batch) {
+		return nil, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"converted %d objects, expected %d",
+				len(converted),
+				len(batch),
+			),
+		}
+	}
+	return converted, nil
+}
+
+// locate finds the object for the given key, which may be stored under a
+// different version of the kind than the key requests.
+// It returns the key the object is stored under and the stored object.
+func (s *Store) locate(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+) (hz.ObjectKey, []byte, error) {
+	storedKey := objectKey(key)
+	data, err := s.get(ctx, key)
+	if err == nil {
+		return storedKey, data, nil
+	}
+	if !errors.Is(err, hz.ErrNotFound) {
+		return hz.ObjectKey{}, nil, err
+	}
+	versions, ok, err := s.kindVersions(ctx, key)
+	if err != nil {
+		return hz.ObjectKey{}, nil, err
+	}
+	if !ok {
+		return hz.ObjectKey{}, nil, hz.ErrNotFound
+	}
+	if !versions.IsServed(key.ObjectVersion()) {
+		return hz.ObjectKey{}, nil, &hz.Error{
+			Status: http.StatusNotFound,
+			Message: fmt.Sprintf(
+				"version %q of %s/%s is not served",
+				key.ObjectVersion(),
+				key.ObjectGroup(),
+				key.ObjectKind(),
+			),
+		}
+	}
+	// Objects normally live under the storage version, so check that first.
+	candidates := append(
+		[]string{versions.StorageVersion},
+		versions.Versions...,
+	)
+	for _, version := range candidates {
+		if version == key.ObjectVersion() {
+			continue
+		}
+		storedKey.Version = version
+		data, err := s.get(ctx, storedKey)
+		if err != nil {
+			if errors.Is(err, hz.ErrNotFound) {
+				continue
+			}
+			return hz.ObjectKey{}, nil, err
+		}
+		return storedKey, data, nil
+	}
+	return hz.ObjectKey{}, nil, hz.ErrNotFound
+}
+
+// storageKey returns the key for storing a new object of the key's kind, which
+// uses the storage version of the kind.
+func (s *Store) storageKey(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+) (hz.ObjectKey, error) {
+	storageKey := objectKey(key)
+	versions, ok, err := s.kindVersions(ctx, key)
+	if err != nil {
+		return hz.ObjectKey{}, err
+	}
+	if ok {
+		storageKey.Version = versions.StorageVersion
+	}
+	return storageKey, nil
+}
+
+func objectKey(key hz.ObjectKeyer) hz.ObjectKey {
+	return hz.ObjectKey{
+		Group:     key.ObjectGroup(),
+		Version:   key.ObjectVersion(),
+		Kind:      key.ObjectKind(),
+		Namespace: key.ObjectNamespace(),
+		Name:      key.ObjectName(),
+	}
+}
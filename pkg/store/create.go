@@ -64,7 +64,7 @@ func (s *Store) Create(ctx context.Context, req CreateRequest) error {
 			),
 		}
 	}
-	if _, err := s.kv.Create(ctx, rawKey, data); err != nil {
+	if _, err := s.putObject(ctx, rawKey, data, 0); err != nil {
 		if errors.Is(err, jetstream.ErrKeyExists) {
 			return &hz.Error{
 				Status: http.StatusConflict,
@@ -16,7 +16,7 @@ type DeleteRequest struct {
 }
 
 func (s *Store) Delete(ctx context.Context, req DeleteRequest) error {
-	data, err := s.get(ctx, req.Key)
+	key, data, err := s.locate(ctx, req.Key)
 	if err != nil {
 		return err
 	}
@@ -44,7 +44,7 @@ func (s *Store) Delete(ctx context.Context, req DeleteRequest) error {
 	}
 	if err := s.Update(ctx, UpdateRequest{
 		Data:     data,
-		Key:      req.Key,
+		Key:      key,
 		Revision: revision,
 	}); err != nil {
 		return err
@@ -16,7 +16,14 @@ type GetRequest struct {
 }
 
 func (s *Store) Get(ctx context.Context, req GetRequest) ([]byte, error) {
-	return s.get(ctx, req.Key)
+	storedKey, data, err := s.locate(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if storedKey.Version == req.Key.ObjectVersion() {
+		return data, nil
+	}
+	return s.convert(ctx, req.Key, data, req.Key.ObjectVersion())
 }
 
 func (s *Store) get(ctx context.Context, key hz.ObjectKeyer) ([]byte, error) {
@@ -76,5 +76,157 @@ func InitKeyValue(
 		}
 	}
 	// TODO: handle updating the mutex bucket if it exists.
+
+	if _, err := js.KeyValue(ctx, hz.BucketValidators); err != nil {
+		if !errors.Is(err, jetstream.ErrBucketNotFound) {
+			return fmt.Errorf(
+				"get validators bucket %q: %w",
+				hz.BucketValidators,
+				err,
+			)
+		}
+		if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      hz.BucketValidators,
+			Description: "Validator policies for " + hz.BucketObjects,
+			History:     1,
+		}); err != nil {
+			return fmt.Errorf(
+				"create validators bucket %q: %w",
+				hz.BucketValidators,
+				err,
+			)
+		}
+	}
+
+	if _, err := js.KeyValue(ctx, hz.BucketMergeSchemas); err != nil {
+		if !errors.Is(err, jetstream.ErrBucketNotFound) {
+			return fmt.Errorf(
+				"get merge schemas bucket %q: %w",
+				hz.BucketMergeSchemas,
+				err,
+			)
+		}
+		if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      hz.BucketMergeSchemas,
+			Description: "Merge schemas for " + hz.BucketObjects,
+			History:     1,
+		}); err != nil {
+			return fmt.Errorf(
+				"create merge schemas bucket %q: %w",
+				hz.BucketMergeSchemas,
+				err,
+			)
+		}
+	}
+
+	if _, err := js.KeyValue(ctx, hz.BucketLeases); err != nil {
+		if !errors.Is(err, jetstream.ErrBucketNotFound) {
+			return fmt.Errorf(
+				"get leases bucket %q: %w",
+				hz.BucketLeases,
+				err,
+			)
+		}
+		if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      hz.BucketLeases,
+			Description: "Leases for leader election.",
+			History:     1,
+			// A lease that is not renewed expires after the TTL.
+			TTL: opt.leaseTTL,
+		}); err != nil {
+			return fmt.Errorf(
+				"create leases bucket %q: %w",
+				hz.BucketLeases,
+				err,
+			)
+		}
+	}
+
+	if _, err := js.KeyValue(ctx, hz.BucketControllers); err != nil {
+		if !errors.Is(err, jetstream.ErrBucketNotFound) {
+			return fmt.Errorf(
+				"get controllers bucket %q: %w",
+				hz.BucketControllers,
+				err,
+			)
+		}
+		if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      hz.BucketControllers,
+			Description: "Status of the running controllers.",
+			History:     1,
+			// A controller that stops writing its status expires after the
+			// TTL.
+			TTL: opt.controllerTTL,
+		}); err != nil {
+			return fmt.Errorf(
+				"create controllers bucket %q: %w",
+				hz.BucketControllers,
+				err,
+			)
+		}
+	}
+
+	if _, err := js.KeyValue(ctx, hz.BucketDeadLetters); err != nil {
+		if !errors.Is(err, jetstream.ErrBucketNotFound) {
+			return fmt.Errorf(
+				"get dead letters bucket %q: %w",
+				hz.BucketDeadLetters,
+				err,
+			)
+		}
+		if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      hz.BucketDeadLetters,
+			Description: "Objects that controllers stopped retrying.",
+			History:     1,
+		}); err != nil {
+			return fmt.Errorf(
+				"create dead letters bucket %q: %w",
+				hz.BucketDeadLetters,
+				err,
+			)
+		}
+	}
+
+	if _, err := js.KeyValue(ctx, hz.BucketResyncs); err != nil {
+		if !errors.Is(err, jetstream.ErrBucketNotFound) {
+			return fmt.Errorf(
+				"get resyncs bucket %q: %w",
+				hz.BucketResyncs,
+				err,
+			)
+		}
+		if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      hz.BucketResyncs,
+			Description: "Deliveries of objects that were resyncs.",
+			History:     1,
+		}); err != nil {
+			return fmt.Errorf(
+				"create resyncs bucket %q: %w",
+				hz.BucketResyncs,
+				err,
+			)
+		}
+	}
+
+	if _, err := js.KeyValue(ctx, hz.BucketVersions); err != nil {
+		if !errors.Is(err, jetstream.ErrBucketNotFound) {
+			return fmt.Errorf(
+				"get versions bucket %q: %w",
+				hz.BucketVersions,
+				err,
+			)
+		}
+		if _, err := js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      hz.BucketVersions,
+			Description: "Versions of the kinds in " + hz.BucketObjects,
+			History:     1,
+		}); err != nil {
+			return fmt.Errorf(
+				"create versions bucket %q: %w",
+				hz.BucketVersions,
+				err,
+			)
+		}
+	}
 	return nil
 }
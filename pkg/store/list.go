@@ -22,8 +22,27 @@ func (s *Store) List(
 	ctx context.Context,
 	req ListRequest,
 ) (*hz.ObjectList, error) {
+	// If the kind is served in multiple versions, list objects of all versions
+	// and convert them to the requested version.
+	listKey := objectKey(req.Key)
+	var (
+		versions hz.KindVersions
+		convert  bool
+	)
+	if isConcreteKind(req.Key) {
+		kindVersions, ok, err := s.kindVersions(ctx, req.Key)
+		if err != nil {
+			return nil, err
+		}
+		if ok && len(kindVersions.Versions) > 1 &&
+			kindVersions.IsServed(req.Key.ObjectVersion()) {
+			listKey.Version = ""
+			versions = kindVersions
+			convert = true
+		}
+	}
 	wOpts := []jetstream.WatchOpt{jetstream.IgnoreDeletes()}
-	watcher, err := s.kv.Watch(ctx, hz.KeyFromObject(req.Key), wOpts...)
+	watcher, err := s.kv.Watch(ctx, hz.KeyFromObject(listKey), wOpts...)
 	if err != nil {
 		return nil, &hz.Error{
 			Status:  http.StatusInternalServerError,
@@ -35,6 +54,13 @@ func (s *Store) List(
 	}()
 
 	objects := []json.RawMessage{}
+	// While objects are being migrated, an object can be stored under two
+	// versions at once.
+	// Index the objects by namespace and name, to keep only one of them.
+	indexes := map[string]int{}
+	// The objects that are stored under another version than the requested
+	// one, which are converted at the end in batches.
+	stale := []bool{}
 	for entry := range watcher.Updates() {
 		if entry == nil {
 			break
@@ -43,9 +69,65 @@ func (s *Store) List(
 		if err != nil {
 			return nil, fmt.Errorf("formatting data: %w", err)
 		}
+		if !convert {
+			objects = append(objects, data)
+			continue
+		}
+		entryKey, err := hz.ObjectKeyFromString(entry.Key())
+		if err != nil {
+			return nil, &hz.Error{
+				Status:  http.StatusInternalServerError,
+				Message: fmt.Sprintf("parsing key: %s", err.Error()),
+			}
+		}
+		name := entryKey.Namespace + "/" + entryKey.Name
+		index, seen := indexes[name]
+		// Prefer the object stored under the storage version, which is the
+		// result of migrating the other.
+		if seen && entryKey.Version != versions.StorageVersion {
+			continue
+		}
+		isStale := entryKey.Version != req.Key.ObjectVersion()
+		if seen {
+			objects[index] = data
+			stale[index] = isStale
+			continue
+		}
+		indexes[name] = len(objects)
 		objects = append(objects, data)
+		stale = append(stale, isStale)
+	}
+	if convert {
+		var toConvert []int
+		for i, isStale := range stale {
+			if isStale {
+				toConvert = append(toConvert, i)
+			}
+		}
+		items := make([]json.RawMessage, len(toConvert))
+		for i, index := range toConvert {
+			items[i] = objects[index]
+		}
+		if err := s.convertList(
+			ctx,
+			req.Key,
+			items,
+			req.Key.ObjectVersion(),
+		); err != nil {
+			return nil, err
+		}
+		for i, index := range toConvert {
+			objects[index] = items[i]
+		}
 	}
 	return &hz.ObjectList{
 		Items: objects,
 	}, nil
 }
+
+// isConcreteKind returns true if the key refers to a single version of a kind.
+func isConcreteKind(key hz.ObjectKeyer) bool {
+	return key.ObjectGroup() != "*" &&
+		key.ObjectVersion() != "*" &&
+		key.ObjectKind() != "*"
+}
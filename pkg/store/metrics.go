@@ -0,0 +1,54 @@
+package store
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+var (
+	metricRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "horizon",
+			Subsystem: "store",
+			Name:      "requests_total",
+			Help:      "Total number of store requests by command and status.",
+		},
+		[]string{"command", "status"},
+	)
+	metricRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "horizon",
+			Subsystem: "store",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of store requests by command and status.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"command", "status"},
+	)
+)
+
+// observeCommand records the result of a store command that started at start.
+func observeCommand(
+	cmd StoreCommand,
+	start time.Time,
+	status int,
+	err error,
+) {
+	if err != nil {
+		status = http.StatusInternalServerError
+		var hErr *hz.Error
+		if errors.As(err, &hErr) {
+			status = hErr.Status
+		}
+	}
+	labels := []string{cmd.String(), strconv.Itoa(status)}
+	metricRequests.WithLabelValues(labels...).Inc()
+	metricRequestDuration.WithLabelValues(labels...).
+		Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+type MigrateRequest struct {
+	// Key is the key of the object as it is stored, including the version.
+	Key hz.ObjectKeyer
+}
+
+// Migrate rewrites the object stored under the given key to the storage
+// version of its kind.
+// It returns an HTTP status code (int) indicating the result of the operation:
+// [http.StatusOK] if the object was migrated, or [http.StatusNotModified] if
+// the object is already stored using the storage version.
+//
+// Migrating uses optimistic concurrency: if the object is modified while it is
+// being migrated, the migration is undone and a conflict error is returned, so
+// that the caller can try again.
+func (s *Store) Migrate(ctx context.Context, req MigrateRequest) (int, error) {
+	versions, ok, err := s.kindVersions(ctx, req.Key)
+	if err != nil {
+		return -1, err
+	}
+	if !ok {
+		return -1, &hz.Error{
+			Status: http.StatusServiceUnavailable,
+			Message: fmt.Sprintf(
+				"no versions registered for %s/%s: "+
+					"start the controller for the kind",
+				req.Key.ObjectGroup(),
+				req.Key.ObjectKind(),
+			),
+		}
+	}
+	data, err := s.get(ctx, req.Key)
+	if err != nil {
+		return -1, err
+	}
+	if req.Key.ObjectVersion() == versions.StorageVersion {
+		return http.StatusNotModified, nil
+	}
+	var obj hz.MetaOnlyObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return -1, &hz.Error{
+			Status:  http.StatusInternalServerError,
+			Message: fmt.Sprintf("unmarshalling object: %s", err.Error()),
+		}
+	}
+	if obj.Revision == nil {
+		return -1, &hz.Error{
+			Status:  http.StatusInternalServerError,
+			Message: "object revision is nil",
+		}
+	}
+	converted, err := s.convert(ctx, req.Key, data, versions.StorageVersion)
+	if err != nil {
+		return -1, hz.ErrorWrap(
+			err,
+			http.StatusInternalServerError,
+			fmt.Sprintf("converting to version %q", versions.StorageVersion),
+		)
+	}
+	converted, err = removeReadOnlyFields(converted)
+	if err != nil {
+		return -1, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"removing read-only fields: %s",
+				err.Error(),
+			),
+		}
+	}
+
+	storageKey := objectKey(req.Key)
+	storageKey.Version = versions.StorageVersion
+	rawStorageKey := hz.KeyFromObject(storageKey)
+	rawKey := hz.KeyFromObject(req.Key)
+	revision, err := s.putObject(ctx, rawStorageKey, converted, 0)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyExists) {
+			return -1, &hz.Error{
+				Status: http.StatusConflict,
+				Message: fmt.Sprintf(
+					"object already exists in storage version: %q",
+					storageKey,
+				),
+			}
+		}
+		return -1, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"creating object in storage version: %s",
+				err.Error(),
+			),
+		}
+	}
+	// Only delete the old object if it has not been modified since it was
+	// read.
+	// Otherwise, undo the create so that no updates are lost.
+	err = s.kv.Delete(ctx, rawKey, jetstream.LastRevision(*obj.Revision))
+	if err == nil {
+		return http.StatusOK, nil
+	}
+	if rbErr := s.kv.Delete(
+		ctx,
+		rawStorageKey,
+		jetstream.LastRevision(revision),
+	); rbErr != nil {
+		return -1, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"undoing migration of %q after error (%s): %s",
+				rawKey,
+				err.Error(),
+				rbErr.Error(),
+			),
+		}
+	}
+	if isErrWrongLastSequence(err) {
+		return -1, &hz.Error{
+			Status: http.StatusConflict,
+			Message: fmt.Sprintf(
+				"object modified during migration: %q: please try again",
+				rawKey,
+			),
+		}
+	}
+	return -1, &hz.Error{
+		Status: http.StatusInternalServerError,
+		Message: fmt.Sprintf(
+			"deleting object from old version: %s",
+			err.Error(),
+		),
+	}
+}
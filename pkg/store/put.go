@@ -0,0 +1,69 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/verifa/horizon/pkg/hz"
+)
+
+// putObject writes data to the objects bucket under rawKey, if the latest
+// revision of the key is revision.
+// A revision of zero requires that the key does not exist (or was deleted).
+//
+// It publishes to the bucket's stream directly, instead of using the key value
+// API, so that the trace context can be added to the message headers.
+// Controllers and watchers then continue the trace of the request that
+// changed the object.
+func (s *Store) putObject(
+	ctx context.Context,
+	rawKey string,
+	data []byte,
+	revision uint64,
+) (uint64, error) {
+	seq, err := s.publishObject(ctx, rawKey, data, revision)
+	if err == nil || revision != 0 || !isErrWrongLastSequence(err) {
+		return seq, err
+	}
+	// The key exists, but it may only be a delete marker, in which case we
+	// can create the object using the revision of the marker.
+	subject := "$KV." + s.kv.Bucket() + "." + rawKey
+	stream, sErr := s.js.Stream(ctx, "KV_"+s.kv.Bucket())
+	if sErr != nil {
+		return 0, fmt.Errorf("get stream: %w", sErr)
+	}
+	last, lErr := stream.GetLastMsgForSubject(ctx, subject)
+	if lErr != nil {
+		return 0, fmt.Errorf("get last msg for subject: %w", lErr)
+	}
+	switch last.Header.Get(kvOperationHeader) {
+	case "DEL", "PURGE":
+		return s.publishObject(ctx, rawKey, data, last.Sequence)
+	}
+	return 0, jetstream.ErrKeyExists
+}
+
+func (s *Store) publishObject(
+	ctx context.Context,
+	rawKey string,
+	data []byte,
+	revision uint64,
+) (uint64, error) {
+	msg := nats.NewMsg("$KV." + s.kv.Bucket() + "." + rawKey)
+	msg.Header.Set(
+		jetstream.ExpectedLastSubjSeqHeader,
+		strconv.FormatUint(revision, 10),
+	)
+	msg.Data = data
+	hz.TraceInject(ctx, msg.Header)
+	ack, err := s.js.PublishMsg(ctx, msg)
+	if err != nil {
+		return 0, err
+	}
+	return ack.Sequence, nil
+}
+
+const kvOperationHeader = "KV-Operation"
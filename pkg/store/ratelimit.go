@@ -0,0 +1,122 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/verifa/horizon/pkg/hz"
+	"golang.org/x/time/rate"
+)
+
+// sessionLimiterTTL is how long the limiter for a session is kept after the
+// session's last request.
+const sessionLimiterTTL = 10 * time.Minute
+
+// rateLimiter limits the rate of API requests using token buckets, both per
+// session and globally (across all sessions).
+type rateLimiter struct {
+	global *rate.Limiter
+
+	sessionLimit rate.Limit
+	sessionBurst int
+
+	mu        sync.Mutex
+	sessions  map[string]*sessionLimiter
+	lastPrune time.Time
+}
+
+type sessionLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(opt storeOptions) *rateLimiter {
+	return &rateLimiter{
+		global:       rate.NewLimiter(opt.rateLimit, opt.rateBurst),
+		sessionLimit: opt.sessionRateLimit,
+		sessionBurst: opt.sessionRateBurst,
+		sessions:     make(map[string]*sessionLimiter),
+		lastPrune:    time.Now(),
+	}
+}
+
+// limitsSessions returns true if requests are limited per session.
+// The caller should authenticate the session before calling
+// [rateLimiter.allow], so that limiters are only kept for valid sessions.
+func (l *rateLimiter) limitsSessions() bool {
+	return l.sessionLimit != rate.Inf
+}
+
+// allow reports whether a request for the session is allowed now.
+// If not, it returns an error with status 429 (Too Many Requests).
+//
+// A request denied by the global limit does not use a token of the session.
+func (l *rateLimiter) allow(session string) error {
+	now := time.Now()
+	sr := l.sessionReserve(session, now)
+	if sr != nil && !reserved(sr, now) {
+		return &hz.Error{
+			Status:  hz.ErrTooManyRequests.Status,
+			Message: "too many requests for session",
+		}
+	}
+	if !reserved(l.global.ReserveN(now, 1), now) {
+		if sr != nil {
+			sr.CancelAt(now)
+		}
+		return hz.ErrTooManyRequests
+	}
+	return nil
+}
+
+// sessionReserve reserves a token of the session, or returns nil if requests
+// are not limited per session.
+func (l *rateLimiter) sessionReserve(
+	session string,
+	now time.Time,
+) *rate.Reservation {
+	if !l.limitsSessions() {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	sl, ok := l.sessions[session]
+	if !ok {
+		sl = &sessionLimiter{
+			limiter: rate.NewLimiter(l.sessionLimit, l.sessionBurst),
+		}
+		l.sessions[session] = sl
+	}
+	sl.lastSeen = now
+	return sl.limiter.ReserveN(now, 1)
+}
+
+// reserved returns true if the reservation can be used now.
+// Otherwise the reservation is cancelled, as requests do not wait for
+// tokens.
+func reserved(r *rate.Reservation, now time.Time) bool {
+	if !r.OK() {
+		return false
+	}
+	if r.DelayFrom(now) > 0 {
+		r.CancelAt(now)
+		return false
+	}
+	return true
+}
+
+// prune removes the limiters for sessions that have not made a request
+// recently, so that expired sessions do not use memory forever.
+// The caller must hold the lock.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < sessionLimiterTTL {
+		return
+	}
+	for session, sl := range l.sessions {
+		if now.Sub(sl.lastSeen) > sessionLimiterTTL {
+			delete(l.sessions, session)
+		}
+	}
+	l.lastPrune = now
+}
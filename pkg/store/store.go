@@ -18,6 +18,8 @@ import (
 	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/extensions/core"
 	"github.com/verifa/horizon/pkg/hz"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -38,10 +40,11 @@ const (
 type StoreCommand string
 
 const (
-	StoreCommandApply  StoreCommand = "apply"
-	StoreCommandGet    StoreCommand = "get"
-	StoreCommandList   StoreCommand = "list"
-	StoreCommandDelete StoreCommand = "delete"
+	StoreCommandApply   StoreCommand = "apply"
+	StoreCommandGet     StoreCommand = "get"
+	StoreCommandList    StoreCommand = "list"
+	StoreCommandDelete  StoreCommand = "delete"
+	StoreCommandMigrate StoreCommand = "migrate"
 )
 
 func (c StoreCommand) String() string {
@@ -56,20 +59,82 @@ func WithMutexTTL(ttl time.Duration) StoreOption {
 	}
 }
 
+// WithLeaseTTL sets the duration of the leases for leader election.
+// A leader that stops renewing its lease is replaced after this duration.
+func WithLeaseTTL(ttl time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.leaseTTL = ttl
+	}
+}
+
+// WithControllerTTL sets how long a controller shows as running in the
+// controller registry after its last heartbeat.
+// Controllers write their status at a third of this duration.
+func WithControllerTTL(ttl time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.controllerTTL = ttl
+	}
+}
+
 func WithStopTimeout(timeout time.Duration) StoreOption {
 	return func(o *storeOptions) {
 		o.stopTimeout = timeout
 	}
 }
 
+// WithRateLimit limits the rate of API requests across all sessions, using a
+// token bucket that refills at r tokens per second and holds up to burst
+// tokens.
+// Requests over the limit fail with status 429 (Too Many Requests).
+// The burst must be at least 1.
+func WithRateLimit(r rate.Limit, burst int) StoreOption {
+	return func(o *storeOptions) {
+		o.rateLimit = r
+		o.rateBurst = burst
+	}
+}
+
+// WithSessionRateLimit limits the rate of API requests per session, using a
+// token bucket that refills at r tokens per second and holds up to burst
+// tokens.
+// Requests over the limit fail with status 429 (Too Many Requests).
+// The burst must be at least 1.
+func WithSessionRateLimit(r rate.Limit, burst int) StoreOption {
+	return func(o *storeOptions) {
+		o.sessionRateLimit = r
+		o.sessionRateBurst = burst
+	}
+}
+
+// WithMaxObjectSize sets the maximum size in bytes of an applied object.
+// Larger objects are rejected with status 413 (Request Entity Too Large).
+// Zero means no limit.
+func WithMaxObjectSize(size int) StoreOption {
+	return func(o *storeOptions) {
+		o.maxObjectSize = size
+	}
+}
+
 var defaultStoreOptions = storeOptions{
-	mutexTTL:    time.Minute,
-	stopTimeout: time.Minute,
+	mutexTTL:         time.Minute,
+	leaseTTL:         time.Second * 15,
+	controllerTTL:    time.Second * 30,
+	stopTimeout:      time.Minute,
+	rateLimit:        rate.Inf,
+	sessionRateLimit: rate.Inf,
 }
 
 type storeOptions struct {
-	mutexTTL    time.Duration
-	stopTimeout time.Duration
+	mutexTTL      time.Duration
+	leaseTTL      time.Duration
+	controllerTTL time.Duration
+	stopTimeout   time.Duration
+
+	rateLimit        rate.Limit
+	rateBurst        int
+	sessionRateLimit rate.Limit
+	sessionRateBurst int
+	maxObjectSize    int
 }
 
 func StartStore(
@@ -92,14 +157,22 @@ type Store struct {
 	Conn *nats.Conn
 	Auth *auth.Auth
 
-	js    jetstream.JetStream
-	kv    jetstream.KeyValue
-	mutex jetstream.KeyValue
-	gc    *GarbageCollector
-	subs  []*nats.Subscription
-
-	stopTimeout time.Duration
-	wg          sync.WaitGroup
+	js         jetstream.JetStream
+	kv         jetstream.KeyValue
+	mutex      jetstream.KeyValue
+	validators jetstream.KeyValue
+	schemas    jetstream.KeyValue
+	// controllers is the controller registry.
+	controllers jetstream.KeyValue
+	// versions caches the versions of each kind.
+	versions *versionsCache
+	gc       *GarbageCollector
+	subs     []*nats.Subscription
+
+	stopTimeout   time.Duration
+	limiter       *rateLimiter
+	maxObjectSize int
+	wg            sync.WaitGroup
 }
 
 func (s *Store) Start(
@@ -113,7 +186,16 @@ func (s *Store) Start(
 		o(&opt)
 	}
 
+	if opt.rateLimit != rate.Inf && opt.rateBurst < 1 {
+		return errors.New("rate limit burst must be at least 1")
+	}
+	if opt.sessionRateLimit != rate.Inf && opt.sessionRateBurst < 1 {
+		return errors.New("session rate limit burst must be at least 1")
+	}
+
 	s.stopTimeout = opt.stopTimeout
+	s.limiter = newRateLimiter(opt)
+	s.maxObjectSize = opt.maxObjectSize
 
 	js, err := jetstream.New(conn)
 	if err != nil {
@@ -138,6 +220,45 @@ func (s *Store) Start(
 		)
 	}
 	s.mutex = mutex
+	validators, err := js.KeyValue(ctx, hz.BucketValidators)
+	if err != nil {
+		return fmt.Errorf(
+			"connecting to validators kv bucket %q: %w",
+			hz.BucketValidators,
+			err,
+		)
+	}
+	s.validators = validators
+	schemas, err := js.KeyValue(ctx, hz.BucketMergeSchemas)
+	if err != nil {
+		return fmt.Errorf(
+			"connecting to merge schemas kv bucket %q: %w",
+			hz.BucketMergeSchemas,
+			err,
+		)
+	}
+	s.schemas = schemas
+	controllers, err := js.KeyValue(ctx, hz.BucketControllers)
+	if err != nil {
+		return fmt.Errorf(
+			"connecting to controllers kv bucket %q: %w",
+			hz.BucketControllers,
+			err,
+		)
+	}
+	s.controllers = controllers
+	versions, err := js.KeyValue(ctx, hz.BucketVersions)
+	if err != nil {
+		return fmt.Errorf(
+			"connecting to versions kv bucket %q: %w",
+			hz.BucketVersions,
+			err,
+		)
+	}
+	s.versions = &versionsCache{kv: versions}
+	if err := s.versions.start(ctx); err != nil {
+		return fmt.Errorf("start versions cache: %w", err)
+	}
 
 	{
 		sub, err := conn.QueueSubscribe(
@@ -188,6 +309,7 @@ func (s *Store) Close() error {
 		}
 	}
 	s.gc.Stop()
+	s.versions.stop()
 
 	// Wait for all store operations to finish, or timeout.
 	if s.stopWaitTimeout() {
@@ -233,29 +355,27 @@ func (s *Store) stopWaitTimeout() bool {
 func (s *Store) handleAPIMsg(ctx context.Context, msg *nats.Msg) {
 	s.wg.Add(1)
 	defer s.wg.Done()
-	// Parse subject to get details.
-	parts := strings.Split(msg.Subject, ".")
-	if len(parts) != subjectLength {
-		_ = hz.RespondError(msg, &hz.Error{
-			Status:  http.StatusBadRequest,
-			Message: fmt.Sprintf("invalid subject: %q", msg.Subject),
-		})
+	// Check the rate limits before handling the message, so that requests
+	// over the limit are cheap.
+	if err := s.rateLimit(ctx, msg); err != nil {
+		_ = hz.RespondError(msg, err)
 		return
 	}
-	cmd := StoreCommand(parts[subjectIndexCommand])
-
-	key := hz.ObjectKey{
-		Group:     parts[subjectIndexGroup],
-		Version:   parts[subjectIndexVersion],
-		Kind:      parts[subjectIndexKind],
-		Namespace: parts[subjectIndexNamespace],
-		Name:      parts[subjectIndexName],
+	cmd, key, err := parseSubject(msg.Subject)
+	if err != nil {
+		_ = hz.RespondError(msg, err)
+		return
 	}
+	ctx, span := startSpan(ctx, cmd, key, msg)
+	defer span.End()
 
 	req := auth.CheckRequest{
 		Session: msg.Header.Get(hz.HeaderAuthorization),
 		Object:  key,
 	}
+	// existing is the object before a mutating command, used to summarise
+	// the changes in the audit log.
+	var existing []byte
 	switch cmd {
 	case StoreCommandList:
 		// List is a bit special. We do not check for permissions to a specific
@@ -271,20 +391,26 @@ func (s *Store) handleAPIMsg(ctx context.Context, msg *nats.Msg) {
 			return
 		}
 
-		s.handleInternalMsg(ctx, msg)
+		respond(msg)(s.handleCommand(ctx, cmd, key, msg))
 		return
 	case StoreCommandGet:
 		req.Verb = auth.VerbRead
 	case StoreCommandApply:
 		// This requires checking if it's a create or edit operation.
-		_, err := s.get(ctx, key)
+		_, data, err := s.locate(ctx, key)
 		if errors.Is(err, hz.ErrNotFound) {
 			req.Verb = auth.VerbCreate
 		} else {
 			req.Verb = auth.VerbUpdate
+			existing = data
 		}
 	case StoreCommandDelete:
 		req.Verb = auth.VerbDelete
+		_, existing, _ = s.locate(ctx, key)
+	case StoreCommandMigrate:
+		// Migrating rewrites the object, so treat it as an update.
+		req.Verb = auth.VerbUpdate
+		existing, _ = s.get(ctx, key)
 	default:
 		_ = hz.RespondError(msg, &hz.Error{
 			Status:  http.StatusBadRequest,
@@ -293,19 +419,35 @@ func (s *Store) handleAPIMsg(ctx context.Context, msg *nats.Msg) {
 		return
 
 	}
-	ok, err := s.Auth.Check(ctx, req)
+	user, ok, err := s.Auth.Authorize(ctx, req)
 	if err != nil {
+		s.audit(ctx, user, req.Verb, key, nil, -1, err)
+		hz.TraceError(span, err)
 		_ = hz.RespondError(msg, err)
 		return
 	}
 	if !ok {
-		_ = hz.RespondError(msg, &hz.Error{
-			Status:  http.StatusForbidden,
-			Message: "forbidden",
-		})
+		s.audit(ctx, user, req.Verb, key, nil, -1, auth.ErrForbidden)
+		hz.TraceError(span, auth.ErrForbidden)
+		_ = hz.RespondError(msg, auth.ErrForbidden)
 		return
 	}
-	s.handleInternalMsg(ctx, msg)
+	status, data, err := s.handleCommand(ctx, cmd, key, msg)
+	s.audit(ctx, user, req.Verb, key, existing, status, err)
+	respond(msg)(status, data, err)
+}
+
+// rateLimit checks the rate limits for the session of the message.
+// If requests are limited per session, the session is authenticated first,
+// so that invalid sessions do not get a limiter.
+func (s *Store) rateLimit(ctx context.Context, msg *nats.Msg) error {
+	session := msg.Header.Get(hz.HeaderAuthorization)
+	if s.limiter.limitsSessions() {
+		if _, err := s.Auth.Sessions.Get(ctx, session); err != nil {
+			return err
+		}
+	}
+	return s.limiter.allow(session)
 }
 
 // handleInternalMsg handles messages for the internal (unprotected) nats
@@ -315,14 +457,24 @@ func (s *Store) handleAPIMsg(ctx context.Context, msg *nats.Msg) {
 func (s *Store) handleInternalMsg(ctx context.Context, msg *nats.Msg) {
 	s.wg.Add(1)
 	defer s.wg.Done()
-	// Parse subject to get details.
-	parts := strings.Split(msg.Subject, ".")
+	cmd, key, err := parseSubject(msg.Subject)
+	if err != nil {
+		_ = hz.RespondError(msg, err)
+		return
+	}
+	ctx, span := startSpan(ctx, cmd, key, msg)
+	defer span.End()
+	respond(msg)(s.handleCommand(ctx, cmd, key, msg))
+}
+
+// parseSubject parses the command and object key from a store subject.
+func parseSubject(subject string) (StoreCommand, hz.ObjectKey, error) {
+	parts := strings.Split(subject, ".")
 	if len(parts) != subjectLength {
-		_ = hz.RespondError(msg, &hz.Error{
+		return "", hz.ObjectKey{}, &hz.Error{
 			Status:  http.StatusBadRequest,
-			Message: fmt.Sprintf("invalid subject: %q", msg.Subject),
-		})
-		return
+			Message: fmt.Sprintf("invalid subject: %q", subject),
+		}
 	}
 	cmd := StoreCommand(parts[subjectIndexCommand])
 
@@ -333,9 +485,62 @@ func (s *Store) handleInternalMsg(ctx context.Context, msg *nats.Msg) {
 		Namespace: parts[subjectIndexNamespace],
 		Name:      parts[subjectIndexName],
 	}
+	return cmd, key, nil
+}
 
+// startSpan starts a span for the store command, continuing the trace from
+// the message headers.
+func startSpan(
+	ctx context.Context,
+	cmd StoreCommand,
+	key hz.ObjectKey,
+	msg *nats.Msg,
+) (context.Context, trace.Span) {
+	ctx = hz.TraceExtract(ctx, msg.Header)
+	return hz.Tracer().Start(
+		ctx,
+		"store."+cmd.String(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(hz.TraceAttributes(key)...),
+	)
+}
+
+// respond returns a function that responds to msg with the result of a store
+// command.
+func respond(msg *nats.Msg) func(int, []byte, error) {
+	return func(status int, data []byte, err error) {
+		if err != nil {
+			_ = hz.RespondError(msg, err)
+			return
+		}
+		_ = hz.RespondStatus(msg, status, data)
+	}
+}
+
+// handleCommand performs the store command and returns the status and body for
+// the response.
+func (s *Store) handleCommand(
+	ctx context.Context,
+	cmd StoreCommand,
+	key hz.ObjectKey,
+	msg *nats.Msg,
+) (status int, data []byte, err error) {
+	defer func(start time.Time) {
+		observeCommand(cmd, start, status, err)
+		hz.TraceError(trace.SpanFromContext(ctx), err)
+	}(time.Now())
 	switch cmd {
 	case StoreCommandApply:
+		if s.maxObjectSize > 0 && len(msg.Data) > s.maxObjectSize {
+			return -1, nil, &hz.Error{
+				Status: http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf(
+					"object size %d bytes exceeds maximum of %d bytes",
+					len(msg.Data),
+					s.maxObjectSize,
+				),
+			}
+		}
 		manager := msg.Header.Get(hz.HeaderApplyFieldManager)
 		forceStr := msg.Header.Get(hz.HeaderApplyForceConflicts)
 		createOnlyStr := msg.Header.Get(hz.HeaderApplyCreateOnly)
@@ -351,45 +556,33 @@ func (s *Store) handleInternalMsg(ctx context.Context, msg *nats.Msg) {
 		}
 		force, err := strToBool(forceStr)
 		if err != nil {
-			_ = hz.RespondError(
-				msg,
-				&hz.Error{
-					Status: http.StatusBadRequest,
-					Message: fmt.Sprintf(
-						"invalid header %s: %q: %q",
-						hz.HeaderApplyForceConflicts,
-						forceStr,
-						err.Error(),
-					),
-				},
-			)
-			return
+			return -1, nil, &hz.Error{
+				Status: http.StatusBadRequest,
+				Message: fmt.Sprintf(
+					"invalid header %s: %q: %q",
+					hz.HeaderApplyForceConflicts,
+					forceStr,
+					err.Error(),
+				),
+			}
 		}
 		createOnly, err := strToBool(createOnlyStr)
 		if err != nil {
-			_ = hz.RespondError(
-				msg,
-				&hz.Error{
-					Status: http.StatusBadRequest,
-					Message: fmt.Sprintf(
-						"invalid header %s: %q: %q",
-						hz.HeaderApplyCreateOnly,
-						createOnlyStr,
-						err.Error(),
-					),
-				},
-			)
-			return
+			return -1, nil, &hz.Error{
+				Status: http.StatusBadRequest,
+				Message: fmt.Sprintf(
+					"invalid header %s: %q: %q",
+					hz.HeaderApplyCreateOnly,
+					createOnlyStr,
+					err.Error(),
+				),
+			}
 		}
 		if manager == "" {
-			_ = hz.RespondError(
-				msg,
-				&hz.Error{
-					Status:  http.StatusBadRequest,
-					Message: "missing field manager",
-				},
-			)
-			return
+			return -1, nil, &hz.Error{
+				Status:  http.StatusBadRequest,
+				Message: "missing field manager",
+			}
 		}
 		// Check that the namespace exists, unless the object is a namespace.
 		checkNamespace := func(ns string) error {
@@ -415,8 +608,7 @@ func (s *Store) handleInternalMsg(ctx context.Context, msg *nats.Msg) {
 		if !isNamespaceKey(key) {
 			err := checkNamespace(key.Namespace)
 			if err != nil {
-				_ = hz.RespondError(msg, err)
-				return
+				return -1, nil, err
 			}
 		}
 
@@ -430,22 +622,18 @@ func (s *Store) handleInternalMsg(ctx context.Context, msg *nats.Msg) {
 
 		status, err := s.Apply(ctx, req)
 		if err != nil {
-			_ = hz.RespondError(msg, err)
-			return
+			return -1, nil, err
 		}
-		_ = hz.RespondStatus(msg, status, nil)
-		return
+		return status, nil, nil
 	case StoreCommandGet:
 		req := GetRequest{
 			Key: key,
 		}
 		resp, err := s.Get(ctx, req)
 		if err != nil {
-			_ = hz.RespondError(msg, err)
-			return
+			return -1, nil, err
 		}
-		_ = hz.RespondOK(msg, resp)
-		return
+		return http.StatusOK, resp, nil
 	case StoreCommandList:
 		// Logic: the auth rbac does not know which objects exist.
 		// Therefore, we cannot ask it which objects we can list.
@@ -456,8 +644,7 @@ func (s *Store) handleInternalMsg(ctx context.Context, msg *nats.Msg) {
 		}
 		resp, err := s.List(ctx, req)
 		if err != nil {
-			_ = hz.RespondError(msg, err)
-			return
+			return -1, nil, err
 		}
 		session := msg.Header.Get(hz.HeaderAuthorization)
 		if session != "" {
@@ -466,37 +653,40 @@ func (s *Store) handleInternalMsg(ctx context.Context, msg *nats.Msg) {
 				Session:    msg.Header.Get(hz.HeaderAuthorization),
 				ObjectList: resp,
 			}); err != nil {
-				_ = hz.RespondError(msg, err)
-				return
+				return -1, nil, err
 			}
 		}
 
 		data, err := json.Marshal(resp)
 		if err != nil {
-			_ = hz.RespondError(msg, &hz.Error{
+			return -1, nil, &hz.Error{
 				Status:  http.StatusInternalServerError,
 				Message: "marshalling list response: " + err.Error(),
-			})
-			return
+			}
 		}
-		_ = hz.RespondOK(msg, data)
-		return
+		return http.StatusOK, data, nil
 	case StoreCommandDelete:
 		req := DeleteRequest{
 			Key: key,
 		}
 		if err := s.Delete(ctx, req); err != nil {
-			_ = hz.RespondError(msg, err)
-			return
+			return -1, nil, err
 		}
-		_ = hz.RespondOK(msg, nil)
-		return
+		return http.StatusOK, nil, nil
+	case StoreCommandMigrate:
+		req := MigrateRequest{
+			Key: key,
+		}
+		status, err := s.Migrate(ctx, req)
+		if err != nil {
+			return -1, nil, err
+		}
+		return status, nil, nil
 	default:
-		_ = hz.RespondError(msg, &hz.Error{
+		return -1, nil, &hz.Error{
 			Status:  http.StatusBadRequest,
 			Message: "invalid command",
-		})
-		return
+		}
 	}
 }
 
@@ -5,15 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/verifa/horizon/pkg/auth"
 	"github.com/verifa/horizon/pkg/hz"
+	"github.com/verifa/horizon/pkg/internal/managedfields"
 	"github.com/verifa/horizon/pkg/server"
 	"github.com/verifa/horizon/pkg/store"
 	tu "github.com/verifa/horizon/pkg/testutil"
+	"golang.org/x/time/rate"
 	"golang.org/x/tools/txtar"
 	"sigs.k8s.io/yaml"
 )
@@ -26,6 +31,17 @@ var cmpOptIgnoreRevision = cmp.FilterPath(func(p cmp.Path) bool {
 		p.Last().String() == "[\"revision\"]"
 }, cmp.Ignore())
 
+// cmpOptIgnoreManagerTime ignores the time of the field managers, which is set
+// by the store when an object changes.
+var cmpOptIgnoreManagerTime = cmp.FilterPath(func(p cmp.Path) bool {
+	if len(p) != 8 {
+		return false
+	}
+	return p.Index(1).String() == "[\"metadata\"]" &&
+		p.Index(3).String() == "[\"managedFields\"]" &&
+		p.Last().String() == "[\"time\"]"
+}, cmp.Ignore())
+
 type DummyApplyObject struct {
 	hz.ObjectMeta `json:"metadata"`
 	Spec          struct{} `json:"spec"`
@@ -203,7 +219,13 @@ func runTest(
 				tu.AssertNoError(t, err, "unmarshal exp")
 				err = json.Unmarshal(actObj, &act)
 				tu.AssertNoError(t, err, "unmarshal act")
-				tu.AssertEqual(t, exp, act, cmpOptIgnoreRevision)
+				tu.AssertEqual(
+					t,
+					exp,
+					act,
+					cmpOptIgnoreRevision,
+					cmpOptIgnoreManagerTime,
+				)
 			case testStepCommandAssertDelete:
 				expJSONData, err := yaml.YAMLToJSON(file.Data)
 				tu.AssertNoError(t, err, "expObj yaml to json")
@@ -240,3 +262,327 @@ func runTest(
 		})
 	}
 }
+
+func TestStoreLimits(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(
+		t,
+		ctx,
+		server.WithStoreOptions(
+			store.WithSessionRateLimit(rate.Every(time.Minute), 2),
+			store.WithMaxObjectSize(1024),
+		),
+	)
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(DummyApplyObject{}),
+		hz.WithControllerValidatorCUE(false),
+		hz.WithControllerValidatorForceNone(),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+
+	newSessionClient := func() hz.Client {
+		sess, err := ti.Auth.Sessions.New(ctx, auth.UserInfo{
+			Sub:    "test",
+			Iss:    "test",
+			Groups: []string{"admin"},
+		})
+		tu.AssertNoError(t, err)
+		return hz.NewClient(
+			ti.Conn,
+			hz.WithClientSession(sess),
+			hz.WithClientManager("test"),
+		)
+	}
+	obj := DummyApplyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "limits",
+		},
+	}
+
+	// The session can make a burst of two requests, and then it is limited.
+	client := newSessionClient()
+	for i := 0; i < 2; i++ {
+		_, err := client.Get(ctx, hz.WithGetKey(obj))
+		tu.AssertErrorIs(t, err, hz.ErrNotFound)
+	}
+	_, err = client.Get(ctx, hz.WithGetKey(obj))
+	var hErr *hz.Error
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, hErr.Status, http.StatusTooManyRequests)
+
+	// Other sessions have their own limit.
+	otherClient := newSessionClient()
+	_, err = otherClient.Get(ctx, hz.WithGetKey(obj))
+	tu.AssertErrorIs(t, err, hz.ErrNotFound)
+
+	// Invalid sessions are rejected before they are limited.
+	invalidClient := hz.NewClient(
+		ti.Conn,
+		hz.WithClientSession("invalid"),
+		hz.WithClientManager("test"),
+	)
+	for i := 0; i < 3; i++ {
+		_, err := invalidClient.Get(ctx, hz.WithGetKey(obj))
+		tu.AssertErrorIs(t, err, auth.ErrInvalidCredentials)
+	}
+
+	// Objects larger than the max size are rejected.
+	data, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "dummy/v1",
+		"kind":       "DummyApplyObject",
+		"metadata": map[string]interface{}{
+			"namespace": "test",
+			"name":      "limits",
+			"labels": map[string]string{
+				"large": strings.Repeat("x", 1024),
+			},
+		},
+	})
+	tu.AssertNoError(t, err)
+	internalClient := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	_, err = internalClient.Apply(ctx, hz.WithApplyData(data))
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, hErr.Status, http.StatusRequestEntityTooLarge)
+}
+
+func TestStoreGlobalRateLimit(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(
+		t,
+		ctx,
+		server.WithStoreOptions(
+			store.WithRateLimit(rate.Every(time.Millisecond*500), 1),
+			store.WithSessionRateLimit(rate.Every(time.Minute), 2),
+		),
+	)
+	sess, err := ti.Auth.Sessions.New(ctx, auth.UserInfo{
+		Sub:    "test",
+		Iss:    "test",
+		Groups: []string{"admin"},
+	})
+	tu.AssertNoError(t, err)
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientSession(sess),
+		hz.WithClientManager("test"),
+	)
+	key := hz.ObjectKey{
+		Group:     "core",
+		Version:   "v1",
+		Kind:      "Namespace",
+		Namespace: "root",
+		Name:      "ratelimit",
+	}
+
+	_, err = client.Get(ctx, hz.WithGetKey(key))
+	tu.AssertErrorIs(t, err, hz.ErrNotFound)
+	// The global limit denies the request, without using a token of the
+	// session.
+	_, err = client.Get(ctx, hz.WithGetKey(key))
+	tu.AssertErrorIs(t, err, hz.ErrTooManyRequests)
+	time.Sleep(time.Millisecond * 600)
+	_, err = client.Get(ctx, hz.WithGetKey(key))
+	tu.AssertErrorIs(t, err, hz.ErrNotFound)
+	// Now the session is out of tokens.
+	time.Sleep(time.Millisecond * 600)
+	_, err = client.Get(ctx, hz.WithGetKey(key))
+	var hErr *hz.Error
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, hErr.Message, "too many requests for session")
+
+	// A burst of zero would deny every request.
+	_, err = store.StartStore(
+		ctx,
+		ti.Conn,
+		ti.Auth,
+		store.WithRateLimit(rate.Every(time.Second), 0),
+	)
+	tu.AssertTrue(t, err != nil)
+}
+
+type slowValidator struct {
+	hz.ZeroValidator
+	delay time.Duration
+}
+
+func (v *slowValidator) ValidateCreate(
+	ctx context.Context,
+	data []byte,
+) error {
+	time.Sleep(v.delay)
+	return nil
+}
+
+func TestValidatorPolicy(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+	client := hz.NewClient(
+		ti.Conn,
+		hz.WithClientInternal(true),
+		hz.WithClientManager("test"),
+	)
+	objClient := hz.ObjectClient[DummyApplyObject]{Client: client}
+	obj := func(name string) DummyApplyObject {
+		return DummyApplyObject{
+			ObjectMeta: hz.ObjectMeta{
+				Namespace: "test",
+				Name:      name,
+			},
+		}
+	}
+	startController := func(opts ...hz.ControllerOption) *hz.Controller {
+		ctlr, err := hz.StartController(
+			ctx,
+			ti.Conn,
+			append(
+				[]hz.ControllerOption{
+					hz.WithControllerFor(DummyApplyObject{}),
+					hz.WithControllerValidatorCUE(false),
+				},
+				opts...,
+			)...,
+		)
+		tu.AssertNoError(t, err)
+		return ctlr
+	}
+
+	// By default, the store fails closed if the validator is unavailable.
+	// The controller is stopped, so the error says that no controller is
+	// running for the kind.
+	ctlr := startController()
+	err := ctlr.Stop()
+	tu.AssertNoError(t, err)
+	_, err = objClient.Apply(ctx, obj("closed"))
+	var hErr *hz.Error
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, hErr.Status, http.StatusServiceUnavailable)
+	tu.AssertTrue(t, strings.Contains(
+		hErr.Message,
+		"no controller is running for kind dummy/DummyApplyObject",
+	), hErr.Message)
+
+	// A validator that is slower than the timeout is unavailable.
+	ctlr = startController(
+		hz.WithControllerValidator(&slowValidator{delay: time.Second}),
+		hz.WithControllerValidatorPolicy(hz.ValidatorPolicy{
+			Timeout: 100 * time.Millisecond,
+		}),
+	)
+	_, err = objClient.Apply(ctx, obj("slow"))
+	tu.AssertTrue(t, errors.As(err, &hErr))
+	tu.AssertEqual(t, hErr.Status, http.StatusServiceUnavailable)
+	tu.AssertTrue(t, strings.Contains(
+		hErr.Message,
+		"validator for kind dummy/DummyApplyObject is unavailable",
+	), hErr.Message)
+	err = ctlr.Stop()
+	tu.AssertNoError(t, err)
+
+	// The policy is kept after the controller stops, so the store fails open.
+	ctlr = startController(
+		hz.WithControllerValidatorForceNone(),
+		hz.WithControllerValidatorPolicy(hz.ValidatorPolicy{
+			FailurePolicy: hz.ValidatorFailOpen,
+		}),
+	)
+	err = ctlr.Stop()
+	tu.AssertNoError(t, err)
+	_, err = objClient.Apply(ctx, obj("open"))
+	tu.AssertNoError(t, err)
+}
+
+func TestApplyManagerTime(t *testing.T) {
+	ctx := context.Background()
+	ti := server.Test(t, ctx)
+	ctlr, err := hz.StartController(
+		ctx,
+		ti.Conn,
+		hz.WithControllerFor(DummyApplyObject{}),
+		hz.WithControllerValidatorCUE(false),
+		hz.WithControllerValidatorForceNone(),
+	)
+	tu.AssertNoError(t, err)
+	t.Cleanup(func() {
+		_ = ctlr.Stop()
+	})
+	client := hz.ObjectClient[DummyApplyObject]{
+		Client: hz.NewClient(
+			ti.Conn,
+			hz.WithClientInternal(true),
+			hz.WithClientManager("m1"),
+		),
+	}
+	apply := func(label string) (hz.ApplyOpResult, managedfields.FieldManager) {
+		result, err := client.Apply(ctx, DummyApplyObject{
+			ObjectMeta: hz.ObjectMeta{
+				Namespace: "test",
+				Name:      "time",
+				Labels:    map[string]string{"label": label},
+			},
+		})
+		tu.AssertNoError(t, err)
+		obj, err := client.Get(ctx, hz.WithGetKey(hz.ObjectKey{
+			Group:     "dummy",
+			Version:   "v1",
+			Kind:      "DummyApplyObject",
+			Namespace: "test",
+			Name:      "time",
+		}))
+		tu.AssertNoError(t, err)
+		fm, ok := obj.ManagedFields.FieldManager("m1")
+		tu.AssertTrue(t, ok, "missing field manager")
+		return result, fm
+	}
+
+	result, created := apply("a")
+	tu.AssertEqual(t, hz.ApplyOpResultCreated, result)
+	tu.AssertEqual(t, managedfields.OperationApply, created.Operation)
+	tu.AssertEqual(t, "dummy/v1", created.APIVersion)
+	tu.AssertTrue(t, created.Time != nil, "missing time")
+
+	// The time has a precision of seconds, so wait for it to change.
+	time.Sleep(time.Second)
+	// Applying the same object is a no-op, and keeps the time.
+	result, noop := apply("a")
+	tu.AssertEqual(t, hz.ApplyOpResultNoop, result)
+	tu.AssertEqual(t, created.Time, noop.Time)
+	// Changing the object updates the time.
+	result, updated := apply("b")
+	tu.AssertEqual(t, hz.ApplyOpResultUpdated, result)
+	tu.AssertTrue(t, updated.Time.After(*created.Time), "time not updated")
+
+	// Applying an object that was created, without changing it, is a no-op
+	// even though the operation differs, and does not bump the revision.
+	obj := DummyApplyObject{
+		ObjectMeta: hz.ObjectMeta{
+			Namespace: "test",
+			Name:      "create",
+		},
+	}
+	_, err = client.Client.Apply(
+		ctx,
+		hz.WithApplyObject(obj),
+		hz.WithApplyCreateOnly(true),
+	)
+	tu.AssertNoError(t, err)
+	before, err := client.Get(ctx, hz.WithGetKey(obj))
+	tu.AssertNoError(t, err)
+	result, err = client.Apply(ctx, obj)
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, hz.ApplyOpResultNoop, result)
+	after, err := client.Get(ctx, hz.WithGetKey(obj))
+	tu.AssertNoError(t, err)
+	tu.AssertEqual(t, *before.Revision, *after.Revision)
+	fm, _ := after.ManagedFields.FieldManager("m1")
+	tu.AssertEqual(t, managedfields.OperationUpdate, fm.Operation)
+}
@@ -53,7 +53,7 @@ func (s *Store) update(
 			),
 		}
 	}
-	if _, err := s.kv.Update(ctx, rawKey, data, revision); err != nil {
+	if _, err := s.putObject(ctx, rawKey, data, revision); err != nil {
 		if isErrWrongLastSequence(err) {
 			return hz.ErrIncorrectRevision
 		}
@@ -2,13 +2,24 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/verifa/horizon/pkg/hz"
 )
 
+const (
+	defaultValidateCreateTimeout = time.Second
+	defaultValidateUpdateTimeout = time.Second * 5
+)
+
 type ValidateRequest struct{}
 
 func (s *Store) Validate(ctx context.Context, req ValidateRequest) error {
@@ -29,13 +40,13 @@ func (s *Store) validateCreate(
 		key.ObjectVersion(),
 		key.ObjectKind(),
 	)
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
-	reply, err := s.Conn.RequestWithContext(ctx, subject, data)
-	if err != nil {
-		return hz.ErrorFromNATSErr(err)
-	}
-	return hz.ErrorFromNATS(reply)
+	return s.requestValidate(
+		ctx,
+		key,
+		subject,
+		data,
+		defaultValidateCreateTimeout,
+	)
 }
 
 func (s *Store) validateUpdate(
@@ -49,11 +60,145 @@ func (s *Store) validateUpdate(
 		key.ObjectVersion(),
 		key.ObjectKind(),
 	)
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	return s.requestValidate(
+		ctx,
+		key,
+		subject,
+		data,
+		defaultValidateUpdateTimeout,
+	)
+}
+
+// requestValidate requests the controller to validate the object in data,
+// propagating the trace context.
+// The timeout and what happens if no validator responds is defined by the
+// validator policy of the kind.
+func (s *Store) requestValidate(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+	subject string,
+	data []byte,
+	defaultTimeout time.Duration,
+) error {
+	policy, err := s.validatorPolicy(ctx, key)
+	if err != nil {
+		return err
+	}
+	timeout := defaultTimeout
+	if policy.Timeout > 0 {
+		timeout = policy.Timeout
+	}
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	hz.TraceInject(ctx, msg.Header)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	reply, err := s.Conn.RequestWithContext(ctx, subject, data)
+	reply, err := s.Conn.RequestMsgWithContext(ctx, msg)
 	if err != nil {
-		return hz.ErrorFromNATSErr(err)
+		if !isErrUnavailable(err) {
+			return hz.ErrorFromNATSErr(err)
+		}
+		live := s.hasLiveController(ctx, key)
+		if policy.FailurePolicy == hz.ValidatorFailOpen {
+			msg := "validator unavailable: failing open"
+			if !live {
+				msg = "no controller is running for kind: failing open"
+			}
+			slog.Warn(
+				msg,
+				"group",
+				key.ObjectGroup(),
+				"kind",
+				key.ObjectKind(),
+				"error",
+				err,
+			)
+			return nil
+		}
+		if !live {
+			return &hz.Error{
+				Status: http.StatusServiceUnavailable,
+				Message: fmt.Sprintf(
+					"no controller is running for kind %s/%s: "+
+						"start a controller for the kind and try again",
+					key.ObjectGroup(),
+					key.ObjectKind(),
+				),
+			}
+		}
+		return &hz.Error{
+			Status: http.StatusServiceUnavailable,
+			Message: fmt.Sprintf(
+				"validator for kind %s/%s is unavailable: %s",
+				key.ObjectGroup(),
+				key.ObjectKind(),
+				err.Error(),
+			),
+		}
 	}
 	return hz.ErrorFromNATS(reply)
 }
+
+// hasLiveController returns true if the controller registry has a running
+// controller for the kind of the key.
+// If the registry cannot be read, it assumes there is a controller, so that
+// the error is about the validator.
+func (s *Store) hasLiveController(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+) bool {
+	keys, err := s.controllers.Keys(ctx)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return false
+		}
+		slog.Error("listing controllers", "error", err)
+		return true
+	}
+	prefix := key.ObjectGroup() + "." + key.ObjectKind() + "."
+	for _, k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatorPolicy returns the validator policy registered for the kind of the
+// key, or the default policy if none is registered.
+func (s *Store) validatorPolicy(
+	ctx context.Context,
+	key hz.ObjectKeyer,
+) (hz.ValidatorPolicy, error) {
+	entry, err := s.validators.Get(ctx, hz.ValidatorPolicyKey(key))
+	if err != nil {
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			return hz.ValidatorPolicy{}, nil
+		}
+		return hz.ValidatorPolicy{}, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"getting validator policy: %s",
+				err.Error(),
+			),
+		}
+	}
+	var policy hz.ValidatorPolicy
+	if err := json.Unmarshal(entry.Value(), &policy); err != nil {
+		return hz.ValidatorPolicy{}, &hz.Error{
+			Status: http.StatusInternalServerError,
+			Message: fmt.Sprintf(
+				"unmarshalling validator policy: %s",
+				err.Error(),
+			),
+		}
+	}
+	return policy, nil
+}
+
+// isErrUnavailable returns true if the error means no validator responded.
+func isErrUnavailable(err error) bool {
+	return errors.Is(err, nats.ErrNoResponders) ||
+		errors.Is(err, nats.ErrTimeout) ||
+		errors.Is(err, context.DeadlineExceeded)
+}